@@ -1126,6 +1126,35 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 	return bestAddress
 }
 
+// Reachability returns a score indicating how well the provided remote address
+// can be reached from the best of the known local addresses.  A score of zero
+// means the address is unreachable, higher scores are preferable.  A local
+// address in the same network group as the remote address scores higher than
+// any other local address with the same reachability class.
+func (a *AddrManager) Reachability(remoteAddr *wire.NetAddress) int {
+	a.lamtx.Lock()
+	defer a.lamtx.Unlock()
+
+	best := 0
+	remoteGroup := GroupKey(remoteAddr)
+	for _, la := range a.localAddresses {
+		reach := getReachabilityFrom(la.na, remoteAddr) * 2
+		if reach > 0 && GroupKey(la.na) == remoteGroup {
+			reach++
+		}
+		if reach > best {
+			best = reach
+		}
+	}
+	return best
+}
+
+// Reachable returns whether the provided remote address is reachable from any
+// of the known local addresses.
+func (a *AddrManager) Reachable(remoteAddr *wire.NetAddress) bool {
+	return a.Reachability(remoteAddr) > 0
+}
+
 // New returns a new bitcoin address manager.
 // Use Start to begin processing asynchronous address updates.
 func New(dataDir string, lookupFunc func(string) ([]net.IP, er.R)) *AddrManager {
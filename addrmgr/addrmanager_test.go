@@ -353,6 +353,40 @@ func TestGetAddress(t *testing.T) {
 	}
 }
 
+func TestReachability(t *testing.T) {
+	amgr := addrmgr.New("testreachability", nil)
+
+	near := wire.NetAddress{IP: net.ParseIP("12.1.2.3")}
+	far := wire.NetAddress{IP: net.ParseIP("173.194.115.66")}
+	private := wire.NetAddress{IP: net.ParseIP("192.168.0.1")}
+
+	// Nothing is reachable without any local address.
+	if score := amgr.Reachability(&far); score != 0 {
+		t.Errorf("Reachability: want 0 with no local addresses, got %d", score)
+	}
+
+	amgr.AddLocalAddress(&wire.NetAddress{IP: net.ParseIP("12.1.1.1")},
+		addrmgr.InterfacePrio)
+
+	nearScore := amgr.Reachability(&near)
+	farScore := amgr.Reachability(&far)
+	if farScore <= 0 {
+		t.Errorf("Reachability: want positive score for %s, got %d",
+			far.IP, farScore)
+	}
+	if nearScore <= farScore {
+		t.Errorf("Reachability: want same group %s (%d) to score higher "+
+			"than %s (%d)", near.IP, nearScore, far.IP, farScore)
+	}
+	if score := amgr.Reachability(&private); score != 0 {
+		t.Errorf("Reachability: want 0 for unroutable %s, got %d",
+			private.IP, score)
+	}
+	if !amgr.Reachable(&far) || amgr.Reachable(&private) {
+		t.Errorf("Reachable: inconsistent with Reachability")
+	}
+}
+
 func TestGetBestLocalAddress(t *testing.T) {
 	localAddrs := []wire.NetAddress{
 		{IP: net.ParseIP("192.168.0.100")},
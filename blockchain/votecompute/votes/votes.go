@@ -1,29 +1,88 @@
 package votes
 
 import (
+	"bytes"
+
+	"github.com/pkt-cash/pktd/btcutil"
+	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/txscript/params"
 	"github.com/pkt-cash/pktd/txscript/parsescript"
 )
 
 const (
 	VOTE      byte = 0x00
 	CANDIDATE byte = 0x01
+
+	// WITHDRAW is a vote which also explicitly withdraws the voter's
+	// candidacy.
+	WITHDRAW byte = 0x02
 )
 
 type NsVote struct {
 	VoterPkScript           []byte
 	VoterIsWillingCandidate bool
+	VoterWithdrawsCandidacy bool
 	VoteCastInBlock         uint32
 	VoteForPkScript         []byte
 }
 
+// Validate checks that a vote which is about to be stored is well formed. The
+// voter and the block where the vote was cast must be known, and neither the
+// voter script nor the script voted for may exceed the maximum script size.
+// The script voted for may be empty, which is a vote for nobody.
+func (v *NsVote) Validate() er.R {
+	if len(v.VoterPkScript) == 0 {
+		return er.New("NsVote: VoterPkScript is empty")
+	} else if len(v.VoterPkScript) > params.MaxScriptSize {
+		return er.Errorf("NsVote: VoterPkScript is [%d] bytes, more than the maximum of [%d]",
+			len(v.VoterPkScript), params.MaxScriptSize)
+	} else if len(v.VoteForPkScript) > params.MaxScriptSize {
+		return er.Errorf("NsVote: VoteForPkScript is [%d] bytes, more than the maximum of [%d]",
+			len(v.VoteForPkScript), params.MaxScriptSize)
+	} else if v.VoteCastInBlock == 0 {
+		return er.New("NsVote: VoteCastInBlock is zero")
+	}
+	return nil
+}
+
 const EpochBlocks = 60 * 24 * 7
 
 const VoteExpirationEpochs = 52
 
 const VoteExpirationBlocks = VoteExpirationEpochs * EpochBlocks
 
-func GetVote(outputScript []byte) *NsVote {
+// Namespace is a prefix which is placed in front of the vote operation byte so
+// that third party vote systems can reuse the OP_RETURN vote format without
+// their votes being counted as protocol votes. The protocol votes use
+// DefaultNamespace, which is empty.
+type Namespace []byte
+
+// DefaultNamespace is the namespace of the protocol votes.
+var DefaultNamespace Namespace
+
+// Valid returns true if ns can be used for parsing and encoding votes. A
+// custom namespace must not begin with a vote operation byte, otherwise its
+// votes would be indistinguishable from protocol votes.
+func (ns Namespace) Valid() bool {
+	return len(ns) == 0 || ns[0] > WITHDRAW
+}
+
+// EncodeVote creates the payload of the OP_RETURN output which casts a vote in
+// this namespace. The op is one of VOTE, CANDIDATE or WITHDRAW.
+func (ns Namespace) EncodeVote(op byte, voteFor []byte) []byte {
+	out := make([]byte, 0, len(ns)+1+len(voteFor))
+	out = append(out, ns...)
+	out = append(out, op)
+	return append(out, voteFor...)
+}
+
+// GetVote parses an output script as a vote in this namespace, if the script
+// is not a vote or it is a vote in a different namespace then nil is returned.
+func (ns Namespace) GetVote(outputScript []byte) *NsVote {
+	if !ns.Valid() {
+		return nil
+	}
 	scr, err := parsescript.ParseScript(outputScript)
 	if err != nil {
 		return nil
@@ -41,12 +100,65 @@ func GetVote(outputScript []byte) *NsVote {
 		return nil
 	}
 	data := scr[1].Data
-	if len(data) < 1 || (data[0] != VOTE && data[0] != CANDIDATE) {
+	if !bytes.HasPrefix(data, ns) {
+		// Vote from a different namespace
+		return nil
+	}
+	data = data[len(ns):]
+	if len(data) < 1 || (data[0] != VOTE && data[0] != CANDIDATE && data[0] != WITHDRAW) {
 		// Not a vote operation
 		return nil
 	}
 	return &NsVote{
 		VoterIsWillingCandidate: data[0] == CANDIDATE,
+		VoterWithdrawsCandidacy: data[0] == WITHDRAW,
 		VoteForPkScript:         data[1:],
 	}
 }
+
+// GetVote parses an output script as a protocol vote, votes in any custom
+// namespace are ignored.
+func GetVote(outputScript []byte) *NsVote {
+	return DefaultNamespace.GetVote(outputScript)
+}
+
+// VoteTally is the weight of the votes for one candidate.
+type VoteTally struct {
+	CandidatePkScript []byte
+	Votes             btcutil.Amount
+}
+
+// ElectionResult determines the winner of an election from the tally of each
+// candidate. If more than one candidate has the most votes then tie is true
+// and the tie is broken in favor of the candidate whose pkScript sorts first,
+// so that every node picks the same winner. The quorum is reached if the total
+// weight of all votes is at least quorum. An empty tally has no winner.
+func ElectionResult(
+	tally map[string]*VoteTally,
+	quorum btcutil.Amount,
+) (winner []byte, tie bool, reachedQuorum bool, err er.R) {
+	var total, best btcutil.Amount
+	found := false
+	for k, t := range tally {
+		if t == nil {
+			return nil, false, false, er.Errorf("ElectionResult: nil tally for [%x]", k)
+		} else if t.Votes < 0 {
+			return nil, false, false, er.Errorf("ElectionResult: candidate [%x] has "+
+				"negative votes [%d]", t.CandidatePkScript, t.Votes)
+		}
+		total += t.Votes
+		switch {
+		case !found || t.Votes > best:
+			found = true
+			winner = t.CandidatePkScript
+			best = t.Votes
+			tie = false
+		case t.Votes == best:
+			tie = true
+			if bytes.Compare(t.CandidatePkScript, winner) < 0 {
+				winner = t.CandidatePkScript
+			}
+		}
+	}
+	return winner, tie, total >= quorum, nil
+}
@@ -0,0 +1,234 @@
+package votes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/txscript/params"
+	"github.com/pkt-cash/pktd/txscript/scriptbuilder"
+)
+
+func voteScript(t *testing.T, voteType byte, voteFor []byte) []byte {
+	data := append([]byte{voteType}, voteFor...)
+	script, err := scriptbuilder.NewScriptBuilder().
+		AddOp(opcode.OP_RETURN).AddData(data).Script()
+	if err != nil {
+		t.Fatalf("unable to build vote script: %v", err)
+	}
+	return script
+}
+
+// TestGetVoteWithdraw checks that a candidacy declaration followed by an
+// explicit withdrawal are both recognized.
+func TestGetVoteWithdraw(t *testing.T) {
+	voteFor := []byte{0x00, 0x14, 0x01, 0x02, 0x03}
+
+	declare := GetVote(voteScript(t, CANDIDATE, voteFor))
+	if declare == nil {
+		t.Fatalf("candidacy declaration not recognized")
+	}
+	if !declare.VoterIsWillingCandidate || declare.VoterWithdrawsCandidacy {
+		t.Fatalf("declaration parsed as candidate=%v withdraw=%v",
+			declare.VoterIsWillingCandidate, declare.VoterWithdrawsCandidacy)
+	}
+
+	withdraw := GetVote(voteScript(t, WITHDRAW, voteFor))
+	if withdraw == nil {
+		t.Fatalf("withdrawal not recognized")
+	}
+	if withdraw.VoterIsWillingCandidate || !withdraw.VoterWithdrawsCandidacy {
+		t.Fatalf("withdrawal parsed as candidate=%v withdraw=%v",
+			withdraw.VoterIsWillingCandidate, withdraw.VoterWithdrawsCandidacy)
+	}
+	if !bytes.Equal(withdraw.VoteForPkScript, voteFor) {
+		t.Fatalf("withdrawal votes for %x, want %x",
+			withdraw.VoteForPkScript, voteFor)
+	}
+
+	vote := GetVote(voteScript(t, VOTE, voteFor))
+	if vote == nil || vote.VoterIsWillingCandidate || vote.VoterWithdrawsCandidacy {
+		t.Fatalf("plain vote parsed incorrectly: %+v", vote)
+	}
+
+	if GetVote(voteScript(t, 0x03, voteFor)) != nil {
+		t.Fatalf("unknown vote type was recognized")
+	}
+}
+
+// TestGetVoteNamespace checks that a namespaced vote is ignored by the
+// protocol parser but recognized by a parser for the same namespace.
+func TestGetVoteNamespace(t *testing.T) {
+	voteFor := []byte{0x00, 0x14, 0x01, 0x02, 0x03}
+	ns := Namespace("xvote")
+	if !ns.Valid() {
+		t.Fatalf("namespace %q should be valid", ns)
+	}
+
+	script, err := scriptbuilder.NewScriptBuilder().
+		AddOp(opcode.OP_RETURN).AddData(ns.EncodeVote(CANDIDATE, voteFor)).Script()
+	if err != nil {
+		t.Fatalf("unable to build vote script: %v", err)
+	}
+	if v := GetVote(script); v != nil {
+		t.Fatalf("namespaced vote recognized by protocol parser: %+v", v)
+	}
+	if v := Namespace("other").GetVote(script); v != nil {
+		t.Fatalf("namespaced vote recognized by other namespace: %+v", v)
+	}
+	v := ns.GetVote(script)
+	if v == nil {
+		t.Fatalf("namespaced vote not recognized")
+	}
+	if !v.VoterIsWillingCandidate || !bytes.Equal(v.VoteForPkScript, voteFor) {
+		t.Fatalf("namespaced vote parsed incorrectly: %+v", v)
+	}
+
+	if ns.GetVote(voteScript(t, VOTE, voteFor)) != nil {
+		t.Fatalf("protocol vote recognized by namespaced parser")
+	}
+	if !bytes.Equal(DefaultNamespace.EncodeVote(VOTE, voteFor), append([]byte{VOTE}, voteFor...)) {
+		t.Fatalf("default namespace changed the protocol vote encoding")
+	}
+
+	bad := Namespace{CANDIDATE, 'x'}
+	if bad.Valid() {
+		t.Fatalf("namespace beginning with a vote op should be invalid")
+	}
+	if bad.GetVote(script) != nil {
+		t.Fatalf("invalid namespace parsed a vote")
+	}
+}
+
+// TestElectionResult checks the winner, tie and quorum reported for a clear
+// winner, an exact tie and a participation below quorum.
+func TestElectionResult(t *testing.T) {
+	a := []byte{0x00, 0x14, 0x0a}
+	b := []byte{0x00, 0x14, 0x0b}
+	c := []byte{0x00, 0x14, 0x0c}
+	mk := func(tallies ...*VoteTally) map[string]*VoteTally {
+		out := make(map[string]*VoteTally)
+		for _, t := range tallies {
+			out[string(t.CandidatePkScript)] = t
+		}
+		return out
+	}
+
+	// Clear winner.
+	winner, tie, quorum, err := ElectionResult(mk(
+		&VoteTally{a, 100}, &VoteTally{b, 300}, &VoteTally{c, 200}), 500)
+	if err != nil {
+		t.Fatalf("ElectionResult: %v", err)
+	}
+	if !bytes.Equal(winner, b) || tie || !quorum {
+		t.Fatalf("clear winner: got winner %x, tie %v, quorum %v", winner, tie, quorum)
+	}
+
+	// Exact tie, the first pkScript wins every time.
+	for i := 0; i < 10; i++ {
+		winner, tie, quorum, err = ElectionResult(mk(
+			&VoteTally{c, 300}, &VoteTally{b, 300}, &VoteTally{a, 100}), 500)
+		if err != nil {
+			t.Fatalf("ElectionResult: %v", err)
+		}
+		if !bytes.Equal(winner, b) || !tie || !quorum {
+			t.Fatalf("tie: got winner %x, tie %v, quorum %v", winner, tie, quorum)
+		}
+	}
+
+	// A tie for second place is not a tie.
+	_, tie, _, _ = ElectionResult(mk(
+		&VoteTally{a, 500}, &VoteTally{b, 300}, &VoteTally{c, 300}), 0)
+	if tie {
+		t.Fatalf("tie for second place reported as a tie")
+	}
+
+	// Below quorum.
+	winner, tie, quorum, err = ElectionResult(mk(
+		&VoteTally{a, 100}, &VoteTally{b, 50}), 1000)
+	if err != nil {
+		t.Fatalf("ElectionResult: %v", err)
+	}
+	if !bytes.Equal(winner, a) || tie || quorum {
+		t.Fatalf("below quorum: got winner %x, tie %v, quorum %v", winner, tie, quorum)
+	}
+
+	// No votes.
+	if winner, _, _, _ := ElectionResult(nil, 0); winner != nil {
+		t.Fatalf("empty tally has winner %x", winner)
+	}
+
+	// Invalid tally.
+	if _, _, _, err := ElectionResult(mk(&VoteTally{a, -1}), 0); err == nil {
+		t.Fatalf("expected error for negative votes")
+	}
+}
+
+// TestNsVoteValidate checks that a vote is rejected when the voter or the
+// block is missing or when a script is too long.
+func TestNsVoteValidate(t *testing.T) {
+	voter := []byte{0x00, 0x14, 0x01, 0x02, 0x03}
+	valid := func() *NsVote {
+		return &NsVote{
+			VoterPkScript:   voter,
+			VoteCastInBlock: 100,
+			VoteForPkScript: []byte{0x00, 0x14, 0x04, 0x05, 0x06},
+		}
+	}
+	tooLong := bytes.Repeat([]byte{0x01}, params.MaxScriptSize+1)
+
+	tests := []struct {
+		name   string
+		modify func(v *NsVote)
+		valid  bool
+	}{
+		{"valid", func(v *NsVote) {}, true},
+		{"vote for nobody", func(v *NsVote) { v.VoteForPkScript = nil }, true},
+		{"no voter", func(v *NsVote) { v.VoterPkScript = nil }, false},
+		{"voter too long", func(v *NsVote) { v.VoterPkScript = tooLong }, false},
+		{"vote for too long", func(v *NsVote) { v.VoteForPkScript = tooLong }, false},
+		{"no block", func(v *NsVote) { v.VoteCastInBlock = 0 }, false},
+	}
+	for _, test := range tests {
+		v := valid()
+		test.modify(v)
+		if err := v.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
+
+// TestEncodeVoteRoundTrip checks that every vote operation encoded with
+// EncodeVote is parsed back by GetVote with the same meaning.
+func TestEncodeVoteRoundTrip(t *testing.T) {
+	voteFor := []byte{0x00, 0x14, 0x01, 0x02, 0x03}
+	for _, test := range []struct {
+		op        byte
+		candidate bool
+		withdraw  bool
+	}{
+		{op: VOTE},
+		{op: CANDIDATE, candidate: true},
+		{op: WITHDRAW, withdraw: true},
+	} {
+		script, err := scriptbuilder.NewScriptBuilder().
+			AddOp(opcode.OP_RETURN).AddData(DefaultNamespace.EncodeVote(test.op, voteFor)).Script()
+		if err != nil {
+			t.Fatalf("unable to build vote script: %v", err)
+		}
+		if !bytes.Equal(script, voteScript(t, test.op, voteFor)) {
+			t.Fatalf("op [%d]: EncodeVote disagrees with the protocol encoding", test.op)
+		}
+		v := GetVote(script)
+		if v == nil {
+			t.Fatalf("op [%d]: vote not recognized", test.op)
+		}
+		if v.VoterIsWillingCandidate != test.candidate || v.VoterWithdrawsCandidacy != test.withdraw {
+			t.Fatalf("op [%d]: parsed as candidate=%v withdraw=%v", test.op,
+				v.VoterIsWillingCandidate, v.VoterWithdrawsCandidacy)
+		}
+		if !bytes.Equal(v.VoteForPkScript, voteFor) {
+			t.Fatalf("op [%d]: votes for %x, want %x", test.op, v.VoteForPkScript, voteFor)
+		}
+	}
+}
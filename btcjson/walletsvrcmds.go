@@ -271,6 +271,9 @@ type SendVoteCmd struct {
 	// Do not source funds from any payments OLDER (lower block height) than this number
 	// default 0 = no limit
 	MinHeight *uint32
+	// True if you want to explicitly withdraw a previous candidacy.
+	// Cannot be combined with IsCandidate.
+	WithdrawCandidacy *bool
 }
 
 // NewSendFromCmd returns a new instance which can be used to issue a sendfrom
@@ -226,6 +226,8 @@ type GetNetworkStewardVoteResult struct {
 type AddressVoteInfo struct {
 	// True this the address has indicated it's candidacy in the most recent vote
 	IsCandidate bool `json:"is_candidate,omitempty"`
+	// True if the address has explicitly withdrawn it's candidacy in the most recent vote
+	WithdrawsCandidacy bool `json:"withdraws_candidacy,omitempty"`
 	// Who the address voted for, empty string if they voted for themselves or nobody
 	VoteFor string `json:"vote_for,omitempty"`
 	// The transaction ID of the vote
@@ -123,7 +123,17 @@ type ChangePasswordRequest struct {
 	//When using JSON, this field must be encoded as base64.
 	NewPassphraseBin []byte `protobuf:"bytes,4,opt,name=new_passphrase_bin,json=newPassphraseBin,proto3" json:"new_passphrase_bin,omitempty"`
 	//wallet_name is optional, if specified will override default wallet.db
-	WalletName           string   `protobuf:"bytes,5,opt,name=wallet_name,json=walletName,proto3" json:"wallet_name,omitempty"`
+	WalletName string `protobuf:"bytes,5,opt,name=wallet_name,json=walletName,proto3" json:"wallet_name,omitempty"`
+	//
+	//current_public_passphrase is the current public passphrase which is used to
+	//open the wallet database. If not specified then the default public
+	//passphrase is used.
+	CurrentPublicPassphrase string `protobuf:"bytes,6,opt,name=current_public_passphrase,json=currentPublicPassphrase,proto3" json:"current_public_passphrase,omitempty"`
+	//
+	//new_public_passphrase, if specified, replaces the public passphrase. It is
+	//changed atomically together with the private passphrase, if new_passphrase
+	//is not specified then the private passphrase is left unchanged.
+	NewPublicPassphrase  string   `protobuf:"bytes,7,opt,name=new_public_passphrase,json=newPublicPassphrase,proto3" json:"new_public_passphrase,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -189,6 +199,20 @@ func (m *ChangePasswordRequest) GetWalletName() string {
 	return ""
 }
 
+func (m *ChangePasswordRequest) GetCurrentPublicPassphrase() string {
+	if m != nil {
+		return m.CurrentPublicPassphrase
+	}
+	return ""
+}
+
+func (m *ChangePasswordRequest) GetNewPublicPassphrase() string {
+	if m != nil {
+		return m.NewPublicPassphrase
+	}
+	return ""
+}
+
 type ChangePasswordResponse struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -396,37 +420,39 @@ func init() {
 func init() { proto.RegisterFile("metaservice.proto", fileDescriptor_b3fb5294949b9545) }
 
 var fileDescriptor_b3fb5294949b9545 = []byte{
-	// 502 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x94, 0x5f, 0x8b, 0xd3, 0x40,
-	0x14, 0xc5, 0xe9, 0xd6, 0x5d, 0xda, 0xdb, 0xed, 0x9f, 0xcc, 0xd6, 0x35, 0xc4, 0x15, 0x25, 0x28,
-	0xb8, 0xe8, 0xa6, 0x52, 0x05, 0x1f, 0x7c, 0x6b, 0x41, 0x51, 0xd8, 0x65, 0x89, 0x0f, 0x82, 0x2f,
-	0x65, 0x9a, 0x5e, 0x9b, 0xd0, 0x74, 0x32, 0xce, 0x4c, 0xb7, 0x5f, 0xc4, 0x67, 0x9f, 0xfc, 0x7c,
-	0x7e, 0x06, 0xc9, 0xcc, 0xa4, 0x4d, 0xd2, 0xf5, 0xa1, 0x90, 0x9e, 0xfb, 0xcb, 0xe1, 0xcc, 0x99,
-	0xdb, 0x82, 0xb3, 0x46, 0x45, 0x25, 0x8a, 0xbb, 0x24, 0xc2, 0x80, 0x8b, 0x4c, 0x65, 0xe4, 0x38,
-	0x65, 0x82, 0x47, 0x5e, 0x9b, 0xaf, 0x94, 0x51, 0xbc, 0xb6, 0xe0, 0x91, 0x79, 0xf4, 0x1d, 0xe8,
-	0x7f, 0x42, 0xf5, 0x99, 0xfd, 0xc8, 0xc6, 0x21, 0xfe, 0xdc, 0xa0, 0x54, 0xfe, 0x9f, 0x06, 0x0c,
-	0xf6, 0x9a, 0xe4, 0x19, 0x93, 0x48, 0x46, 0xd0, 0x62, 0xb8, 0x51, 0x22, 0x61, 0x99, 0xdb, 0x78,
-	0xd6, 0x78, 0xd9, 0x19, 0x9f, 0x05, 0xda, 0x37, 0xb8, 0xb1, 0x72, 0xce, 0x87, 0x3b, 0x88, 0x5c,
-	0xc2, 0xc9, 0x96, 0xa6, 0x29, 0x2a, 0xf7, 0x48, 0xe3, 0x8e, 0xc5, 0xbf, 0x69, 0x51, 0xc3, 0x16,
-	0x20, 0xef, 0xa0, 0x9d, 0x26, 0xcb, 0x58, 0xb1, 0x84, 0x2d, 0xdd, 0xa6, 0xa6, 0xcf, 0x2d, 0x6d,
-	0x73, 0x14, 0x31, 0xc2, 0x3d, 0xe8, 0xff, 0x6d, 0xc0, 0xc3, 0x69, 0x4c, 0xd9, 0x12, 0x6f, 0xa9,
-	0x94, 0xdb, 0x4c, 0x2c, 0xec, 0x01, 0xc8, 0x15, 0x90, 0x68, 0x23, 0x04, 0x32, 0x35, 0xe3, 0x54,
-	0x4a, 0x1e, 0x0b, 0x2a, 0x51, 0xa7, 0x6e, 0x87, 0x8e, 0x9d, 0xdc, 0xee, 0x06, 0xe4, 0x0d, 0x0c,
-	0xcb, 0x78, 0xee, 0x34, 0x9b, 0x27, 0x4c, 0xe7, 0x3e, 0x0d, 0x49, 0xe9, 0x85, 0x7c, 0x34, 0x49,
-	0x18, 0x79, 0x01, 0x3d, 0x86, 0xdb, 0xb2, 0x79, 0x53, 0x9b, 0x77, 0x19, 0x6e, 0x4b, 0xc6, 0xaf,
-	0x81, 0x54, 0x31, 0x6d, 0xfb, 0x40, 0xdb, 0x0e, 0x2a, 0x68, 0x6e, 0xfa, 0x14, 0x3a, 0xa6, 0x8f,
-	0x19, 0xa3, 0x6b, 0x74, 0x8f, 0xb5, 0x23, 0x18, 0xe9, 0x86, 0xae, 0xd1, 0x77, 0xe1, 0xbc, 0x7e,
-	0x5e, 0xd3, 0x8a, 0xff, 0xab, 0x01, 0xc3, 0x69, 0x8c, 0xd1, 0xaa, 0xde, 0xc4, 0x2b, 0x70, 0xac,
-	0xe7, 0x41, 0x11, 0x03, 0x33, 0x28, 0xc5, 0x0d, 0xe0, 0xac, 0x04, 0xd7, 0x6a, 0x70, 0xf6, 0x78,
-	0xd1, 0x42, 0x2d, 0x70, 0xf3, 0x20, 0xf0, 0x24, 0xbf, 0xa0, 0x4a, 0x2a, 0xbb, 0x4c, 0x97, 0x30,
-	0xb8, 0xa3, 0x69, 0xb2, 0xa8, 0xa7, 0x6a, 0x85, 0x7d, 0xad, 0xef, 0x43, 0xf9, 0x3d, 0x38, 0x9d,
-	0x0a, 0x2a, 0xe3, 0x62, 0x39, 0xfb, 0xd0, 0xb5, 0xdf, 0x8d, 0xd7, 0xf8, 0xf7, 0x11, 0x74, 0xae,
-	0x51, 0xd1, 0xaf, 0x66, 0xe7, 0xc9, 0x07, 0x68, 0x15, 0xcb, 0x4b, 0x6a, 0x5b, 0x54, 0x6c, 0xb8,
-	0xf7, 0xe8, 0x40, 0xb7, 0xc1, 0xae, 0xa1, 0x57, 0xad, 0x98, 0x5c, 0x58, 0xf4, 0xde, 0x4d, 0xf3,
-	0x9e, 0xfc, 0x67, 0x6a, 0xed, 0xbe, 0x40, 0xb7, 0x52, 0x00, 0x79, 0xbc, 0xe3, 0x0f, 0x2f, 0xcb,
-	0xbb, 0xb8, 0x7f, 0x68, 0xbd, 0xde, 0x03, 0x7c, 0xcc, 0x44, 0x84, 0xfa, 0xf4, 0xa4, 0xf8, 0xf1,
-	0x95, 0xbb, 0xf1, 0x86, 0x55, 0xd1, 0xbc, 0x38, 0x79, 0xfe, 0xdd, 0x5f, 0x26, 0x2a, 0xde, 0xcc,
-	0x83, 0x28, 0x5b, 0x8f, 0xf8, 0x4a, 0x5d, 0x45, 0x54, 0xc6, 0xf9, 0xc3, 0x62, 0x94, 0xb2, 0xfc,
-	0x23, 0x78, 0x34, 0x3f, 0xd1, 0x7f, 0x07, 0x6f, 0xff, 0x05, 0x00, 0x00, 0xff, 0xff, 0xdd, 0xd9,
-	0x47, 0xd9, 0x40, 0x04, 0x00, 0x00,
+	// 535 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x94, 0x5f, 0x8f, 0xd2, 0x4c,
+	0x14, 0xc6, 0x03, 0xbc, 0xcb, 0x0b, 0x87, 0xe5, 0x4f, 0x07, 0xd8, 0xad, 0x75, 0x8d, 0xa6, 0xd1,
+	0xc4, 0x8d, 0x6e, 0x31, 0x68, 0x62, 0xa2, 0x77, 0x90, 0x68, 0x34, 0xd9, 0xcd, 0xa6, 0x5e, 0x98,
+	0x78, 0x43, 0x86, 0x61, 0xa4, 0x0d, 0x65, 0x5a, 0x67, 0xa6, 0xcb, 0x17, 0xf1, 0xda, 0x2b, 0x3f,
+	0x97, 0x9f, 0xc5, 0x74, 0x3a, 0x85, 0x69, 0x59, 0x2f, 0x48, 0xca, 0x39, 0xbf, 0xf3, 0xe4, 0x99,
+	0xa7, 0x73, 0x0a, 0xd6, 0x96, 0x4a, 0x2c, 0x28, 0xbf, 0x0b, 0x09, 0xf5, 0x12, 0x1e, 0xcb, 0x18,
+	0x9d, 0x44, 0x8c, 0x27, 0xc4, 0x69, 0x27, 0x1b, 0x99, 0x57, 0x9c, 0x36, 0x4f, 0x48, 0xfe, 0xe8,
+	0x5a, 0xd0, 0xff, 0x48, 0xe5, 0x27, 0xf6, 0x3d, 0x9e, 0xfa, 0xf4, 0x47, 0x4a, 0x85, 0x74, 0x7f,
+	0xd7, 0x60, 0x70, 0xa8, 0x89, 0x24, 0x66, 0x82, 0xa2, 0x09, 0xb4, 0x18, 0x4d, 0x25, 0x0f, 0x59,
+	0x6c, 0xd7, 0x9e, 0xd4, 0x9e, 0x77, 0xa6, 0x43, 0x4f, 0xe9, 0x7a, 0x37, 0xba, 0x9c, 0xf1, 0xfe,
+	0x1e, 0x42, 0x97, 0xd0, 0xdc, 0xe1, 0x28, 0xa2, 0xd2, 0xae, 0x2b, 0xdc, 0xd2, 0xf8, 0x57, 0x55,
+	0x54, 0xb0, 0x06, 0xd0, 0x1b, 0x68, 0x47, 0xe1, 0x3a, 0x90, 0x2c, 0x64, 0x6b, 0xbb, 0xa1, 0xe8,
+	0x33, 0x4d, 0x6b, 0x1f, 0x85, 0x0d, 0xff, 0x00, 0xba, 0x7f, 0xea, 0x30, 0x9e, 0x07, 0x98, 0xad,
+	0xe9, 0x2d, 0x16, 0x62, 0x17, 0xf3, 0x95, 0x3e, 0x00, 0xba, 0x02, 0x44, 0x52, 0xce, 0x29, 0x93,
+	0x8b, 0x04, 0x0b, 0x91, 0x04, 0x1c, 0x0b, 0xaa, 0x5c, 0xb7, 0x7d, 0x4b, 0x77, 0x6e, 0xf7, 0x0d,
+	0xf4, 0x0a, 0x46, 0x26, 0x9e, 0x29, 0x2d, 0x96, 0x21, 0x53, 0xbe, 0x4f, 0x7d, 0x64, 0x0c, 0x64,
+	0xad, 0x59, 0xc8, 0xd0, 0x33, 0xe8, 0x31, 0xba, 0x33, 0xc5, 0x1b, 0x4a, 0xbc, 0xcb, 0xe8, 0xce,
+	0x10, 0x7e, 0x09, 0xa8, 0x8c, 0x29, 0xd9, 0xff, 0x94, 0xec, 0xa0, 0x84, 0x66, 0xa2, 0x8f, 0xa1,
+	0x93, 0xe7, 0xb1, 0x60, 0x78, 0x4b, 0xed, 0x13, 0xa5, 0x08, 0x79, 0xe9, 0x06, 0x6f, 0x29, 0x7a,
+	0x07, 0x0f, 0xf6, 0x3e, 0xd3, 0x65, 0x14, 0x12, 0xd3, 0x40, 0x53, 0xe1, 0xe7, 0x85, 0x59, 0xd5,
+	0x37, 0xac, 0x4c, 0x61, 0xac, 0xac, 0x1c, 0xcd, 0xfd, 0xaf, 0xe6, 0x86, 0x99, 0x9b, 0xca, 0x8c,
+	0x6b, 0xc3, 0x59, 0x35, 0xdf, 0xfc, 0x2d, 0xb8, 0x3f, 0x6b, 0x30, 0x9a, 0x07, 0x94, 0x6c, 0xaa,
+	0xc9, 0xbf, 0x00, 0x4b, 0x9f, 0xe1, 0x28, 0xf8, 0x41, 0xde, 0x30, 0x3c, 0x79, 0x30, 0x34, 0xe0,
+	0x4a, 0xec, 0xd6, 0x01, 0x2f, 0x52, 0xaf, 0x04, 0xd4, 0xa8, 0x06, 0xe4, 0xce, 0x60, 0x5c, 0x71,
+	0xa5, 0x2f, 0xef, 0x25, 0x0c, 0xee, 0x70, 0x14, 0xae, 0xaa, 0xae, 0x5a, 0x7e, 0x5f, 0xd5, 0x8d,
+	0x43, 0xf7, 0xe0, 0x74, 0xce, 0xb1, 0x08, 0x8a, 0x65, 0xe8, 0x43, 0x57, 0xff, 0xcf, 0xb5, 0xa6,
+	0xbf, 0xea, 0xd0, 0xb9, 0xa6, 0x12, 0x7f, 0xc9, 0x77, 0x0c, 0xbd, 0x87, 0x56, 0xb1, 0x2c, 0xa8,
+	0x72, 0x6b, 0x8b, 0x8d, 0x72, 0xce, 0x8f, 0xea, 0xda, 0xd8, 0x35, 0xf4, 0xca, 0x11, 0xa3, 0x0b,
+	0x8d, 0xde, 0x7b, 0xb3, 0x9d, 0x47, 0xff, 0xe8, 0x6a, 0xb9, 0xcf, 0xd0, 0x2d, 0x05, 0x80, 0x1e,
+	0xee, 0xf9, 0xe3, 0x97, 0xe5, 0x5c, 0xdc, 0xdf, 0xd4, 0x5a, 0x6f, 0x01, 0x3e, 0xc4, 0x9c, 0x50,
+	0x75, 0x7a, 0x54, 0x2c, 0xbb, 0x99, 0x8d, 0x33, 0x2a, 0x17, 0xf3, 0xc1, 0xd9, 0xd3, 0x6f, 0xee,
+	0x3a, 0x94, 0x41, 0xba, 0xf4, 0x48, 0xbc, 0x9d, 0x24, 0x1b, 0x79, 0x45, 0xb0, 0x08, 0xb2, 0x87,
+	0xd5, 0x24, 0x62, 0xd9, 0x8f, 0x27, 0x64, 0xd9, 0x54, 0x9f, 0x9f, 0xd7, 0x7f, 0x07, 0x00, 0xe5,
+	0x08, 0xd9, 0x73, 0xb0, 0x04, 0x00, 0x00,
 }
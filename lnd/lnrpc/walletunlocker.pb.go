@@ -314,7 +314,13 @@ type UnlockWalletRequest struct {
 	//
 	//wallet_name is optional when the user wants to load a specified wallet other
 	//than the default wallet.db
-	WalletName           string   `protobuf:"bytes,5,opt,name=wallet_name,json=walletName,proto3" json:"wallet_name,omitempty"`
+	WalletName string `protobuf:"bytes,5,opt,name=wallet_name,json=walletName,proto3" json:"wallet_name,omitempty"`
+	//
+	//timeout_seconds is an optional argument which causes the wallet to be
+	//locked again after this many seconds. If zero then the wallet stays
+	//unlocked until it is explicitly locked. A subsequent unlock replaces the
+	//timeout.
+	TimeoutSeconds       int64    `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -380,6 +386,13 @@ func (m *UnlockWalletRequest) GetWalletName() string {
 	return ""
 }
 
+func (m *UnlockWalletRequest) GetTimeoutSeconds() int64 {
+	if m != nil {
+		return m.TimeoutSeconds
+	}
+	return 0
+}
+
 type UnlockWalletResponse struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -423,35 +436,36 @@ func init() {
 func init() { proto.RegisterFile("walletunlocker.proto", fileDescriptor_76e3ed10ed53e4fd) }
 
 var fileDescriptor_76e3ed10ed53e4fd = []byte{
-	// 473 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x54, 0xcf, 0x8b, 0xd3, 0x40,
-	0x14, 0x26, 0xfd, 0xb1, 0x6b, 0x5f, 0x4b, 0x6a, 0xa7, 0xdd, 0x25, 0x1b, 0x0f, 0xc6, 0xa0, 0x6c,
-	0x40, 0xcc, 0x42, 0xbd, 0x78, 0xb5, 0x22, 0x8b, 0x17, 0x91, 0x2c, 0xcb, 0x82, 0x97, 0x32, 0x4d,
-	0x1f, 0x26, 0x34, 0x9d, 0x19, 0x67, 0x52, 0xcb, 0xde, 0x3d, 0xfa, 0xf7, 0xf9, 0xd7, 0x78, 0x90,
-	0xcc, 0xcc, 0xae, 0x69, 0x1b, 0x41, 0x11, 0x3c, 0x04, 0x86, 0xef, 0x7d, 0x6f, 0xf8, 0xbe, 0xef,
-	0xcd, 0x0b, 0x4c, 0xb6, 0xb4, 0x28, 0xb0, 0xdc, 0xb0, 0x82, 0xa7, 0x2b, 0x94, 0xb1, 0x90, 0xbc,
-	0xe4, 0xa4, 0x5b, 0x30, 0x29, 0x52, 0xbf, 0x27, 0x45, 0x6a, 0x90, 0xf0, 0x9b, 0x03, 0xee, 0x25,
-	0xb2, 0x2b, 0xc4, 0x65, 0x82, 0x9f, 0x37, 0xa8, 0x4a, 0x72, 0x0e, 0x43, 0x85, 0xb8, 0x9c, 0x0b,
-	0xaa, 0x94, 0xc8, 0x24, 0x55, 0xe8, 0x39, 0x81, 0x13, 0xf5, 0x12, 0xb7, 0x82, 0x3f, 0xdc, 0xa3,
-	0x24, 0x86, 0xf1, 0x1e, 0x71, 0xbe, 0xc8, 0x99, 0xd7, 0x0a, 0x9c, 0x68, 0x90, 0x8c, 0x76, 0xc9,
-	0xb3, 0x9c, 0x91, 0x27, 0x30, 0xd0, 0x7c, 0x64, 0xa5, 0xe4, 0xe2, 0xd6, 0x6b, 0x6b, 0x62, 0xbf,
-	0xc2, 0xde, 0x1a, 0x28, 0x7c, 0x06, 0xc3, 0x7b, 0x35, 0x4a, 0x70, 0xa6, 0x90, 0x10, 0xe8, 0x54,
-	0x0c, 0xcf, 0x09, 0xda, 0x51, 0x2f, 0xd1, 0xe7, 0xf0, 0x47, 0x0b, 0x46, 0xef, 0x58, 0x5e, 0xde,
-	0x68, 0x93, 0x77, 0xc2, 0x9f, 0xc3, 0xc8, 0xb8, 0x3e, 0x94, 0xfe, 0xd0, 0x14, 0x6a, 0xe2, 0xa7,
-	0x70, 0x72, 0x40, 0xae, 0xc9, 0x1f, 0xef, 0x37, 0x54, 0x06, 0x1e, 0x43, 0xdf, 0xf6, 0x68, 0x45,
-	0x6d, 0xad, 0x08, 0x0c, 0x54, 0x69, 0x6e, 0x8a, 0xae, 0xf3, 0x37, 0xd1, 0x75, 0x7f, 0x17, 0xdd,
-	0x39, 0x0c, 0x25, 0xa6, 0xfc, 0x0b, 0xca, 0xdb, 0xf9, 0x36, 0x67, 0x4b, 0xbe, 0xf5, 0x8e, 0x02,
-	0x27, 0xea, 0x26, 0xee, 0x1d, 0x7c, 0xa3, 0x51, 0x32, 0x83, 0x61, 0x9a, 0x51, 0xc6, 0xb0, 0x98,
-	0x2f, 0x68, 0xba, 0xda, 0x08, 0xe5, 0x1d, 0x07, 0x4e, 0xd4, 0x9f, 0x9e, 0xc5, 0x7a, 0xf6, 0xf1,
-	0x9b, 0x8c, 0xb2, 0x99, 0xae, 0x5c, 0x31, 0x2a, 0x54, 0xc6, 0xcb, 0xc4, 0xb5, 0x1d, 0x06, 0x56,
-	0x35, 0x9b, 0x8c, 0xae, 0xd1, 0x7b, 0xa0, 0x1d, 0x58, 0x9b, 0xef, 0xe9, 0x1a, 0xc3, 0x09, 0x90,
-	0x7a, 0xfa, 0x66, 0x50, 0xe1, 0xd7, 0x16, 0x8c, 0xaf, 0xf5, 0x7b, 0xfb, 0xcf, 0x63, 0x69, 0x08,
-	0xa7, 0xfd, 0xa7, 0xe1, 0x74, 0xfe, 0x31, 0x9c, 0xee, 0x41, 0x38, 0xa7, 0x30, 0xd9, 0x4d, 0xc1,
-	0xc4, 0x33, 0xfd, 0xee, 0x80, 0x6b, 0xa0, 0x6b, 0xbb, 0x94, 0xe4, 0x15, 0x1c, 0xdb, 0xd7, 0x4e,
-	0x4e, 0xac, 0x82, 0xdd, 0x5d, 0xf4, 0x4f, 0xf7, 0x61, 0xbb, 0x14, 0xaf, 0x01, 0x7e, 0x4d, 0x80,
-	0x78, 0x96, 0x75, 0xb0, 0x12, 0xfe, 0x59, 0x43, 0xc5, 0x5e, 0x71, 0x09, 0x83, 0xba, 0x4e, 0xe2,
-	0x5b, 0x6a, 0xc3, 0x08, 0xfd, 0x47, 0x8d, 0x35, 0x73, 0xd1, 0xec, 0xe9, 0xc7, 0xf0, 0x53, 0x5e,
-	0x66, 0x9b, 0x45, 0x9c, 0xf2, 0xf5, 0x85, 0x58, 0x95, 0x2f, 0x52, 0xaa, 0xb2, 0xea, 0xb0, 0xbc,
-	0x28, 0x58, 0xf5, 0x49, 0x91, 0x2e, 0x8e, 0xf4, 0xff, 0xe6, 0xe5, 0xcf, 0x00, 0x00, 0x00, 0xff,
-	0xff, 0xfa, 0xc4, 0x92, 0x0a, 0x99, 0x04, 0x00, 0x00,
+	// 492 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x94, 0xd1, 0x8a, 0xd3, 0x40,
+	0x14, 0x86, 0x49, 0xd3, 0xee, 0xda, 0xd3, 0x92, 0xda, 0x69, 0x77, 0xc9, 0xc6, 0x0b, 0x63, 0x50,
+	0x36, 0x20, 0x66, 0xa1, 0xde, 0x78, 0x6b, 0x45, 0x16, 0x6f, 0x44, 0x52, 0x96, 0x05, 0x6f, 0xca,
+	0x74, 0x32, 0x98, 0xd0, 0x74, 0x66, 0xcc, 0x24, 0x96, 0x7d, 0x07, 0x5f, 0xc5, 0xd7, 0xf1, 0x69,
+	0xbc, 0x90, 0xcc, 0xcc, 0xee, 0xa6, 0x6d, 0x04, 0x45, 0xd8, 0x8b, 0x42, 0xf8, 0xce, 0x3f, 0xc3,
+	0x7f, 0xfe, 0x33, 0xa7, 0x30, 0xdd, 0xe2, 0x3c, 0xa7, 0x65, 0xc5, 0x72, 0x4e, 0xd6, 0xb4, 0x88,
+	0x44, 0xc1, 0x4b, 0x8e, 0x7a, 0x39, 0x2b, 0x04, 0xf1, 0xfa, 0x85, 0x20, 0x9a, 0x04, 0xdf, 0x2d,
+	0x70, 0x2e, 0x29, 0x5b, 0x50, 0x9a, 0xc4, 0xf4, 0x6b, 0x45, 0x65, 0x89, 0xce, 0x61, 0x24, 0x29,
+	0x4d, 0x96, 0x02, 0x4b, 0x29, 0xd2, 0x02, 0x4b, 0xea, 0x5a, 0xbe, 0x15, 0xf6, 0x63, 0xa7, 0xc6,
+	0x9f, 0xee, 0x28, 0x8a, 0x60, 0xb2, 0x27, 0x5c, 0xae, 0x32, 0xe6, 0x76, 0x7c, 0x2b, 0x1c, 0xc6,
+	0xe3, 0x5d, 0xf1, 0x3c, 0x63, 0xe8, 0x19, 0x0c, 0x95, 0x9e, 0xb2, 0xb2, 0xe0, 0xe2, 0xc6, 0xb5,
+	0x95, 0x70, 0x50, 0xb3, 0xf7, 0x1a, 0x05, 0x2f, 0x60, 0x74, 0xe7, 0x46, 0x0a, 0xce, 0x24, 0x45,
+	0x08, 0xba, 0xb5, 0xc2, 0xb5, 0x7c, 0x3b, 0xec, 0xc7, 0xea, 0x3b, 0xf8, 0xd5, 0x81, 0xf1, 0x07,
+	0x96, 0x95, 0xd7, 0xaa, 0xc9, 0x5b, 0xe3, 0x2f, 0x61, 0xac, 0xbb, 0x3e, 0xb4, 0xfe, 0x58, 0x17,
+	0x1a, 0xe6, 0x67, 0x70, 0x72, 0x20, 0x6e, 0xd8, 0x9f, 0xec, 0x1f, 0xa8, 0x1b, 0x78, 0x0a, 0x03,
+	0x73, 0x46, 0x39, 0xb2, 0x95, 0x23, 0xd0, 0xa8, 0xf6, 0xdc, 0x16, 0x5d, 0xf7, 0x5f, 0xa2, 0xeb,
+	0xfd, 0x29, 0xba, 0x73, 0x18, 0x15, 0x94, 0xf0, 0x6f, 0xb4, 0xb8, 0x59, 0x6e, 0x33, 0x96, 0xf0,
+	0xad, 0x7b, 0xe4, 0x5b, 0x61, 0x2f, 0x76, 0x6e, 0xf1, 0xb5, 0xa2, 0x68, 0x0e, 0x23, 0x92, 0x62,
+	0xc6, 0x68, 0xbe, 0x5c, 0x61, 0xb2, 0xae, 0x84, 0x74, 0x8f, 0x7d, 0x2b, 0x1c, 0xcc, 0xce, 0x22,
+	0x35, 0xfb, 0xe8, 0x5d, 0x8a, 0xd9, 0x5c, 0x55, 0x16, 0x0c, 0x0b, 0x99, 0xf2, 0x32, 0x76, 0xcc,
+	0x09, 0x8d, 0x65, 0xa3, 0x4d, 0x86, 0x37, 0xd4, 0x7d, 0xe4, 0x5b, 0xf7, 0x6d, 0x7e, 0xc4, 0x1b,
+	0x1a, 0x4c, 0x01, 0x35, 0xd3, 0xd7, 0x83, 0x0a, 0x7e, 0x74, 0x60, 0x72, 0xa5, 0xde, 0xdb, 0x03,
+	0x8f, 0xa5, 0x25, 0x1c, 0xfb, 0x6f, 0xc3, 0xe9, 0xfe, 0x67, 0x38, 0xbd, 0xfd, 0x70, 0x6a, 0x37,
+	0x65, 0xb6, 0xa1, 0xbc, 0xaa, 0x5f, 0x09, 0xe1, 0x2c, 0x91, 0x6a, 0x54, 0x76, 0xec, 0x18, 0xbc,
+	0xd0, 0x34, 0x38, 0x85, 0xe9, 0x6e, 0x5c, 0x3a, 0xc7, 0xd9, 0x4f, 0x0b, 0x1c, 0x8d, 0xae, 0xcc,
+	0xf6, 0xa2, 0x37, 0x70, 0x6c, 0xd6, 0x02, 0x9d, 0x18, 0xab, 0xbb, 0x4b, 0xeb, 0x9d, 0xee, 0x63,
+	0xb3, 0x3d, 0x6f, 0x01, 0xee, 0x47, 0x85, 0x5c, 0xa3, 0x3a, 0xd8, 0x1d, 0xef, 0xac, 0xa5, 0x62,
+	0xae, 0xb8, 0x84, 0x61, 0xd3, 0x27, 0xf2, 0x8c, 0xb4, 0x65, 0xd6, 0xde, 0x93, 0xd6, 0x9a, 0xbe,
+	0x68, 0xfe, 0xfc, 0x73, 0xf0, 0x25, 0x2b, 0xd3, 0x6a, 0x15, 0x11, 0xbe, 0xb9, 0x10, 0xeb, 0xf2,
+	0x15, 0xc1, 0x32, 0xad, 0x3f, 0x92, 0x8b, 0x9c, 0xd5, 0xbf, 0x42, 0x90, 0xd5, 0x91, 0xfa, 0x63,
+	0x7a, 0xfd, 0x7b, 0x00, 0x0c, 0x63, 0x9a, 0xc9, 0xc2, 0x04, 0x00, 0x00,
 }
@@ -261,13 +261,24 @@ func (m *MetaService) ChangePassword0(ctx context.Context,
 	} else {
 		if len(in.NewPassphrase) > 0 {
 			newWalletPassphrase = []byte(in.NewPassphrase)
+		} else if len(in.NewPublicPassphrase) > 0 {
+			// Only the public passphrase is being changed.
+			newWalletPassphrase = walletPassphrase
 		} else {
 			newWalletPassphrase = []byte(lnwallet.DefaultPrivatePassphrase)
 		}
 	}
 
+	//	fetch public passphrases from request, the public passphrase is left
+	//	unchanged unless a new one is specified
 	publicPw := []byte(wallet.InsecurePubPassphrase)
-	newPubPw := []byte(wallet.InsecurePubPassphrase)
+	if len(in.CurrentPublicPassphrase) > 0 {
+		publicPw = []byte(in.CurrentPublicPassphrase)
+	}
+	newPubPw := publicPw
+	if len(in.NewPublicPassphrase) > 0 {
+		newPubPw = []byte(in.NewPublicPassphrase)
+	}
 
 	walletFile := m.walletFile
 	if m.Wallet == nil || m.Wallet.Locked() {
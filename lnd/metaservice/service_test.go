@@ -7,6 +7,7 @@
 package metaservice
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"io/ioutil"
@@ -282,6 +283,98 @@ func TestChangeWalletPasswordNewRootkey(t *testing.T) {
 	util.RequireNoErr(t, errr)
 }
 
+// Test changing just the private, just the public and both passphrases, and
+// that the wallet reopens with the new credentials afterwards.
+func TestChangeWalletPublicPassphrase(t *testing.T) {
+	t.Parallel()
+
+	log.Debugf(">>>>> running TestChangeWalletPublicPassphrase()")
+
+	defaultPub := []byte(wallet.InsecurePubPassphrase)
+	newPriv := []byte("hunter2???")
+	newPub := []byte("new-public")
+
+	tests := []struct {
+		name     string
+		req      *lnrpc.ChangePasswordRequest
+		wantErr  bool
+		wantPub  []byte
+		wantPriv []byte
+	}{{
+		name: "private only",
+		req: &lnrpc.ChangePasswordRequest{
+			CurrentPasswordBin: testPassword,
+			NewPassphraseBin:   newPriv,
+		},
+		wantPub:  defaultPub,
+		wantPriv: newPriv,
+	}, {
+		name: "public only",
+		req: &lnrpc.ChangePasswordRequest{
+			CurrentPasswordBin:  testPassword,
+			NewPublicPassphrase: string(newPub),
+		},
+		wantPub:  newPub,
+		wantPriv: testPassword,
+	}, {
+		name: "both",
+		req: &lnrpc.ChangePasswordRequest{
+			CurrentPasswordBin:  testPassword,
+			NewPassphraseBin:    newPriv,
+			NewPublicPassphrase: string(newPub),
+		},
+		wantPub:  newPub,
+		wantPriv: newPriv,
+	}, {
+		name: "wrong private passphrase",
+		req: &lnrpc.ChangePasswordRequest{
+			CurrentPasswordBin:  []byte("wrong-ofc"),
+			NewPassphraseBin:    newPriv,
+			NewPublicPassphrase: string(newPub),
+		},
+		wantErr:  true,
+		wantPub:  defaultPub,
+		wantPriv: testPassword,
+	}}
+
+	for _, test := range tests {
+		testDir, errr := ioutil.TempDir("", "changepub")
+		require.NoError(t, errr)
+		defer func() {
+			_ = os.RemoveAll(testDir)
+		}()
+
+		loader := createTestWallet(t, testDir, testNetParams)
+		util.RequireNoErr(t, loader.UnloadWallet())
+
+		metaService := NewMetaService(nil)
+		metaService.netParams = testNetParams
+		metaService.walletPath = btcwallet.NetworkDir(testDir, testNetParams)
+		metaService.walletFile = testWalletFilename
+
+		_, err := metaService.ChangePassword(context.Background(), test.req)
+		if test.wantErr {
+			require.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+		}
+
+		// The wallet opens with the expected public passphrase and
+		// unlocks with the expected private passphrase.
+		loader = wallet.NewLoader(testNetParams, metaService.walletPath,
+			testWalletFilename, true, 0)
+		w, rerr := loader.OpenExistingWallet(test.wantPub, false)
+		util.RequireNoErr(t, rerr, test.name)
+		util.RequireNoErr(t, w.Unlock(test.wantPriv, nil), test.name)
+		util.RequireNoErr(t, loader.UnloadWallet(), test.name)
+
+		if !bytes.Equal(test.wantPub, defaultPub) {
+			_, rerr = loader.OpenExistingWallet(defaultPub, false)
+			require.Error(t, er.Native(rerr), test.name)
+		}
+	}
+}
+
 //	execute a password change
 func changePassword(metaService *MetaService, macTestDir string, req *lnrpc.ChangePasswordRequest) (*lnrpc.ChangePasswordResponse, er.R) {
 
@@ -11126,6 +11126,24 @@ func mklnrpc_ChangePasswordRequest() Type {
                 },
                 Type: mkstring(),
             },
+            {
+                Name: "current_public_passphrase",
+                Description: []string{
+                    "current_public_passphrase is the current public passphrase which is used to",
+                    "open the wallet database. If not specified then the default public",
+                    "passphrase is used.",
+                },
+                Type: mkstring(),
+            },
+            {
+                Name: "new_public_passphrase",
+                Description: []string{
+                    "new_public_passphrase, if specified, replaces the public passphrase. It is",
+                    "changed atomically together with the private passphrase, if new_passphrase",
+                    "is not specified then the private passphrase is left unchanged.",
+                },
+                Type: mkstring(),
+            },
         },
     }
 }
@@ -11394,6 +11412,16 @@ func mklnrpc_UnlockWalletRequest() Type {
                 },
                 Type: mkstring(),
             },
+            {
+                Name: "timeout_seconds",
+                Description: []string{
+                    "timeout_seconds is an optional argument which causes the wallet to be",
+                    "locked again after this many seconds. If zero then the wallet stays",
+                    "unlocked until it is explicitly locked. A subsequent unlock replaces the",
+                    "timeout.",
+                },
+                Type: mkint64(),
+            },
         },
     }
 }
@@ -6949,7 +6949,7 @@ func (r *rpcServer) CreateTransaction(ctx context.Context, req *lnrpc.CreateTran
 
 	var transaction []byte
 	if req.ElectrumFormat {
-		b := new(bytes.Buffer)
+		b := bytes.NewBuffer(make([]byte, 0, tx.Tx.SerializeSizeEptf()))
 		if err := tx.Tx.BtcEncode(b, 0, wire.ForceEptfEncoding); err != nil {
 			return nil, er.Native(err)
 		}
@@ -3,10 +3,12 @@ package walletunlocker
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/chaincfg"
 	"github.com/pkt-cash/pktd/lnd/chanbackup"
+	"github.com/pkt-cash/pktd/lnd/clock"
 	"github.com/pkt-cash/pktd/lnd/lnrpc"
 	"github.com/pkt-cash/pktd/lnd/lnwallet/btcwallet"
 	"github.com/pkt-cash/pktd/pktwallet/wallet"
@@ -129,6 +131,10 @@ type UnlockerService struct {
 
 	walletFile string
 	walletPath string
+
+	// Clock is used to schedule relocking the wallet when UnlockWallet is
+	// called with a timeout.
+	Clock clock.Clock
 }
 
 var _ lnrpc.WalletUnlockerServer = (*UnlockerService)(nil)
@@ -150,6 +156,7 @@ func New(chainDir string, params *chaincfg.Params, noFreelistSync bool,
 		macaroonFiles:   macaroonFiles,
 		walletFile:      walletFilename,
 		walletPath:      walletPath,
+		Clock:           clock.NewDefaultClock(),
 	}
 }
 
@@ -393,6 +400,12 @@ func (u *UnlockerService) UnlockWallet0(ctx context.Context,
 
 	recoveryWindow := uint32(in.RecoveryWindow)
 
+	if in.TimeoutSeconds < 0 {
+		return nil, er.Errorf("timeout_seconds must not be negative, got [%d]",
+			in.TimeoutSeconds)
+	}
+	relockTimeout := time.Duration(in.TimeoutSeconds) * time.Second
+
 	netDir := btcwallet.NetworkDir(u.chainDir, u.netParams)
 	if u.walletPath != "" {
 		netDir = u.walletPath
@@ -421,13 +434,19 @@ func (u *UnlockerService) UnlockWallet0(ctx context.Context,
 		// password was incorrect.
 		return nil, err
 	}
+	// The wallet locks itself again once the timeout expires.
+	var lockAfter <-chan time.Time
+	if relockTimeout > 0 {
+		lockAfter = u.Clock.TickAfter(relockTimeout)
+	}
 	//Also test against private password
-	err = unlockedWallet.Unlock(walletPassphrase, nil)
+	err = unlockedWallet.Unlock(walletPassphrase, lockAfter)
 	if err != nil {
 		//unload wallet so future unlock calls can be processed
 		loader.UnloadWallet()
 		return nil, err
 	}
+
 	// We successfully opened the wallet and pass the instance back to
 	// avoid it needing to be unlocked again.
 	walletUnlockMsg := &WalletUnlockMsg{
@@ -13,6 +13,7 @@ import (
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/btcutil/util"
 	"github.com/pkt-cash/pktd/chaincfg"
+	"github.com/pkt-cash/pktd/lnd/clock"
 	"github.com/pkt-cash/pktd/lnd/lnrpc"
 	"github.com/pkt-cash/pktd/lnd/lnwallet/btcwallet"
 	"github.com/pkt-cash/pktd/lnd/walletunlocker"
@@ -383,3 +384,84 @@ func TestUnlockWallet(t *testing.T) {
 		t.Fatalf("password not received")
 	}
 }
+
+// unlockWithTimeout calls UnlockWallet on the service with a relock timeout
+// and returns the unlocked wallet which was sent over the unlock channel.
+func unlockWithTimeout(t *testing.T, service *walletunlocker.UnlockerService,
+	timeoutSeconds int64) *walletunlocker.WalletUnlockMsg {
+
+	req := &lnrpc.UnlockWalletRequest{
+		WalletPassphraseBin: testPassword,
+		TimeoutSeconds:      timeoutSeconds,
+	}
+	errChan := make(chan er.R, 1)
+	go func() {
+		_, err := service.UnlockWallet(context.Background(), req)
+		if err != nil {
+			errChan <- er.E(err)
+		}
+	}()
+
+	select {
+	case err := <-errChan:
+		t.Fatalf("UnlockWallet call failed: %v", err)
+
+	case unlockMsg := <-service.UnlockMsgs:
+		service.MacResponseChan <- testMac
+		return unlockMsg
+
+	case <-time.After(defaultTestTimeout):
+		t.Fatalf("password not received")
+	}
+	return nil
+}
+
+// TestUnlockWalletTimeout checks that the wallet is locked again once the
+// unlock timeout expires and that unlocking again resets the timer.
+func TestUnlockWalletTimeout(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testunlocktimeout")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+	createTestWallet(t, testDir, testNetParams)
+
+	start := time.Unix(1600000000, 0)
+	testClock := clock.NewTestClock(start)
+	service := walletunlocker.New(testDir, testNetParams, true, nil, "", testWalletFilename)
+	service.Clock = testClock
+
+	_, err := service.UnlockWallet(context.Background(), &lnrpc.UnlockWalletRequest{
+		WalletPassphraseBin: testPassword,
+		TimeoutSeconds:      -1,
+	})
+	require.Error(t, err)
+
+	// The wallet stays unlocked until the timeout expires.
+	msg := unlockWithTimeout(t, service, 60)
+	require.False(t, msg.Wallet.Locked())
+	testClock.SetTime(start.Add(59 * time.Second))
+	require.False(t, msg.Wallet.Locked())
+	testClock.SetTime(start.Add(60 * time.Second))
+	require.Eventually(t, msg.Wallet.Locked, defaultTestTimeout, 10*time.Millisecond)
+	util.RequireNoErr(t, msg.UnloadWallet())
+
+	// Unlocking again before the timeout replaces the previous timer.
+	msg = unlockWithTimeout(t, service, 60)
+	testClock.SetTime(start.Add(90 * time.Second))
+	util.RequireNoErr(t, msg.UnloadWallet())
+	msg = unlockWithTimeout(t, service, 60)
+	testClock.SetTime(start.Add(121 * time.Second))
+	require.False(t, msg.Wallet.Locked())
+	testClock.SetTime(start.Add(150 * time.Second))
+	require.Eventually(t, msg.Wallet.Locked, defaultTestTimeout, 10*time.Millisecond)
+
+	// Without a timeout the wallet stays unlocked.
+	util.RequireNoErr(t, msg.UnloadWallet())
+	msg = unlockWithTimeout(t, service, 0)
+	testClock.SetTime(start.Add(24 * time.Hour))
+	require.False(t, msg.Wallet.Locked())
+	util.RequireNoErr(t, msg.UnloadWallet())
+}
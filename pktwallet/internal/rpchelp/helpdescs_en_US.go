@@ -59,6 +59,7 @@ var helpDescsEnUS = map[string]string{
 	"addressvoteinfo-vote_txid":                "The transaction ID of this vote transaction",
 	"addressvoteinfo-vote_for":                 "The address who is being voted for",
 	"addressvoteinfo-is_candidate":             "True if this address has indicated it's desire to candidate for Network Steward",
+	"addressvoteinfo-withdraws_candidacy":      "True if this address has explicitly withdrawn it's candidacy for Network Steward",
 
 	"getwalletseed--synopsis": "Get the wallet seed words for this wallet",
 	"getwalletseed--result0":  "The seed words used, along with the wallet passphrase, to create the wallet",
@@ -334,13 +335,14 @@ var helpDescsEnUS = map[string]string{
 		"Unlike normal transactions, vote transactions CANNOT contain more than one input\n" +
 		"address. This address is considered to be the voter, and the vote is weighted based\n" +
 		"on the number of coins this address has.",
-	"sendvote-fromaddress": "The address to use for casting the vote",
-	"sendvote-votefor":     "The address to vote for",
-	"sendvote-iscandidate": "If true, then this wallet will candidate to become Network Steward",
-	"sendvote-minconf":     "Minimum number of block confirmations required before a transaction output is eligible to be spent",
-	"sendvote-maxinputs":   "Maximum number of transaction inputs that are allowed",
-	"sendvote-minheight":   "Do not source inputs from that are newer than this block number",
-	"sendvote--result0":    "The transaction hash of the sent vote transaction",
+	"sendvote-fromaddress":       "The address to use for casting the vote",
+	"sendvote-votefor":           "The address to vote for",
+	"sendvote-iscandidate":       "If true, then this wallet will candidate to become Network Steward",
+	"sendvote-withdrawcandidacy": "If true, then this wallet withdraws it's candidacy, cannot be combined with iscandidate",
+	"sendvote-minconf":           "Minimum number of block confirmations required before a transaction output is eligible to be spent",
+	"sendvote-maxinputs":         "Maximum number of transaction inputs that are allowed",
+	"sendvote-minheight":         "Do not source inputs from that are newer than this block number",
+	"sendvote--result0":          "The transaction hash of the sent vote transaction",
 
 	// SetTxFeeCmd help.
 	"settxfee--synopsis": "Modify the increment used each time more fee is required for an authored transaction.",
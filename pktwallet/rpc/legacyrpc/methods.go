@@ -17,6 +17,7 @@ import (
 	jsoniter "github.com/json-iterator/go"
 
 	"github.com/pkt-cash/pktd/blockchain"
+	"github.com/pkt-cash/pktd/blockchain/votecompute/votes"
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/connmgr/banmgr"
 	"github.com/pkt-cash/pktd/pktlog/log"
@@ -1335,12 +1336,8 @@ func prepareTxReq(
 	return &req, nil
 }
 
-func mkVoteScript(willingCandidate bool, voteFor []byte) ([]byte, er.R) {
-	buf := make([]byte, len(voteFor)+1)
-	if willingCandidate {
-		buf[0] = 0x01
-	}
-	copy(buf[1:], voteFor)
+func mkVoteScript(op byte, voteFor []byte) ([]byte, er.R) {
+	buf := votes.DefaultNamespace.EncodeVote(op, voteFor)
 	return scriptbuilder.NewScriptBuilder().AddOp(opcode.OP_RETURN).AddData(buf).Script()
 }
 
@@ -1375,6 +1372,16 @@ func sendVote(icmd interface{}, w *wallet.Wallet) (interface{}, er.R) {
 	if req.MaxInputs != nil {
 		maxInputs = int(*req.MaxInputs)
 	}
+	op := votes.VOTE
+	if req.IsCandidate != nil && *req.IsCandidate {
+		op = votes.CANDIDATE
+	}
+	if req.WithdrawCandidacy != nil && *req.WithdrawCandidacy {
+		if op == votes.CANDIDATE {
+			return nil, er.New("iscandidate and withdrawcandidacy cannot both be set")
+		}
+		op = votes.WITHDRAW
+	}
 	if voteFor, err := btcutil.DecodeAddress(req.VoteFor, w.ChainParams()); err != nil {
 		return nil, err
 	} else if voteForScript, err := txscript.PayToAddrScriptWithVote(voteFor, nil, nil); err != nil {
@@ -1383,7 +1390,7 @@ func sendVote(icmd interface{}, w *wallet.Wallet) (interface{}, er.R) {
 		&[]string{req.FromAddress}, minConf, txrules.DefaultRelayFeePerKb,
 		wallet.SendModeBcasted, nil, minHeight, maxInputs); err != nil {
 		return nil, err
-	} else if vscr, err := mkVoteScript(req.IsCandidate != nil && *req.IsCandidate, voteForScript); err != nil {
+	} else if vscr, err := mkVoteScript(op, voteForScript); err != nil {
 		return nil, err
 	} else {
 		txr.Outputs = []*wire.TxOut{{Value: 0, PkScript: vscr}}
@@ -1501,7 +1508,7 @@ func createTransaction(icmd interface{}, w *wallet.Wallet) (interface{}, er.R) {
 	}
 
 	if cmd.ElectrumFormat != nil && *cmd.ElectrumFormat {
-		b := new(bytes.Buffer)
+		b := bytes.NewBuffer(make([]byte, 0, tx.Tx.SerializeSizeEptf()))
 		if err := tx.Tx.BtcEncode(b, 0, wire.ForceEptfEncoding); err != nil {
 			return nil, err
 		}
@@ -7,7 +7,7 @@ func helpDescsEnUS() map[string]string {
 		"addmultisigaddress":      "addmultisigaddress nrequired [\"key\",...]\n\nGenerates and imports a multisig address and redeeming script to the 'imported' account.\n\nArguments:\n1. nrequired (numeric, required)         The number of signatures required to redeem outputs paid to this address\n2. keys      (array of string, required) Pubkeys and/or pay-to-pubkey-hash addresses to partially control the multisig address\n\nResult:\n\"value\" (string) The imported pay-to-script-hash address\n",
 		"createmultisig":          "createmultisig nrequired [\"key\",...]\n\nGenerate a multisig address and redeem script.\n\nArguments:\n1. nrequired (numeric, required)         The number of signatures required to redeem outputs paid to this address\n2. keys      (array of string, required) Pubkeys and/or pay-to-pubkey-hash addresses to partially control the multisig address\n\nResult:\n{\n \"address\": \"value\",      (string) The generated pay-to-script-hash address\n \"redeemScript\": \"value\", (string) The script required to redeem outputs paid to the multisig address\n}                         \n",
 		"createtransaction":       "createtransaction \"toaddress\" amount ([\"fromaddress\",...] electrumformat \"changeaddress\" inputminheight minconf=1 vote maxinputs \"autolock\" nosign)\n\nCreate a transaction but do not send it to the chain\n\nArguments:\n1.  toaddress      (string, required)             The recipient to send the coins to\n2.  amount         (numeric, required)            The amount of coins to send\n3.  fromaddresses  (array of string, optional)    Addresses to use for selecting coins to spend\n4.  electrumformat (boolean, optional)            If true, then the transaction result will be output in electrum incomplete transaction format, useful for signing later\n5.  changeaddress  (string, optional)             Return extra coins to this address, if unspecified then one will be created\n6.  inputminheight (numeric, optional)            The minimum block height to take inputs from (default: 0)\n7.  minconf        (numeric, optional, default=1) Do not spend any outputs which don't have at least this number of confirmations (default 1)\n8.  vote           (boolean, optional)            True if you wish for this transaction to contain a network steward vote\n9.  maxinputs      (numeric, optional)            Maximum number of transaction inputs that are allowed\n10. autolock       (string, optional)             If specified, all txouts spent for this transaction will be locked under this name\n11. nosign         (boolean, optional)            If specified, create an *unsigned* transaction\n\nResult:\n\"value\" (string) The hex encoded transaction result\n",
-		"getaddressbalances":      "getaddressbalances (minconf=1 showzerobalance)\n\nGet balances for each address\n\nArguments:\n1. minconf         (numeric, optional, default=1) Minimum number of confirmations for coins to be considered received\n2. showzerobalance (boolean, optional)            If true then addresses which have been created but carry zero balance will be included\n\nResult:\n[{\n \"address\": \"value\",             (string)  The address which has this balance\n \"total\": n.nnn,                 (numeric) Total balance\n \"stotal\": \"value\",              (string)  Total balance (atomic units as base 10 string)\n \"spendable\": n.nnn,             (numeric) Balance which is currently spendable\n \"sspendable\": \"value\",          (string)  Balance which is currently spendable (atomic units as base 10 string)\n \"immaturereward\": n.nnn,        (numeric) Mined coins which have not yet matured\n \"simmaturereward\": \"value\",     (string)  Mined coins which have not yet matured (atomic units as base 10 string)\n \"unconfirmed\": n.nnn,           (numeric) Unconfirmed balance\n \"sunconfirmed\": \"value\",        (string)  Unconfirmed balance (atomic units as base 10 string)\n \"outputcount\": n,               (numeric) The number of transaction outputs which make up the balance\n \"vote\": {                       (object)  If the address has a valid vote on record, the vote\n  \"is_candidate\": true|false,    (boolean) True if this address has indicated it's desire to candidate for Network Steward\n  \"vote_for\": \"value\",           (string)  The address who is being voted for\n  \"vote_txid\": \"value\",          (string)  The transaction ID of this vote transaction\n  \"vote_block\": n,               (numeric) The block number in which this vote was cast\n  \"expiration_block\": n,         (numeric) The block number at which this vote will expire, if not renewed\n  \"estimated_expiration_sec\": n, (numeric) The time when we estimate the vote will expire, based on block time targets. Seconds since the epoch.\n },                                        \n},...]\n",
+		"getaddressbalances":      "getaddressbalances (minconf=1 showzerobalance)\n\nGet balances for each address\n\nArguments:\n1. minconf         (numeric, optional, default=1) Minimum number of confirmations for coins to be considered received\n2. showzerobalance (boolean, optional)            If true then addresses which have been created but carry zero balance will be included\n\nResult:\n[{\n \"address\": \"value\",                 (string)  The address which has this balance\n \"total\": n.nnn,                     (numeric) Total balance\n \"stotal\": \"value\",                  (string)  Total balance (atomic units as base 10 string)\n \"spendable\": n.nnn,                 (numeric) Balance which is currently spendable\n \"sspendable\": \"value\",              (string)  Balance which is currently spendable (atomic units as base 10 string)\n \"immaturereward\": n.nnn,            (numeric) Mined coins which have not yet matured\n \"simmaturereward\": \"value\",         (string)  Mined coins which have not yet matured (atomic units as base 10 string)\n \"unconfirmed\": n.nnn,               (numeric) Unconfirmed balance\n \"sunconfirmed\": \"value\",            (string)  Unconfirmed balance (atomic units as base 10 string)\n \"outputcount\": n,                   (numeric) The number of transaction outputs which make up the balance\n \"vote\": {                           (object)  If the address has a valid vote on record, the vote\n  \"is_candidate\": true|false,        (boolean) True if this address has indicated it's desire to candidate for Network Steward\n  \"withdraws_candidacy\": true|false, (boolean) True if this address has explicitly withdrawn it's candidacy for Network Steward\n  \"vote_for\": \"value\",               (string)  The address who is being voted for\n  \"vote_txid\": \"value\",              (string)  The transaction ID of this vote transaction\n  \"vote_block\": n,                   (numeric) The block number in which this vote was cast\n  \"expiration_block\": n,             (numeric) The block number at which this vote will expire, if not renewed\n  \"estimated_expiration_sec\": n,     (numeric) The time when we estimate the vote will expire, based on block time targets. Seconds since the epoch.\n },                                            \n},...]\n",
 		"setnetworkstewardvote":   "setnetworkstewardvote (\"votefor\" \"voteagainst\")\n\nConfigure the wallet to vote for a network steward when making payments (note: payments to segwit addresses cannot vote)\n\nArguments:\n1. votefor     (string, optional) The address to vote for (in the event of an election, this is the address who should win)\n2. voteagainst (string, optional) The address to vote against (if this is the current NS then this will cause a vote for an election)\n\nResult:\n{\n} \n",
 		"getnetworkstewardvote":   "getnetworkstewardvote\n\nFind out how the wallet is currently configured to vote in a network steward election\n\nArguments:\nNone\n\nResult:\n{\n \"votefor\": \"value\",     (string) The address which your wallet is currently voting for\n \"voteagainst\": \"value\", (string) The address which your wallet is currently voting against\n}                        \n",
 		"resync":                  "resync (fromheight toheight [\"address\",...] dropdb)\n\nRe-synchronize the wallet to the chain, scan from the first block to find any missing coins\n\nArguments:\n1. fromheight (numeric, optional)         Start re-syncing to the chain from specified height, default or -1 will use the height of the chain when the wallet was created\n2. toheight   (numeric, optional)         Stop resyncing when this height is reached, default or -1 will use the tip of the chain\n3. addresses  (array of string, optional) If specified, the wallet will ONLY scan the chain for these addresses, not others. If dropdb is specified then it will scan all addresses including these\n4. dropdb     (boolean, optional)         Clean most of the data out of the wallet transaction store, this is not a real resync, it just drops the wallet and then lets it begin working again\n\nResult:\nNothing\n",
@@ -34,7 +34,7 @@ func helpDescsEnUS() map[string]string {
 		"sendfrom":                "sendfrom \"toaddress\" amount ([\"fromaddress\",...] minconf=1 \"comment\" \"commentto\" maxinputs minheight)\n\nDEPRECATED -- Authors, signs, and sends a transaction that outputs some amount to a payment address.\nA change output is automatically included to send extra output value back to the original account.\n\nArguments:\n1. toaddress     (string, required)             Address to pay\n2. amount        (numeric, required)            Amount to send to the payment address valued in bitcoin\n3. fromaddresses (array of string, optional)    Addresses to use for selecting coins to spend\n4. minconf       (numeric, optional, default=1) Minimum number of block confirmations required before a transaction output is eligible to be spent\n5. comment       (string, optional)             Unused\n6. commentto     (string, optional)             Unused\n7. maxinputs     (numeric, optional)            Maximum number of transaction inputs that are allowed\n8. minheight     (numeric, optional)            Only select transactions from this height or above\n\nResult:\n\"value\" (string) The transaction hash of the sent transaction\n",
 		"sendmany":                "sendmany {\"address\":amount,...} ([\"fromaddress\",...] minconf=1 \"comment\" maxinputs)\n\nAuthors, signs, and sends a transaction that outputs to many payment addresses.\nA change output is automatically included to send extra output value back to the original account.\n\nArguments:\n1. amounts (object, required) Pairs of payment addresses and the output amount to pay each\n{\n \"Address to pay\": Amount to send to the payment address valued in bitcoin, (object) JSON object using payment addresses as keys and output amounts valued in bitcoin to send to each address\n ...\n}\n2. fromaddresses (array of string, optional)    Addresses to use for selecting coins to spend\n3. minconf       (numeric, optional, default=1) Minimum number of block confirmations required before a transaction output is eligible to be spent\n4. comment       (string, optional)             Unused\n5. maxinputs     (numeric, optional)            Maximum number of transaction inputs that are allowed\n\nResult:\n\"value\" (string) The transaction hash of the sent transaction\n",
 		"sendtoaddress":           "sendtoaddress \"address\" amount (\"comment\" \"commentto\")\n\nAuthors, signs, and sends a transaction that outputs some amount to a payment address.\nUnlike sendfrom, outputs are always chosen from the default account.\nA change output is automatically included to send extra output value back to the original account.\n\nArguments:\n1. address   (string, required)  Address to pay\n2. amount    (numeric, required) Amount to send to the payment address valued in bitcoin\n3. comment   (string, optional)  Unused\n4. commentto (string, optional)  Unused\n\nResult:\n\"value\" (string) The transaction hash of the sent transaction\n",
-		"sendvote":                "sendvote \"fromaddress\" \"votefor\" (iscandidate minconf maxinputs minheight)\n\nAuthors, signs, and sends a vote transaction to vote in the new Network Steward\nelection system. Vote transactions are not entirely free, they must pay normal\ntransaction fees like any other, so they must source coins from an input address\nand make change.\n\nUnlike normal transactions, vote transactions CANNOT contain more than one input\naddress. This address is considered to be the voter, and the vote is weighted based\non the number of coins this address has.\n\nArguments:\n1. fromaddress (string, required)  The address to use for casting the vote\n2. votefor     (string, required)  The address to vote for\n3. iscandidate (boolean, optional) If true, then this wallet will candidate to become Network Steward\n4. minconf     (numeric, optional) Minimum number of block confirmations required before a transaction output is eligible to be spent\n5. maxinputs   (numeric, optional) Maximum number of transaction inputs that are allowed\n6. minheight   (numeric, optional) Do not source inputs from that are newer than this block number\n\nResult:\n\"value\" (string) The transaction hash of the sent vote transaction\n",
+		"sendvote":                "sendvote \"fromaddress\" \"votefor\" (iscandidate minconf maxinputs minheight withdrawcandidacy)\n\nAuthors, signs, and sends a vote transaction to vote in the new Network Steward\nelection system. Vote transactions are not entirely free, they must pay normal\ntransaction fees like any other, so they must source coins from an input address\nand make change.\n\nUnlike normal transactions, vote transactions CANNOT contain more than one input\naddress. This address is considered to be the voter, and the vote is weighted based\non the number of coins this address has.\n\nArguments:\n1. fromaddress       (string, required)  The address to use for casting the vote\n2. votefor           (string, required)  The address to vote for\n3. iscandidate       (boolean, optional) If true, then this wallet will candidate to become Network Steward\n4. minconf           (numeric, optional) Minimum number of block confirmations required before a transaction output is eligible to be spent\n5. maxinputs         (numeric, optional) Maximum number of transaction inputs that are allowed\n6. minheight         (numeric, optional) Do not source inputs from that are newer than this block number\n7. withdrawcandidacy (boolean, optional) If true, then this wallet withdraws it's candidacy, cannot be combined with iscandidate\n\nResult:\n\"value\" (string) The transaction hash of the sent vote transaction\n",
 		"settxfee":                "settxfee amount\n\nModify the increment used each time more fee is required for an authored transaction.\n\nArguments:\n1. amount (numeric, required) The new fee increment valued in bitcoin\n\nResult:\ntrue|false (boolean) The boolean 'true'\n",
 		"signmessage":             "signmessage \"address\" \"message\"\n\nSigns a message using the private key of a payment address.\n\nArguments:\n1. address (string, required) Payment address of private key used to sign the message with\n2. message (string, required) Message to sign\n\nResult:\n\"value\" (string) The signed message encoded as a base64 string\n",
 		"signrawtransaction":      "signrawtransaction \"rawtx\" ([{\"txid\":\"value\",\"vout\":n,\"scriptpubkey\":\"value\",\"redeemscript\":\"value\"},...] [\"privkey\",...] flags=\"ALL\")\n\nSigns transaction inputs using private keys from this wallet and request.\nThe valid flags options are ALL, NONE, SINGLE, ALL|ANYONECANPAY, NONE|ANYONECANPAY, and SINGLE|ANYONECANPAY.\n\nArguments:\n1. rawtx    (string, required)                Unsigned or partially unsigned transaction to sign encoded as a hexadecimal string\n2. inputs   (array of object, optional)       Additional data regarding inputs that this wallet may not be tracking\n3. privkeys (array of string, optional)       Additional WIF-encoded private keys to use when creating signatures\n4. flags    (string, optional, default=\"ALL\") Sighash flags\n\nResult:\n{\n \"hex\": \"value\",         (string)          The resulting transaction encoded as a hexadecimal string\n \"complete\": true|false, (boolean)         Whether all input signatures have been created\n \"errors\": [{            (array of object) Script verification errors (if exists)\n  \"txid\": \"value\",       (string)          The transaction hash of the referenced previous output\n  \"vout\": n,             (numeric)         The output index of the referenced previous output\n  \"scriptSig\": \"value\",  (string)          The hex-encoded signature script\n  \"sequence\": n,         (numeric)         Script sequence number\n  \"error\": \"value\",      (string)          Verification or signing error related to the input\n },...],                                   \n}                        \n",
@@ -57,4 +57,4 @@ var localeHelpDescs = map[string]func() map[string]string{
 	"en_US": helpDescsEnUS,
 }
 
-var requestUsages = "addmultisigaddress nrequired [\"key\",...]\ncreatemultisig nrequired [\"key\",...]\ncreatetransaction \"toaddress\" amount ([\"fromaddress\",...] electrumformat \"changeaddress\" inputminheight minconf=1 vote maxinputs \"autolock\" nosign)\ngetaddressbalances (minconf=1 showzerobalance)\nsetnetworkstewardvote (\"votefor\" \"voteagainst\")\ngetnetworkstewardvote\nresync (fromheight toheight [\"address\",...] dropdb)\nstopresync\naddp2shscript \"script\" segwit\ndumpprivkey \"address\"\ngetbalance (minconf=1)\ngetbestblockhash\ngetblockcount\ngetinfo\ngetnewaddress (legacy)\ngetreceivedbyaddress \"address\" (minconf=1)\ngettransaction \"txid\" (includewatchonly=false)\ngetwalletseed\ngetsecret \"name\"\nhelp (\"command\")\nimportprivkey \"privkey\" (\"label\" rescan=true legacy=false)\nlistlockunspent\nlistreceivedbyaddress (minconf=1 includeempty=false includewatchonly=false)\nlistsinceblock (\"blockhash\" targetconfirmations=1 includewatchonly=false)\nlisttransactions (count=10 from=0)\nlistunspent (minconf=1 maxconf=9999999 [\"address\",...])\nlockunspent unlock [{\"txid\":\"value\",\"vout\":n},...] (\"lockname\")\nsendfrom \"toaddress\" amount ([\"fromaddress\",...] minconf=1 \"comment\" \"commentto\" maxinputs minheight)\nsendmany {\"address\":amount,...} ([\"fromaddress\",...] minconf=1 \"comment\" maxinputs)\nsendtoaddress \"address\" amount (\"comment\" \"commentto\")\nsendvote \"fromaddress\" \"votefor\" (iscandidate minconf maxinputs minheight)\nsettxfee amount\nsignmessage \"address\" \"message\"\nsignrawtransaction \"rawtx\" ([{\"txid\":\"value\",\"vout\":n,\"scriptpubkey\":\"value\",\"redeemscript\":\"value\"},...] [\"privkey\",...] flags=\"ALL\")\nvalidateaddress \"address\"\nverifymessage \"address\" \"signature\" \"message\"\nwalletlock\nwalletpassphrase \"passphrase\" timeout\nwalletpassphrasechange \"oldpassphrase\" \"newpassphrase\"\nwalletmempool\nexportwatchingwallet (\"account\" download=false)\ngetbestblock\ngetunconfirmedbalance (\"account\")\nlistaddresstransactions [\"address\",...] (\"account\")\nlistalltransactions (\"account\")\nwalletislocked"
+var requestUsages = "addmultisigaddress nrequired [\"key\",...]\ncreatemultisig nrequired [\"key\",...]\ncreatetransaction \"toaddress\" amount ([\"fromaddress\",...] electrumformat \"changeaddress\" inputminheight minconf=1 vote maxinputs \"autolock\" nosign)\ngetaddressbalances (minconf=1 showzerobalance)\nsetnetworkstewardvote (\"votefor\" \"voteagainst\")\ngetnetworkstewardvote\nresync (fromheight toheight [\"address\",...] dropdb)\nstopresync\naddp2shscript \"script\" segwit\ndumpprivkey \"address\"\ngetbalance (minconf=1)\ngetbestblockhash\ngetblockcount\ngetinfo\ngetnewaddress (legacy)\ngetreceivedbyaddress \"address\" (minconf=1)\ngettransaction \"txid\" (includewatchonly=false)\ngetwalletseed\ngetsecret \"name\"\nhelp (\"command\")\nimportprivkey \"privkey\" (\"label\" rescan=true legacy=false)\nlistlockunspent\nlistreceivedbyaddress (minconf=1 includeempty=false includewatchonly=false)\nlistsinceblock (\"blockhash\" targetconfirmations=1 includewatchonly=false)\nlisttransactions (count=10 from=0)\nlistunspent (minconf=1 maxconf=9999999 [\"address\",...])\nlockunspent unlock [{\"txid\":\"value\",\"vout\":n},...] (\"lockname\")\nsendfrom \"toaddress\" amount ([\"fromaddress\",...] minconf=1 \"comment\" \"commentto\" maxinputs minheight)\nsendmany {\"address\":amount,...} ([\"fromaddress\",...] minconf=1 \"comment\" maxinputs)\nsendtoaddress \"address\" amount (\"comment\" \"commentto\")\nsendvote \"fromaddress\" \"votefor\" (iscandidate minconf maxinputs minheight withdrawcandidacy)\nsettxfee amount\nsignmessage \"address\" \"message\"\nsignrawtransaction \"rawtx\" ([{\"txid\":\"value\",\"vout\":n,\"scriptpubkey\":\"value\",\"redeemscript\":\"value\"},...] [\"privkey\",...] flags=\"ALL\")\nvalidateaddress \"address\"\nverifymessage \"address\" \"signature\" \"message\"\nwalletlock\nwalletpassphrase \"passphrase\" timeout\nwalletpassphrasechange \"oldpassphrase\" \"newpassphrase\"\nwalletmempool\nexportwatchingwallet (\"account\" download=false)\ngetbestblock\ngetunconfirmedbalance (\"account\")\nlistaddresstransactions [\"address\",...] (\"account\")\nlistalltransactions (\"account\")\nwalletislocked"
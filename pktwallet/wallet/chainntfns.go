@@ -114,6 +114,7 @@ func (w *Wallet) GetVote(addr btcutil.Address) (*btcjson.AddressVoteInfo, er.R)
 				int64(w.chainParams.TargetTimePerBlock.Seconds())*int64(blocksToGo)
 			out = &btcjson.AddressVoteInfo{
 				IsCandidate:            v.IsCandidate,
+				WithdrawsCandidacy:     v.WithdrawsCandidacy,
 				VoteFor:                v.VoteFor,
 				VoteTxid:               v.VoteTxid,
 				VoteBlock:              v.VoteBlock,
@@ -128,6 +129,16 @@ func (w *Wallet) GetVote(addr btcutil.Address) (*btcjson.AddressVoteInfo, er.R)
 }
 
 func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) er.R {
+	if err := w.insertRelevantTx(dbtx, rec, block); err != nil {
+		return err
+	}
+	w.notifyRelevantTx(dbtx, rec, block)
+	return nil
+}
+
+// insertRelevantTx records the transaction and its credits in the wallet
+// without notifying clients, see notifyRelevantTx.
+func (w *Wallet) insertRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) er.R {
 	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
 	txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
 
@@ -143,8 +154,7 @@ func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord,
 	// Check every output to determine whether it is controlled by a wallet
 	// key.  If so, mark the output as a credit.
 	for i, output := range rec.MsgTx.TxOut {
-		_, addrs, _, err := txscript.ExtractPkScriptAddrs(output.PkScript,
-			w.chainParams)
+		_, addrs, _, err := txscript.TxOutAddresses(output, w.chainParams)
 		if err != nil {
 			// Non-standard outputs are skipped.
 			continue
@@ -194,6 +204,13 @@ func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord,
 			}
 		}
 	}
+	return nil
+}
+
+// notifyRelevantTx sends notification of a transaction which was recorded by
+// insertRelevantTx to any interested clients.
+func (w *Wallet) notifyRelevantTx(dbtx walletdb.ReadTx, rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) {
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
 
 	// Send notification of mined or unmined transaction to any interested
 	// clients.
@@ -227,8 +244,6 @@ func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord,
 			w.NtfnServer.notifyMinedTransaction(dbtx, details, block)
 		}
 	}
-
-	return nil
 }
 
 // chainConn is an interface that abstracts the chain connection logic required
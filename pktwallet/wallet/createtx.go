@@ -8,7 +8,12 @@ package wallet
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/emirpasic/gods/trees/redblacktree"
@@ -25,7 +30,10 @@ import (
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/unspent"
 	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/txscript/scriptbuilder"
 	"github.com/pkt-cash/pktd/wire"
+	"github.com/pkt-cash/pktd/wire/constants"
 )
 
 // Maximum number of inputs which will be included in a transaction
@@ -38,12 +46,111 @@ const MaxInputsPerTxLegacy = 499
 var InsufficientFundsError = er.GenericErrorType.CodeWithDetail("InsufficientFundsError",
 	"insufficient funds available to construct transaction")
 
+// AffordableOutputsError is wrapped by InsufficientFundsError when a
+// transaction cannot be created from CreateTxReq, it carries the number of
+// leading entries in Outputs which could have been paid for so that the caller
+// can trim the list of recipients and try again. Use AffordableOutputs to get
+// it from an error.
+type AffordableOutputsError struct {
+	Affordable int
+	Requested  int
+	Err        er.R
+}
+
+func (e AffordableOutputsError) Error() string {
+	return fmt.Sprintf("%s, only the first [%d] of [%d] outputs can be afforded",
+		e.Err.Message(), e.Affordable, e.Requested)
+}
+
+// AffordableOutputs returns the number of leading outputs which could have been
+// paid for if err is an InsufficientFundsError from creating a transaction.
+func AffordableOutputs(err er.R) (int, bool) {
+	if !InsufficientFundsError.Is(err) {
+		return 0, false
+	}
+	if ae, ok := er.Wrapped(err).(AffordableOutputsError); ok {
+		return ae.Affordable, true
+	}
+	return 0, false
+}
+
 var TooManyInputsError = er.GenericErrorType.CodeWithDetail("TooManyInputsError",
 	"unable to construct transaction because there are too many inputs, you may need to fold coins")
 
 var UnconfirmedCoinsError = er.GenericErrorType.CodeWithDetail("UnconfirmedCoinsError",
 	"unable to construct transaction, there are coins but they are not yet confirmed")
 
+var UnconfirmedChainTooLongError = er.GenericErrorType.CodeWithDetail("UnconfirmedChainTooLongError",
+	"unable to construct transaction, spending the available unconfirmed change would "+
+		"create too long a chain of unconfirmed transactions")
+
+// InvalidInputError is returned by ValidateEptf when the script of an input
+// does not validate, the index of the input is included in the message.
+var InvalidInputError = er.GenericErrorType.CodeWithDetail("InvalidInputError",
+	"transaction input does not validate")
+
+// NonStandardOutputError is returned when a transaction would pay to a script
+// which peers do not consider standard and would not relay.
+var NonStandardOutputError = er.GenericErrorType.CodeWithDetail("NonStandardOutputError",
+	"unable to construct transaction because an output pays to a non-standard script")
+
+// HighFeeError is returned when the fee of a transaction is more than the
+// allowed fraction of the value being sent and CreateTxReq.RejectHighFee is set.
+var HighFeeError = er.GenericErrorType.CodeWithDetail("HighFeeError",
+	"unable to construct transaction because the fee is too high compared to the amount being sent")
+
+// ChangeSplitError is returned when CreateTxReq.ChangeSplit is set but the
+// change is too small to be divided into that many outputs which are not dust.
+var ChangeSplitError = er.GenericErrorType.CodeWithDetail("ChangeSplitError",
+	"unable to construct transaction because the change cannot be split as requested")
+
+// LockTimeCurrentHeight may be used as CreateTxReq.LockTime to set the locktime
+// of the transaction to the current block height, so that it cannot be mined
+// in a reorganization of an earlier block, this discourages fee sniping.
+const LockTimeCurrentHeight = ^uint32(0)
+
+// setLockTime sets the locktime of tx, LockTimeCurrentHeight is replaced with
+// height. A locktime is only enforced if at least one input is not final, so
+// if every input is final then the sequence of each is reduced by one, which
+// does not enable a relative locktime.
+func setLockTime(tx *wire.MsgTx, lockTime uint32, height int32) {
+	if lockTime == LockTimeCurrentHeight {
+		lockTime = uint32(height)
+	}
+	tx.LockTime = lockTime
+	for _, in := range tx.TxIn {
+		if in.Sequence != constants.MaxTxInSequenceNum {
+			return
+		}
+	}
+	for _, in := range tx.TxIn {
+		in.Sequence = constants.MaxTxInSequenceNum - 1
+	}
+}
+
+// DefaultMaxFeeFraction is the fraction of the value being sent above which
+// the fee of a transaction is considered to be a mistake.
+const DefaultMaxFeeFraction = 0.5
+
+// checkHighFee returns the fee of the transaction and the value which it sends
+// to outputs other than change, along with whether the fee is more than
+// maxFraction of that value. A transaction which sends nothing, for example one
+// which only carries data outputs, is never considered to have a high fee.
+func checkHighFee(tx *txauthor.AuthoredTx, maxFraction float64) (btcutil.Amount, btcutil.Amount, bool) {
+	var total, sent btcutil.Amount
+	for i, out := range tx.Tx.TxOut {
+		total += btcutil.Amount(out.Value)
+		if i != tx.ChangeIndex {
+			sent += btcutil.Amount(out.Value)
+		}
+	}
+	fee := tx.TotalInput - total
+	if sent <= 0 {
+		return fee, sent, false
+	}
+	return fee, sent, float64(fee) > float64(sent)*maxFraction
+}
+
 func makeInputSource(eligible []*dbstructs.Unspent) txauthor.InputSource {
 	// Current inputs and their total value.  These are closed over by the
 	// returned input source and reused across multiple calls.
@@ -68,6 +175,96 @@ func makeInputSource(eligible []*dbstructs.Unspent) txauthor.InputSource {
 	}
 }
 
+// ExternalInput is an input which the wallet does not track, for example a coin
+// belonging to another party which is to be spent alongside the wallet's own
+// coins. Because the wallet does not know the output being spent, the caller
+// must supply its script and value.
+type ExternalInput struct {
+	OutPoint wire.OutPoint
+	PkScript []byte
+	Value    btcutil.Amount
+}
+
+// checkExternalInputs makes sure that the external inputs of txr are complete
+// and that the transaction will not be signed, since the wallet has no keys for
+// them.
+func checkExternalInputs(txr *CreateTxReq) er.R {
+	if len(txr.ExternalInputs) == 0 {
+		return nil
+	} else if txr.SendMode != SendModeUnsigned && txr.SendMode != SendModeEptf {
+		return er.New("ExternalInputs cannot be signed by the wallet, " +
+			"use SendModeUnsigned or SendModeEptf")
+	}
+	seen := make(map[wire.OutPoint]struct{}, len(txr.ExternalInputs))
+	for i, in := range txr.ExternalInputs {
+		if len(in.PkScript) == 0 {
+			return er.Errorf("External input [%d] has no PkScript", i)
+		} else if in.Value <= 0 {
+			return er.Errorf("External input [%d] has non-positive value [%s]",
+				i, in.Value.String())
+		} else if _, ok := seen[in.OutPoint]; ok {
+			return er.Errorf("External input [%d] spends [%s] more than once",
+				i, in.OutPoint.String())
+		}
+		seen[in.OutPoint] = struct{}{}
+	}
+	return nil
+}
+
+// withExternalInputs returns an input source which always spends all of the
+// external inputs, followed by as many of the inputs from src as are needed to
+// reach the target.
+func withExternalInputs(src txauthor.InputSource, external []ExternalInput) txauthor.InputSource {
+	externalTotal := btcutil.Amount(0)
+	for _, in := range external {
+		externalTotal += in.Value
+	}
+	return func(target btcutil.Amount) (btcutil.Amount, []*wire.TxIn, []wire.TxInAdditional, er.R) {
+		srcTarget := btcutil.Amount(0)
+		if target > externalTotal {
+			srcTarget = target - externalTotal
+		}
+		total, inputs, additional, err := src(srcTarget)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		outInputs := make([]*wire.TxIn, 0, len(external)+len(inputs))
+		outAdditional := make([]wire.TxInAdditional, 0, len(external)+len(inputs))
+		for _, in := range external {
+			op := in.OutPoint
+			v := int64(in.Value)
+			outInputs = append(outInputs, wire.NewTxIn(&op, nil, nil))
+			outAdditional = append(outAdditional, wire.TxInAdditional{
+				PkScript: in.PkScript,
+				Value:    &v,
+			})
+		}
+		outInputs = append(outInputs, inputs...)
+		outAdditional = append(outAdditional, additional...)
+		return externalTotal + total, outInputs, outAdditional, nil
+	}
+}
+
+// withoutExternalInputs returns the credits which are not spent by any of the
+// external inputs, so that a coin the wallet tracks is not spent twice if the
+// caller also passes it as external.
+func withoutExternalInputs(credits []*dbstructs.Unspent, external []ExternalInput) []*dbstructs.Unspent {
+	if len(external) == 0 {
+		return credits
+	}
+	spent := make(map[wire.OutPoint]struct{}, len(external))
+	for _, in := range external {
+		spent[in.OutPoint] = struct{}{}
+	}
+	out := make([]*dbstructs.Unspent, 0, len(credits))
+	for _, c := range credits {
+		if _, ok := spent[c.OutPoint]; !ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
 // secretSource is an implementation of txauthor.SecretSource for the wallet's
 // address manager.
 type secretSource struct {
@@ -120,7 +317,9 @@ func (s secretSource) GetScript(addr btcutil.Address) ([]byte, er.R) {
 // input scripts added and SHOULD NOT be broadcasted.
 func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R) {
 
-	chainClient, err := w.requireChainClient()
+	// Get current block's height and hash, retrying on a transient failure
+	// before any database transaction is opened.
+	bs, err := w.blockStampWithRetry()
 	if err != nil {
 		return nil, err
 	}
@@ -133,23 +332,81 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 
 	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
 
-	// Get current block's height and hash.
-	bs, err := chainClient.BlockStamp()
+	// A transaction paying less than the relay fee would not be accepted
+	// by peers, so never build one. The rate which is used is reported in
+	// the FeeSatPerKB of the result.
+	if relayFee := w.RelayFee(); txr.FeeSatPerKB < relayFee {
+		log.Debugf("Fee rate [%s/kB] is below the relay fee, using [%s/kB]",
+			txr.FeeSatPerKB.String(), relayFee.String())
+		txr.FeeSatPerKB = relayFee
+	}
+
+	outputs, err := appendDataOutputs(txr.Outputs, txr.DataOutputs)
 	if err != nil {
 		return nil, err
 	}
 
-	isEnough := enough.MkIsEnough(txr.Outputs, txr.FeeSatPerKB)
+	if !txr.AllowNonStandard {
+		for i, out := range outputs {
+			if err := checkOutputStandard(out.PkScript); err != nil {
+				return nil, NonStandardOutputError.New(fmt.Sprintf("output [%d]", i), err)
+			}
+		}
+	}
+
+	if txr.ChangeScript != nil {
+		if err := checkChangeScript(txr.ChangeScript); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkExternalInputs(&txr); err != nil {
+		return nil, err
+	}
+	if txr.DryRun && txr.SendMode != SendModeUnsigned {
+		return nil, er.New("DryRun transactions are not signed, use SendModeUnsigned")
+	}
+	if txr.ChangeSplit > 1 && (txr.ChangeScript != nil || txr.ChangeAddress != nil) {
+		return nil, er.New("ChangeSplit pays change to new addresses, " +
+			"it cannot be used with ChangeAddress or ChangeScript")
+	}
+
+	inputComparator := txr.InputComparator
+	if inputComparator == nil && txr.SelectionGoal == MinimizeUtxos {
+		inputComparator = PreferSmallest
+	}
+
+	isEnough := enough.MkIsEnough(outputs, txr.FeeSatPerKB)
 	t0 := time.Now()
 	eligibleOuts, visits, err := w.findEligibleOutputs(
 		dbtx, isEnough, txr.InputAddresses, txr.Minconf, bs,
-		txr.InputMinHeight, txr.InputComparator, txr.MaxInputs)
+		txr.InputMinHeight, inputComparator, txr.MaxInputs,
+		txr.AllowUnconfirmedChainLimit)
 	if err != nil {
 		return nil, err
 	}
 	log.Infof("findEligibleOutputs() completed in [%s], visited [%d] utxos",
 		time.Since(t0).String(), visits)
 
+	if inputComparator != nil && txr.InputComparator == nil && !isEnough.IsSweeping() {
+		// If the smallest outputs cannot pay within the input limit then
+		// fall back to the default selection rather than making a partial
+		// payment.
+		var amt btcutil.Amount
+		for _, eo := range eligibleOuts.credits {
+			amt += btcutil.Amount(eo.Value)
+		}
+		if !isEnough.WellIsIt(len(eligibleOuts.credits), eligibleOuts.isSegwit, amt) {
+			log.Debugf("Unable to pay using the smallest outputs, falling back to default selection")
+			eligibleOuts, _, err = w.findEligibleOutputs(
+				dbtx, isEnough, txr.InputAddresses, txr.Minconf, bs,
+				txr.InputMinHeight, nil, txr.MaxInputs,
+				txr.AllowUnconfirmedChainLimit)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	addrStr := "<all>"
 	if len(txr.InputAddresses) > 0 {
 		addrs := make([]string, 0, len(txr.InputAddresses))
@@ -158,15 +415,26 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 		}
 		addrStr = strings.Join(addrs, ", ")
 	}
-	log.Debugf("Found [%d] eligable inputs from addresses [%s], excluded [%d] (unconfirmed) "+
-		"and [%d] (too many inputs for tx)",
-		len(eligibleOuts.credits), addrStr, eligibleOuts.unconfirmedCount, eligibleOuts.unusedCount)
+	log.Debugf("Found [%d] eligable inputs from addresses [%s], excluded [%d] (unconfirmed), "+
+		"[%d] (unconfirmed chain too long) and [%d] (too many inputs for tx), "+
+		"[%d] unconfirmed change outputs are eligable",
+		len(eligibleOuts.credits), addrStr, eligibleOuts.unconfirmedCount,
+		eligibleOuts.deepChangeCount, eligibleOuts.unusedCount,
+		eligibleOuts.unconfirmedChangeCount)
 	for _, eo := range eligibleOuts.credits {
 		log.Debugf("  %s @ %d - %s", eo.OutPoint.String(), eo.Block.Height, btcutil.Amount(eo.Value).String())
 	}
 
+	eligibleOuts.credits = withoutExternalInputs(eligibleOuts.credits, txr.ExternalInputs)
 	inputSource := makeInputSource(eligibleOuts.credits)
+	if len(txr.ExternalInputs) > 0 {
+		inputSource = withExternalInputs(inputSource, txr.ExternalInputs)
+	}
+	var paidChangeAddr btcutil.Address
 	changeSource := func() ([]byte, er.R) {
+		if txr.ChangeScript != nil {
+			return txr.ChangeScript, nil
+		}
 		// Derive the change output script.  As a hack to allow
 		// spending from the imported account, change addresses are
 		// created from account 0.
@@ -188,10 +456,11 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 		if err != nil {
 			return nil, err
 		}
+		paidChangeAddr = changeAddr
 		return txscript.PayToAddrScript(changeAddr)
 	}
 	tx, err = txauthor.NewUnsignedTransaction(
-		txr.Outputs, txr.FeeSatPerKB, inputSource, changeSource, txr.MaxInputs > -1)
+		outputs, txr.FeeSatPerKB, inputSource, changeSource, txr.MaxInputs > -1)
 	if err != nil {
 		if !txauthor.ImpossibleTxError.Is(err) {
 			return nil, err
@@ -199,12 +468,23 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 			return nil, TooManyInputsError.New(
 				fmt.Sprintf("additional [%d] transactions containing [%f] coins",
 					eligibleOuts.unusedCount, eligibleOuts.unusedAmt.ToBTC()), err)
+		} else if eligibleOuts.deepChangeCount > 0 {
+			return nil, UnconfirmedChainTooLongError.New(
+				fmt.Sprintf("there are [%f] coins in [%d] unconfirmed change outputs which would "+
+					"create a chain of more than [%d] unconfirmed transactions",
+					eligibleOuts.deepChangeAmt.ToBTC(), eligibleOuts.deepChangeCount,
+					txr.AllowUnconfirmedChainLimit), err)
 		} else if eligibleOuts.unconfirmedCount > 0 {
 			return nil, UnconfirmedCoinsError.New(
 				fmt.Sprintf("there are [%f] coins available in [%d] unconfirmed transactions, "+
 					"to spend from these you need to specify minconf=0",
 					eligibleOuts.unconfirmedAmt.ToBTC(), eligibleOuts.unconfirmedCount), err)
 		} else {
+			err = er.E(AffordableOutputsError{
+				Affordable: countAffordableOutputs(txr, eligibleOuts.credits, changeSource),
+				Requested:  len(txr.Outputs),
+				Err:        err,
+			})
 			if txr.InputAddresses != nil {
 				return nil, InsufficientFundsError.New(
 					fmt.Sprintf("address(es) [%s] do not have enough balance", addrStr), err)
@@ -214,21 +494,123 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 		}
 	}
 
-	// Randomize change position, if change exists, before signing.  This
-	// doesn't affect the serialize size, so the change amount will still
-	// be valid.
-	if tx.ChangeIndex >= 0 {
-		tx.RandomizeChangePosition()
+	if tx.DustAbsorbed > 0 {
+		log.Debugf("Change of [%s] is dust, it is absorbed into the fee",
+			tx.DustAbsorbed.String())
+	}
+
+	maxFeeFraction := txr.MaxFeeFraction
+	if maxFeeFraction <= 0 {
+		maxFeeFraction = DefaultMaxFeeFraction
+	}
+	if fee, sent, high := checkHighFee(tx, maxFeeFraction); high {
+		if txr.RejectHighFee {
+			return nil, HighFeeError.New(fmt.Sprintf("fee [%s] is more than [%f] of the [%s] being sent",
+				fee.String(), maxFeeFraction, sent.String()), nil)
+		}
+		log.Warnf("Fee [%s] is more than [%f] of the [%s] being sent",
+			fee.String(), maxFeeFraction, sent.String())
+	}
+
+	// If requested, split the change between new internal addresses, this
+	// places the change outputs at random positions.
+	var splitChangeAddrs []btcutil.Address
+	if txr.ChangeSplit > 1 && tx.ChangeIndex >= 0 && txr.DryRun {
+		// Deriving the addresses would advance the internal branch, so
+		// pay to placeholders of the same size as a P2WPKH script.
+		fetchChange := func() ([]byte, er.R) {
+			return dryRunChangeScript, nil
+		}
+		w.changeRandMtx.Lock()
+		err = tx.SplitChange(txr.ChangeSplit, fetchChange, txr.FeeSatPerKB, w.changeRand)
+		w.changeRandMtx.Unlock()
+		if err != nil {
+			return nil, ChangeSplitError.New("", err)
+		}
+	} else if txr.ChangeSplit > 1 && tx.ChangeIndex >= 0 {
+		manager, err := w.Manager.FetchScopedKeyManager(waddrmgr.KeyScopeBIP0084)
+		if err != nil {
+			return nil, err
+		}
+		// The next address index only advances when the db transaction
+		// is committed, so all of the addresses are derived at once.
+		next := 0
+		fetchChange := func() ([]byte, er.R) {
+			if splitChangeAddrs == nil {
+				addrs, err := manager.NextInternalAddresses(addrmgrNs, 0, uint32(txr.ChangeSplit))
+				if err != nil {
+					return nil, err
+				}
+				for _, a := range addrs {
+					splitChangeAddrs = append(splitChangeAddrs, a.Address())
+				}
+			}
+			next++
+			return txscript.PayToAddrScript(splitChangeAddrs[next-1])
+		}
+		w.changeRandMtx.Lock()
+		err = tx.SplitChange(txr.ChangeSplit, fetchChange, txr.FeeSatPerKB, w.changeRand)
+		w.changeRandMtx.Unlock()
+		if err != nil {
+			return nil, ChangeSplitError.New("", err)
+		}
+	} else if tx.ChangeIndex >= 0 && !txr.SortBip69 {
+		// Randomize change position, if change exists, before signing.
+		// This doesn't affect the serialize size, so the change amount
+		// will still be valid.
+		w.randomizeChangePosition(tx)
+	} else if txr.ChangeSplit > 1 {
+		log.Debugf("There is no change to split")
+	}
+
+	if err := w.recordChangeDerivations(addrmgrNs, tx, splitChangeAddrs, paidChangeAddr); err != nil {
+		return nil, err
+	}
+
+	// The BIP 69 order does not depend on the wallet so it says nothing
+	// about which output is the change.
+	if txr.SortBip69 {
+		tx.SortBip69()
+	}
+
+	if txr.InputSequence != nil {
+		for _, in := range tx.Tx.TxIn {
+			in.Sequence = *txr.InputSequence
+		}
+		if *txr.InputSequence&constants.SequenceLockTimeDisabled == 0 && tx.Tx.Version < 2 {
+			tx.Tx.Version = 2
+		}
+	}
+
+	if txr.LockTime != 0 {
+		setLockTime(tx.Tx, txr.LockTime, bs.Height)
+	}
+
+	if txr.PreSignHook != nil {
+		if err := txr.PreSignHook(tx); err != nil {
+			return nil, err
+		}
 	}
 
 	// If a dry run was requested, we return now before adding the input
 	// scripts, and don't commit the database transaction. The DB will be
 	// rolled back when this method returns to ensure the dry run didn't
 	// alter the DB in any way.
-	if txr.SendMode == SendModeUnsigned {
+	if txr.DryRun {
+		return tx, nil
+	}
+	if txr.SendMode == SendModeUnsigned || txr.SendMode == SendModeEptf {
+		if txr.SendMode == SendModeEptf {
+			if err := checkEptfAdditional(tx.Tx); err != nil {
+				return nil, err
+			}
+		}
 		if err := dbtx.Commit(); err != nil {
 			return nil, err
 		}
+		if len(splitChangeAddrs) > 0 {
+			w.watch.WatchAddrs(splitChangeAddrs)
+		}
 		return tx, nil
 	}
 
@@ -242,20 +624,25 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 		return nil, err
 	}
 
-	if txr.SendMode != SendModeBcasted {
+	if txr.SendMode != SendModeBcasted && len(splitChangeAddrs) == 0 {
 		return tx, nil
 	}
 
+	// The new change addresses are kept even if the transaction is not
+	// broadcasted yet, so that the change is recognized once it is.
 	if err := dbtx.Commit(); err != nil {
 		return nil, err
 	}
 
 	// Finally, we'll request the backend to notify us of the transaction
 	// that pays to the change address, if there is one, when it confirms.
-	if tx.ChangeIndex >= 0 {
-		changePkScript := tx.Tx.TxOut[tx.ChangeIndex].PkScript
-		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
-			changePkScript, w.chainParams,
+	// A caller supplied change script may not belong to the wallet so there
+	// is nothing to watch.
+	if len(splitChangeAddrs) > 0 {
+		w.watch.WatchAddrs(splitChangeAddrs)
+	} else if tx.ChangeIndex >= 0 && txr.ChangeScript == nil {
+		_, addrs, _, err := txscript.TxOutAddresses(
+			tx.Tx.TxOut[tx.ChangeIndex], w.chainParams,
 		)
 		if err != nil {
 			return nil, err
@@ -266,6 +653,294 @@ func (w *Wallet) txToOutputs(txr CreateTxReq) (tx *txauthor.AuthoredTx, err er.R
 	return tx, nil
 }
 
+// recordChangeDerivations fills in the ChangeDerivations of tx. Split change is
+// paid to internal addresses of account 0, otherwise change is paid to
+// changeAddr which is only recorded if it belongs to the wallet.
+func (w *Wallet) recordChangeDerivations(
+	addrmgrNs walletdb.ReadBucket,
+	tx *txauthor.AuthoredTx,
+	splitChangeAddrs []btcutil.Address,
+	changeAddr btcutil.Address,
+) er.R {
+	if len(splitChangeAddrs) > 0 {
+		for _, addr := range splitChangeAddrs {
+			tx.ChangeDerivations = append(tx.ChangeDerivations, txauthor.ChangeDerivation{
+				KeyScope: waddrmgr.KeyScopeBIP0084,
+				Account:  0,
+				Address:  addr,
+			})
+		}
+		return nil
+	} else if tx.ChangeIndex < 0 || changeAddr == nil {
+		return nil
+	}
+	scoped, account, err := w.Manager.AddrAccount(addrmgrNs, changeAddr)
+	if waddrmgr.ErrAddressNotFound.Is(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	tx.ChangeDerivations = append(tx.ChangeDerivations, txauthor.ChangeDerivation{
+		KeyScope: scoped.Scope(),
+		Account:  account,
+		Address:  changeAddr,
+	})
+	return nil
+}
+
+// dryRunChangeScript is the placeholder which split change is paid to in a dry
+// run, it is a P2WPKH script so the size of the transaction is unchanged.
+var dryRunChangeScript = append([]byte{opcode.OP_0, opcode.OP_DATA_20}, make([]byte, 20)...)
+
+// countAffordableOutputs returns the largest n such that a transaction paying
+// the first n of txr.Outputs, along with any data outputs, can be funded from
+// credits.
+func countAffordableOutputs(
+	txr CreateTxReq,
+	credits []*dbstructs.Unspent,
+	changeSource txauthor.ChangeSource,
+) int {
+	return sort.Search(len(txr.Outputs), func(n int) bool {
+		outputs, err := appendDataOutputs(txr.Outputs[:n+1], txr.DataOutputs)
+		if err != nil {
+			return true
+		}
+		inputSource := makeInputSource(credits)
+		if len(txr.ExternalInputs) > 0 {
+			inputSource = withExternalInputs(inputSource, txr.ExternalInputs)
+		}
+		_, err = txauthor.NewUnsignedTransaction(outputs, txr.FeeSatPerKB,
+			inputSource, changeSource, txr.MaxInputs > -1)
+		return err != nil
+	})
+}
+
+// checkChangeScript makes sure that a caller supplied change script is a
+// standard script which can be spent, so that change is not lost.
+func checkChangeScript(changeScript []byte) er.R {
+	switch class := txscript.GetScriptClass(changeScript); class {
+	case txscript.NonStandardTy, txscript.NullDataTy:
+		return er.Errorf("change script [%x] of type [%s] is not spendable as change",
+			changeScript, class)
+	}
+	return nil
+}
+
+// maxStandardMultiSigKeys is the maximum number of public keys in a bare
+// multi-signature output which peers consider standard.
+const maxStandardMultiSigKeys = 3
+
+// checkOutputStandard makes sure that an output pays to a script which peers
+// consider standard and will relay.
+func checkOutputStandard(pkScript []byte) er.R {
+	switch class := txscript.GetScriptClass(pkScript); class {
+	case txscript.NonStandardTy:
+		return er.Errorf("script [%x] is of type [%s]", pkScript, class)
+	case txscript.MultiSigTy:
+		numPubKeys, numSigs, err := txscript.CalcMultiSigStats(pkScript)
+		if err != nil {
+			return err
+		} else if numPubKeys < 1 || numPubKeys > maxStandardMultiSigKeys {
+			return er.Errorf("multi-signature script [%x] has [%d] public keys, "+
+				"between 1 and [%d] are allowed", pkScript, numPubKeys, maxStandardMultiSigKeys)
+		} else if numSigs < 1 || numSigs > numPubKeys {
+			return er.Errorf("multi-signature script [%x] requires [%d] signatures "+
+				"from [%d] public keys", pkScript, numSigs, numPubKeys)
+		}
+	}
+	return nil
+}
+
+// SetChangePositionSource causes the position of the change output in newly
+// created transactions to be chosen using randomness from src rather than from
+// a cryptographically seeded prng. This is intended for tests which need
+// deterministic output ordering, passing nil restores the default behavior.
+func (w *Wallet) SetChangePositionSource(src rand.Source) {
+	w.changeRandMtx.Lock()
+	defer w.changeRandMtx.Unlock()
+	if src == nil {
+		w.changeRand = nil
+	} else {
+		w.changeRand = rand.New(src)
+	}
+}
+
+// RelayFee returns the minimum fee rate in satoshis per kB which peers will
+// relay, transactions are never created with a lower fee rate.
+func (w *Wallet) RelayFee() btcutil.Amount {
+	return btcutil.Amount(atomic.LoadInt64(&w.relayFee))
+}
+
+// SetRelayFee sets the minimum fee rate in satoshis per kB which peers will
+// relay, for example when connected to a node which requires a higher fee
+// than txrules.DefaultRelayFeePerKb.
+func (w *Wallet) SetRelayFee(fee btcutil.Amount) er.R {
+	if fee < 0 {
+		return er.Errorf("relay fee must not be negative, got [%s]", fee.String())
+	}
+	atomic.StoreInt64(&w.relayFee, int64(fee))
+	return nil
+}
+
+// DefaultBurnHorizon is the default number of blocks ahead of the current
+// height at which coinbase outputs are checked for being burned, so that a
+// transaction does not spend an output which will be burned before it confirms.
+const DefaultBurnHorizon = 1440
+
+// MaxBurnHorizon is the largest burn horizon which may be set, one week of
+// blocks, a transaction which has not confirmed by then is not expected to.
+const MaxBurnHorizon = 10080
+
+// SetBurnHorizon sets the number of blocks ahead of the current height at which
+// coinbase outputs are checked for being burned when selecting inputs, outputs
+// which will be burned within this many blocks are not spent. The horizon only
+// affects selection, outputs are not deleted until they are actually burned.
+func (w *Wallet) SetBurnHorizon(blocks int32) er.R {
+	if blocks < 0 {
+		return er.Errorf("burn horizon must not be negative, got [%d]", blocks)
+	} else if blocks > MaxBurnHorizon {
+		return er.Errorf("burn horizon must be at most [%d], got [%d]", MaxBurnHorizon, blocks)
+	}
+	atomic.StoreInt32(&w.burnHorizon, blocks)
+	return nil
+}
+
+// isBurned returns true if uns is a coinbase output which will be burned within
+// the burn horizon of height.
+func (w *Wallet) isBurned(uns *dbstructs.Unspent, height int32) bool {
+	return txrules.IsBurned(uns, w.chainParams, height+atomic.LoadInt32(&w.burnHorizon))
+}
+
+// isBurnedNow returns true if uns is a coinbase output which is already burned
+// at height, such an output can never be spent so it may be deleted.
+func (w *Wallet) isBurnedNow(uns *dbstructs.Unspent, height int32) bool {
+	return txrules.IsBurned(uns, w.chainParams, height)
+}
+
+// DefaultChainRetries is the default number of times that fetching the best
+// block is retried when creating a transaction before giving up, retrying is
+// off unless it is enabled with SetChainRetry.
+const DefaultChainRetries = 0
+
+// DefaultChainRetryBackoff is the default time to wait before the first retry
+// of fetching the best block, the wait is doubled after each failed attempt.
+const DefaultChainRetryBackoff = 250 * time.Millisecond
+
+// SetChainRetry sets the number of times that fetching the best block from the
+// chain client is retried when creating a transaction, and the time to wait
+// before the first retry. Zero retries means a failure is returned right away.
+func (w *Wallet) SetChainRetry(retries int32, backoff time.Duration) er.R {
+	if retries < 0 {
+		return er.Errorf("chain retries must not be negative, got [%d]", retries)
+	} else if backoff < 0 {
+		return er.Errorf("chain retry backoff must not be negative, got [%s]", backoff)
+	}
+	atomic.StoreInt32(&w.chainRetries, retries)
+	atomic.StoreInt64(&w.chainRetryBackoff, int64(backoff))
+	return nil
+}
+
+// blockStampWithRetry gets the best block from the chain client, retrying with
+// exponential backoff if the chain client returns an error so that a momentary
+// loss of connectivity does not fail the caller. If there is no chain client
+// then there is nothing to wait for and the failure is returned right away.
+func (w *Wallet) blockStampWithRetry() (*waddrmgr.BlockStamp, er.R) {
+	chainClient, err := w.requireChainClient()
+	if err != nil {
+		return nil, err
+	}
+	retries := atomic.LoadInt32(&w.chainRetries)
+	backoff := time.Duration(atomic.LoadInt64(&w.chainRetryBackoff))
+	for i := int32(0); ; i++ {
+		bs, err := chainClient.BlockStamp()
+		if err == nil {
+			return bs, nil
+		}
+		if i >= retries {
+			return nil, err
+		}
+		log.Debugf("Unable to get best block, retrying in [%s]: %s", backoff, err.String())
+		select {
+		case <-time.After(backoff):
+		case <-w.quitChan():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// isImmature returns true if uns is a coinbase output which has not yet reached
+// coinbase maturity when the chain is at the given height.
+func (w *Wallet) isImmature(uns *dbstructs.Unspent, height int32) bool {
+	return uns.FromCoinBase &&
+		!confirmed(int32(w.chainParams.CoinbaseMaturity), uns.Block.Height, height)
+}
+
+// randomizeChangePosition moves the change output of tx to a random position.
+func (w *Wallet) randomizeChangePosition(tx *txauthor.AuthoredTx) {
+	w.changeRandMtx.Lock()
+	defer w.changeRandMtx.Unlock()
+	if w.changeRand == nil {
+		tx.RandomizeChangePosition()
+	} else {
+		tx.RandomizeChangePositionWith(w.changeRand)
+	}
+}
+
+// appendDataOutputs returns a new slice of outputs with a zero-value
+// OP_RETURN output appended for each data payload. The outputs slice which is
+// passed in is not modified.
+func appendDataOutputs(outputs []*wire.TxOut, data [][]byte) ([]*wire.TxOut, er.R) {
+	if len(data) == 0 {
+		return outputs, nil
+	}
+	out := make([]*wire.TxOut, 0, len(outputs)+len(data))
+	out = append(out, outputs...)
+	for i, d := range data {
+		if len(d) > txscript.MaxDataCarrierSize {
+			return nil, er.Errorf("Data output number [%d] is [%d] bytes, "+
+				"the maximum allowed is [%d]", i, len(d), txscript.MaxDataCarrierSize)
+		}
+		script, err := scriptbuilder.NewScriptBuilder().
+			AddOp(opcode.OP_RETURN).AddData(d).Script()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, wire.NewTxOut(0, script))
+	}
+	return out, nil
+}
+
+// checkEptfAdditional verifies that every input of the transaction has the
+// PkScript and Value of the output which it spends, without this information
+// an external signer cannot sign the transaction.
+func checkEptfAdditional(tx *wire.MsgTx) er.R {
+	if len(tx.Additional) != len(tx.TxIn) {
+		return er.Errorf("len(tx.Additional) = [%d] but len(tx.TxIn) = [%d], cannot make EPTF",
+			len(tx.Additional), len(tx.TxIn))
+	}
+	for i, add := range tx.Additional {
+		if len(add.PkScript) == 0 {
+			return er.Errorf("Input number [%d] has no PkScript, cannot make EPTF", i)
+		} else if add.Value == nil {
+			return er.Errorf("Input number [%d] has unknown value, cannot make EPTF", i)
+		}
+	}
+	return nil
+}
+
+// encodeEptf serializes an unsigned transaction in EPTF.
+func encodeEptf(tx *wire.MsgTx) ([]byte, er.R) {
+	if err := checkEptfAdditional(tx); err != nil {
+		return nil, err
+	}
+	b := bytes.NewBuffer(make([]byte, 0, tx.SerializeSizeEptf()))
+	if err := tx.BtcEncode(b, 0, wire.ForceEptfEncoding); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
 type amountCount struct {
 	// Amount of coins
 	amount btcutil.Amount
@@ -351,9 +1026,36 @@ func PreferBiggest(a, b interface{}) int {
 }
 
 // PreferSmallest prefers smallest (coin value) outputs first (spend the dust)
-// func PreferSmallest(a, b interface{}) int {
-// 	return -PreferBiggest(a, b)
-// }
+func PreferSmallest(a, b interface{}) int {
+	return -PreferBiggest(a, b)
+}
+
+// PreferConfirmedThenBiggest prefers confirmed outputs over unconfirmed ones
+// and then biggest (coin value) outputs first
+func PreferConfirmedThenBiggest(a, b interface{}) int {
+	s1 := a.(*dbstructs.Unspent)
+	if s1 == nil {
+		panic("PreferConfirmedThenBiggest: s1 == nil")
+	}
+	s2 := b.(*dbstructs.Unspent)
+	if s2 == nil {
+		panic("PreferConfirmedThenBiggest: s2 == nil")
+	}
+
+	c1 := s1.Block.Height >= 0
+	c2 := s2.Block.Height >= 0
+	if c1 && !c2 {
+		return -1
+	} else if !c1 && c2 {
+		return 1
+	} else if s1.Value < s2.Value {
+		return 1
+	} else if s1.Value > s2.Value {
+		return -1
+	} else {
+		return NilComparator(s1, s2)
+	}
+}
 
 func convertResult(ac *amountCount) []*dbstructs.Unspent {
 	ifaces := ac.credits.Keys()
@@ -369,10 +1071,38 @@ func convertResult(ac *amountCount) []*dbstructs.Unspent {
 
 type eligibleOutputs struct {
 	credits          []*dbstructs.Unspent
+	isSegwit         bool
 	unconfirmedCount int
 	unconfirmedAmt   btcutil.Amount
 	unusedCount      int
 	unusedAmt        btcutil.Amount
+
+	// Unconfirmed change outputs which are eligable to be spent
+	unconfirmedChangeCount int
+	unconfirmedChangeAmt   btcutil.Amount
+
+	// Unconfirmed change outputs which are excluded because spending them
+	// would exceed the unconfirmed chain limit
+	deepChangeCount int
+	deepChangeAmt   btcutil.Amount
+}
+
+// limitInputs removes the worst credits from ac until it is within the input
+// limit, counting them as unused, and returns true if any were removed.
+func (out *eligibleOutputs) limitInputs(ac *amountCount, maxInputs int) bool {
+	wasOver := false
+	for ac.overLimit(maxInputs) {
+		worst := ac.credits.Right().Key.(*dbstructs.Unspent)
+		if worst == nil {
+			panic("findEligibleOutputs: worst == nil")
+		}
+		ac.credits.Remove(worst)
+		ac.amount -= btcutil.Amount(worst.Value)
+		out.unusedAmt += btcutil.Amount(worst.Value)
+		out.unusedCount++
+		wasOver = true
+	}
+	return wasOver
 }
 
 func (w *Wallet) findEligibleOutputs(
@@ -384,6 +1114,7 @@ func (w *Wallet) findEligibleOutputs(
 	inputMinHeight int,
 	inputComparator utils.Comparator,
 	maxInputs int,
+	unconfirmedChainLimit int,
 ) (eligibleOutputs, int, er.R) {
 	out := eligibleOutputs{}
 	chainClient, err := w.requireChainClient()
@@ -397,6 +1128,11 @@ func (w *Wallet) findEligibleOutputs(
 
 	var burnedOutputs []wire.OutPoint
 
+	frozen, err := w.TxStore.FrozenAddresses(txmgrNs)
+	if err != nil {
+		return out, 0, err
+	}
+
 	log.Debugf("Looking for unspents to build transaction")
 
 	addrStrs := make(map[string]struct{})
@@ -414,13 +1150,15 @@ func (w *Wallet) findEligibleOutputs(
 		}
 
 		if uns.FromCoinBase {
-			if !confirmed(int32(w.chainParams.CoinbaseMaturity), uns.Block.Height, bs.Height) {
+			if w.isImmature(uns, bs.Height) {
 				log.Debugf("Skipping immature coinbase output [%s] at height %d",
 					uns.OutPoint.String(), uns.Block.Height)
 				return nil
-			} else if txrules.IsBurned(uns, w.chainParams, bs.Height+1440) {
+			} else if w.isBurned(uns, bs.Height) {
 				log.Tracef("Skipping burned output at height %d", uns.Block.Height)
-				if len(burnedOutputs) < 1_000_000 {
+				// Outputs which are only within the burn horizon are still
+				// spendable so they are kept.
+				if len(burnedOutputs) < 1_000_000 && w.isBurnedNow(uns, bs.Height) {
 					burnedOutputs = append(burnedOutputs, uns.OutPoint)
 				}
 				return nil
@@ -440,9 +1178,31 @@ func (w *Wallet) findEligibleOutputs(
 			}
 		}
 
-		// Locked unspent outputs are skipped.
+		// Locked unspent outputs and outputs of frozen addresses are skipped.
 		if w.LockedOutpoint(uns.OutPoint) {
 			return nil
+		} else if _, ok := frozen[uns.Address]; ok {
+			return nil
+		}
+
+		if uns.Block.Height < 0 {
+			// Spending unconfirmed change extends a chain of unconfirmed
+			// transactions, if it would become too long then skip it.
+			depth, err := w.TxStore.UnminedChangeDepth(txmgrNs, &uns.OutPoint.Hash)
+			if err != nil {
+				return err
+			}
+			if depth == 0 {
+			} else if unconfirmedChainLimit > 0 && depth >= unconfirmedChainLimit {
+				log.Debugf("Skipping unconfirmed change [%s], unconfirmed chain depth [%d]",
+					uns.OutPoint.String(), depth)
+				out.deepChangeCount++
+				out.deepChangeAmt += btcutil.Amount(uns.Value)
+				return nil
+			} else {
+				out.unconfirmedChangeCount++
+				out.unconfirmedChangeAmt += btcutil.Amount(uns.Value)
+			}
 		}
 
 		// If there is an unspent which references a block header which doesn't
@@ -499,8 +1259,9 @@ func (w *Wallet) findEligibleOutputs(
 			}
 
 			// If we have no explicit sorting specified then we can short-circuit
-			// and avoid table-scanning the whole db
-			if inputComparator == nil {
+			// and avoid table-scanning the whole db, unless there are too many
+			// inputs in which case the worst must be removed first.
+			if inputComparator == nil && !ha.overLimit(maxInputs) {
 				winner = ha
 				return er.LoopBreak
 			}
@@ -560,7 +1321,11 @@ func (w *Wallet) findEligibleOutputs(
 				out.unusedCount += ac.credits.Size()
 			}
 		}
+		// When sweeping with no comparator the winner is picked as soon as
+		// it goes over the limit, so it must be brought back within it.
+		out.limitInputs(winner, maxInputs)
 		out.credits = convertResult(winner)
+		out.isSegwit = winner.isSegwit
 		return out, visits, nil
 	}
 
@@ -582,21 +1347,11 @@ func (w *Wallet) findEligibleOutputs(
 		for i := 0; it.Next(); i++ {
 			outAc.credits.Put(it.Key(), nil)
 		}
+		outAc.amount += ac.amount
 		outAc.isSegwit = outAc.isSegwit && ac.isSegwit
 
-		wasOver := false
-		for outAc.overLimit(maxInputs) {
-			// Too many inputs, we will remove the worst
-			worst := outAc.credits.Right().Key.(*dbstructs.Unspent)
-			if worst == nil {
-				panic("findEligibleOutputs: worst == nil")
-			}
-			outAc.credits.Remove(worst)
-			outAc.amount -= btcutil.Amount(worst.Value)
-			out.unusedAmt += btcutil.Amount(worst.Value)
-			out.unusedCount++
-			wasOver = true
-		}
+		// Too many inputs, we will remove the worst
+		wasOver := out.limitInputs(&outAc, maxInputs)
 		if isEnough.IsSweeping() && !wasOver {
 			// if we were never over the limit and we're sweeping multiple addresses,
 			// lets go around and get another address
@@ -607,39 +1362,48 @@ func (w *Wallet) findEligibleOutputs(
 		}
 	}
 
+	if outAc.overLimit(maxInputs) {
+		return out, visits, er.Errorf("findEligibleOutputs: selected [%d] inputs, "+
+			"more than the limit of [%d]", outAc.credits.Size(), maxInputs)
+	}
 	out.credits = convertResult(&outAc)
+	out.isSegwit = outAc.isSegwit
 	return out, visits, nil
 }
 
 // addrMgrWithChangeSource returns the address manager bucket and a change
-// source function that returns change addresses from said address manager.
+// source function that returns change addresses from said address manager,
+// along with a ChangeDerivation which is filled in when a change address is
+// made. Change for the imported account is derived from account 0, so the
+// account of the derivation can differ from the account which is being spent
+// from.
 func (w *Wallet) addrMgrWithChangeSource(dbtx walletdb.ReadWriteTx,
-	account uint32) (walletdb.ReadWriteBucket, txauthor.ChangeSource) {
+	account uint32) (walletdb.ReadWriteBucket, txauthor.ChangeSource, *txauthor.ChangeDerivation) {
 
 	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+	derivation := &txauthor.ChangeDerivation{}
 	changeSource := func() ([]byte, er.R) {
 		// Derive the change output script. We'll use the default key
 		// scope responsible for P2WPKH addresses to do so. As a hack to
 		// allow spending from the imported account, change addresses
 		// are created from account 0.
-		var changeAddr btcutil.Address
-		var err er.R
 		changeKeyScope := waddrmgr.KeyScopeBIP0084
+		changeAccount := account
 		if account == waddrmgr.ImportedAddrAccount {
-			changeAddr, _, err = w.newAddress(
-				addrmgrNs, 0, changeKeyScope,
-			)
-		} else {
-			changeAddr, _, err = w.newAddress(
-				addrmgrNs, account, changeKeyScope,
-			)
+			changeAccount = 0
 		}
+		changeAddr, _, err := w.newAddress(
+			addrmgrNs, changeAccount, changeKeyScope,
+		)
 		if err != nil {
 			return nil, err
 		}
+		derivation.KeyScope = changeKeyScope
+		derivation.Account = changeAccount
+		derivation.Address = changeAddr
 		return txscript.PayToAddrScript(changeAddr)
 	}
-	return addrmgrNs, changeSource
+	return addrmgrNs, changeSource, derivation
 }
 
 // validateMsgTx1 verifies transaction input scripts for tx.  All previous output
@@ -651,21 +1415,77 @@ func validateMsgTx1(tx *wire.MsgTx) er.R {
 		return er.Errorf("len(tx.Additional) = [%d] but len(tx.TxIn) = [%d], cannot validate tx",
 			len(tx.Additional), len(tx.TxIn))
 	}
-	for i, add := range tx.Additional {
-		if len(add.PkScript) == 0 {
-			return er.Errorf("Unable to validate transaction, add.PkScript is empty")
-		} else if add.Value == nil {
-			return er.Errorf("Unable to validate transaction, add.Value is unknown")
-		}
-		vm, err := txscript.NewEngine(add.PkScript, tx, i,
-			txscript.StandardVerifyFlags, nil, hashCache, *add.Value)
-		if err != nil {
-			err.AddMessage("cannot create script engine")
+	for i := range tx.Additional {
+		if err := validateInput(tx, i, hashCache, txscript.StandardVerifyFlags); err != nil {
 			return err
 		}
-		err = vm.Execute()
+	}
+	return nil
+}
+
+// validateInput verifies the input script of input i of tx using the PkScript
+// and Value from tx.Additional. The hashCache is only read so it may be shared
+// between goroutines validating different inputs of the same tx.
+func validateInput(tx *wire.MsgTx, i int, hashCache *txscript.TxSigHashes, flags txscript.ScriptFlags) er.R {
+	add := tx.Additional[i]
+	if len(add.PkScript) == 0 {
+		return er.Errorf("Unable to validate transaction, add.PkScript is empty")
+	} else if add.Value == nil {
+		return er.Errorf("Unable to validate transaction, add.Value is unknown")
+	}
+	vm, err := txscript.NewEngine(add.PkScript, tx, i, flags, nil, hashCache, *add.Value)
+	if err != nil {
+		err.AddMessage("cannot create script engine")
+		return err
+	}
+	err = vm.Execute()
+	if err != nil {
+		err.AddMessage("cannot validate transaction")
+		return err
+	}
+	return nil
+}
+
+// ValidateEptf verifies the input scripts of every input of tx using the
+// PkScript and Value of each input from tx.Additional, as decoded from EPTF.
+// The inputs are validated concurrently by a pool of workers, if any inputs
+// fail then an InvalidInputError for the failing input with the lowest index
+// is returned.
+func ValidateEptf(tx *wire.MsgTx, flags txscript.ScriptFlags) er.R {
+	if len(tx.Additional) != len(tx.TxIn) {
+		return er.Errorf("len(tx.Additional) = [%d] but len(tx.TxIn) = [%d], cannot validate tx",
+			len(tx.Additional), len(tx.TxIn))
+	}
+	hashCache := txscript.NewTxSigHashes(tx)
+
+	workers := runtime.NumCPU()
+	if workers > len(tx.TxIn) {
+		workers = len(tx.TxIn)
+	}
+	errs := make([]er.R, len(tx.TxIn))
+	next := int32(-1)
+	failed := int32(0)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(tx.TxIn) {
+					return
+				}
+				if err := validateInput(tx, i, hashCache, flags); err != nil {
+					errs[i] = InvalidInputError.New(fmt.Sprintf("input [%d]", i), err)
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
-			err.AddMessage("cannot validate transaction")
 			return err
 		}
 	}
@@ -8,21 +8,38 @@ import (
 	"bytes"
 	"encoding/hex"
 	"io/ioutil"
+	"math/rand"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/emirpasic/gods/utils"
+	"github.com/pkt-cash/pktd/blockchain"
+	"github.com/pkt-cash/pktd/blockchain/votecompute/votes"
+	"github.com/pkt-cash/pktd/btcec"
+	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/btcutil/hdkeychain"
+	"github.com/pkt-cash/pktd/btcutil/txsort"
 	"github.com/pkt-cash/pktd/chaincfg"
 	"github.com/pkt-cash/pktd/chaincfg/chainhash"
 	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/enough"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/internal/txsizes"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/txauthor"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
 	"github.com/pkt-cash/pktd/pktwallet/walletdb"
 	_ "github.com/pkt-cash/pktd/pktwallet/walletdb/bdb"
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr"
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
 	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/txscript/params"
+	"github.com/pkt-cash/pktd/txscript/scriptbuilder"
 	"github.com/pkt-cash/pktd/wire"
+	"github.com/pkt-cash/pktd/wire/constants"
 )
 
 var (
@@ -224,6 +241,12 @@ func TestTxToOutputsDryRun(t *testing.T) {
 // addUtxo add the given transaction to the wallet's database marked as a
 // confirmed UTXO .
 func addUtxo(t *testing.T, w *Wallet, incomingTx *wire.MsgTx) {
+	addUtxoAt(t, w, incomingTx, testBlockHeight)
+}
+
+// addUtxoAt adds the given transaction to the wallet's database as a UTXO
+// confirmed in a block at the given height.
+func addUtxoAt(t *testing.T, w *Wallet, incomingTx *wire.MsgTx, height int32) {
 	var b bytes.Buffer
 	if err := incomingTx.Serialize(&b); err != nil {
 		t.Fatalf("unable to serialize tx: %v", err)
@@ -240,7 +263,7 @@ func addUtxo(t *testing.T, w *Wallet, incomingTx *wire.MsgTx) {
 	block := &wtxmgr.BlockMeta{
 		Block: dbstructs.Block{
 			Hash:   *testBlockHash,
-			Height: testBlockHeight,
+			Height: height,
 		},
 		Time: time.Unix(1387737310, 0),
 	}
@@ -260,3 +283,1774 @@ func addUtxo(t *testing.T, w *Wallet, incomingTx *wire.MsgTx) {
 		t.Fatalf("failed inserting tx: %v", err)
 	}
 }
+
+// TestTxToOutputsEptf checks that a transaction created with SendModeEptf can
+// be encoded in EPTF and decoded back with the amount and pkScript of every
+// input intact.
+func TestTxToOutputsEptf(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+
+	incomingTx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	}
+	addUtxo(t, w, incomingTx)
+
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(10000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeEptf,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	for _, in := range tx.Tx.TxIn {
+		if len(in.SignatureScript) > 0 || len(in.Witness) > 0 {
+			t.Fatalf("EPTF transaction should not be signed")
+		}
+	}
+
+	blob, err := encodeEptf(tx.Tx)
+	if err != nil {
+		t.Fatalf("unable to encode EPTF: %v", err)
+	}
+	if !bytes.HasPrefix(blob, []byte("EPTF\xff\x00")) {
+		t.Fatalf("missing EPTF magic: %x", blob)
+	}
+
+	var decoded wire.MsgTx
+	if err := decoded.Deserialize(bytes.NewReader(blob)); err != nil {
+		t.Fatalf("unable to decode EPTF: %v", err)
+	}
+	if decoded.TxHash() != tx.Tx.TxHash() {
+		t.Fatalf("decoded txid [%s] does not match [%s]",
+			decoded.TxHash(), tx.Tx.TxHash())
+	}
+	if len(decoded.Additional) != 1 {
+		t.Fatalf("expected 1 input with additional info, got %d",
+			len(decoded.Additional))
+	}
+	add := decoded.Additional[0]
+	if !bytes.Equal(add.PkScript, p2wkhAddr) {
+		t.Fatalf("unexpected input pkScript %x", add.PkScript)
+	}
+	if add.Value == nil || *add.Value != 1000000 {
+		t.Fatalf("unexpected input value %v", add.Value)
+	}
+
+	// An input without a known value cannot be handed off for signing.
+	tx.Tx.Additional[0].Value = nil
+	if _, err := encodeEptf(tx.Tx); err == nil {
+		t.Fatalf("expected error encoding EPTF with unknown input value")
+	}
+}
+
+// TestTxToOutputsDataOutputs checks that DataOutputs are added to the
+// transaction as zero-value OP_RETURN outputs and that oversized payloads are
+// rejected.
+func TestTxToOutputsDataOutputs(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+
+	incomingTx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	}
+	addUtxo(t, w, incomingTx)
+
+	payloads := [][]byte{
+		append([]byte{votes.VOTE}, p2wkhAddr...),
+		[]byte("hello"),
+	}
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(10000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+		DataOutputs: payloads,
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(txr.Outputs) != 1 {
+		t.Fatalf("txToOutputs modified the request outputs")
+	}
+
+	// Change position is randomized so the data outputs may be reordered.
+	found := make(map[string]bool)
+	for _, out := range tx.Tx.TxOut {
+		if txscript.GetScriptClass(out.PkScript) != txscript.NullDataTy {
+			continue
+		}
+		if out.Value != 0 {
+			t.Fatalf("OP_RETURN output has nonzero value %d", out.Value)
+		}
+		pushes, err := txscript.PushedData(out.PkScript)
+		if err != nil {
+			t.Fatalf("unable to parse OP_RETURN output: %v", err)
+		}
+		if len(pushes) != 1 {
+			t.Fatalf("expected a single push in OP_RETURN output, got %d", len(pushes))
+		}
+		if bytes.Equal(pushes[0], payloads[0]) && votes.GetVote(out.PkScript) == nil {
+			t.Fatalf("vote payload was not recognized as a vote")
+		}
+		found[string(pushes[0])] = true
+	}
+	for _, p := range payloads {
+		if !found[string(p)] {
+			t.Fatalf("missing OP_RETURN output with payload %x", p)
+		}
+	}
+
+	txr.DataOutputs = [][]byte{make([]byte, txscript.MaxDataCarrierSize+1)}
+	if _, err := w.txToOutputs(txr); err == nil {
+		t.Fatalf("expected error for oversized data output")
+	}
+}
+
+// TestTxToOutputsDeterministicChange checks that with a fixed change position
+// source the change index is the same every time the transaction is created.
+func TestTxToOutputsDeterministicChange(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(10000000, p2wkhAddr)},
+	})
+
+	txr := CreateTxReq{
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+	}
+	for i := 0; i < 8; i++ {
+		txr.Outputs = append(txr.Outputs, wire.NewTxOut(10000, p2wkhAddr))
+	}
+
+	changeIndexes := func() []int {
+		w.SetChangePositionSource(rand.NewSource(1))
+		var out []int
+		for i := 0; i < 5; i++ {
+			tx, err := w.txToOutputs(txr)
+			if err != nil {
+				t.Fatalf("unable to author tx: %v", err)
+			}
+			if tx.ChangeIndex < 0 {
+				t.Fatalf("expected a change output")
+			}
+			out = append(out, tx.ChangeIndex)
+		}
+		return out
+	}
+
+	first := changeIndexes()
+	second := changeIndexes()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("change indexes differ with the same seed: %v vs %v",
+				first, second)
+		}
+	}
+	w.SetChangePositionSource(nil)
+}
+
+// TestTxToOutputsUnconfirmedChainLimit checks that unconfirmed change is only
+// spent when doing so does not exceed AllowUnconfirmedChainLimit.
+func TestTxToOutputsUnconfirmedChainLimit(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	// Spend the only confirmed output, leaving just unconfirmed change.
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(10000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeSigned,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if _, err := w.ReliablyPublishTransaction(tx.Tx, ""); err != nil {
+		t.Fatalf("unable to publish tx: %v", err)
+	}
+
+	txr := CreateTxReq{
+		Outputs:                    []*wire.TxOut{wire.NewTxOut(20000, p2wkhAddr)},
+		Minconf:                    0,
+		FeeSatPerKB:                1000,
+		SendMode:                   SendModeUnsigned,
+		MaxInputs:                  -1,
+		AllowUnconfirmedChainLimit: 1,
+	}
+	if _, err := w.txToOutputs(txr); !UnconfirmedChainTooLongError.Is(err) {
+		t.Fatalf("expected UnconfirmedChainTooLongError, got %v", err)
+	}
+
+	txr.AllowUnconfirmedChainLimit = 2
+	if _, err := w.txToOutputs(txr); err != nil {
+		t.Fatalf("unable to spend unconfirmed change: %v", err)
+	}
+	txr.AllowUnconfirmedChainLimit = 0
+	if _, err := w.txToOutputs(txr); err != nil {
+		t.Fatalf("unable to spend unconfirmed change without limit: %v", err)
+	}
+}
+
+// TestTxToOutputsChangeScript checks that a caller supplied change script is
+// used verbatim and that unspendable change scripts are rejected.
+func TestTxToOutputsChangeScript(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	// A p2wkh script which does not belong to the wallet.
+	vaultScript := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0xaa}, 20)...)
+	txr := CreateTxReq{
+		Outputs:      []*wire.TxOut{wire.NewTxOut(10000, p2wkhAddr)},
+		Minconf:      1,
+		FeeSatPerKB:  1000,
+		SendMode:     SendModeBcasted,
+		MaxInputs:    -1,
+		ChangeScript: vaultScript,
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if tx.ChangeIndex < 0 {
+		t.Fatalf("expected a change output")
+	}
+	if !bytes.Equal(tx.Tx.TxOut[tx.ChangeIndex].PkScript, vaultScript) {
+		t.Fatalf("change paid to %x, want %x",
+			tx.Tx.TxOut[tx.ChangeIndex].PkScript, vaultScript)
+	}
+
+	for _, script := range [][]byte{
+		{0x51, 0x51},       // OP_1 OP_1 is nonstandard
+		{0x6a, 0x01, 0x01}, // OP_RETURN would burn the change
+	} {
+		txr.ChangeScript = script
+		if _, err := w.txToOutputs(txr); err == nil {
+			t.Fatalf("expected error for change script %x", script)
+		}
+	}
+}
+
+// TestTxToOutputsAffordableOutputs checks that when the wallet cannot pay all
+// of the outputs, the number of outputs which could be paid is reported.
+func TestTxToOutputsAffordableOutputs(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	txr := CreateTxReq{
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+	}
+	for i := 0; i < 4; i++ {
+		txr.Outputs = append(txr.Outputs, wire.NewTxOut(300000, p2wkhAddr))
+	}
+	_, err = w.txToOutputs(txr)
+	if !InsufficientFundsError.Is(err) {
+		t.Fatalf("expected InsufficientFundsError, got %v", err)
+	}
+	affordable, ok := AffordableOutputs(err)
+	if !ok || affordable != 3 {
+		t.Fatalf("expected 3 affordable outputs, got %d (%v)", affordable, ok)
+	}
+
+	txr.Outputs = txr.Outputs[:affordable]
+	if _, err := w.txToOutputs(txr); err != nil {
+		t.Fatalf("unable to author tx with affordable outputs: %v", err)
+	}
+}
+
+// TestTxToOutputsRelayFeeFloor checks that a fee rate below the relay fee is
+// raised to the relay fee, including when the relay fee of the wallet is not
+// the default, and that the rate used is reported.
+func TestTxToOutputsRelayFeeFloor(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	var usedRate btcutil.Amount
+	fee := func(feeRate btcutil.Amount) int64 {
+		tx, err := w.txToOutputs(CreateTxReq{
+			Outputs:     []*wire.TxOut{wire.NewTxOut(10000, p2wkhAddr)},
+			Minconf:     1,
+			FeeSatPerKB: feeRate,
+			SendMode:    SendModeUnsigned,
+			MaxInputs:   -1,
+		})
+		if err != nil {
+			t.Fatalf("unable to author tx: %v", err)
+		}
+		usedRate = tx.FeeSatPerKB
+		fee := int64(0)
+		for _, add := range tx.Tx.Additional {
+			fee += *add.Value
+		}
+		for _, out := range tx.Tx.TxOut {
+			fee -= out.Value
+		}
+		return fee
+	}
+
+	relayFee := fee(txrules.DefaultRelayFeePerKb)
+	for _, feeRate := range []btcutil.Amount{0, 1, txrules.DefaultRelayFeePerKb - 1} {
+		if got := fee(feeRate); got != relayFee {
+			t.Fatalf("fee rate %d: got fee %d, want relay fee %d",
+				feeRate, got, relayFee)
+		}
+	}
+	if fee(txrules.DefaultRelayFeePerKb*10) <= relayFee {
+		t.Fatalf("fee rate above the relay fee was not honored")
+	}
+
+	// A node may require more than the default relay fee.
+	if err := w.SetRelayFee(txrules.DefaultRelayFeePerKb * 5); err != nil {
+		t.Fatalf("SetRelayFee: %v", err)
+	}
+	relayFee = fee(txrules.DefaultRelayFeePerKb * 5)
+	if got := fee(txrules.DefaultRelayFeePerKb); got != relayFee {
+		t.Fatalf("got fee %d, want non-default relay fee %d", got, relayFee)
+	}
+	if usedRate != txrules.DefaultRelayFeePerKb*5 {
+		t.Fatalf("reported fee rate %v, want %v", usedRate, txrules.DefaultRelayFeePerKb*5)
+	}
+	if err := w.SetRelayFee(-1); err == nil {
+		t.Fatalf("expected error for negative relay fee")
+	}
+}
+
+// TestTxToOutputsSelectionGoal compares the number of wallet outputs spent by
+// MinimizeFee and MinimizeUtxos for the same payment.
+func TestTxToOutputsSelectionGoal(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i), p2wkhAddr)},
+		})
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(5000000, p2wkhAddr)},
+	})
+
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(300000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+	}
+	minFee, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx with MinimizeFee: %v", err)
+	}
+
+	txr.SelectionGoal = MinimizeUtxos
+	minUtxos, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx with MinimizeUtxos: %v", err)
+	}
+	if len(minUtxos.Tx.TxIn) != 4 {
+		t.Fatalf("MinimizeUtxos: expected 4 inputs, got %d", len(minUtxos.Tx.TxIn))
+	}
+	for _, add := range minUtxos.Tx.Additional {
+		if *add.Value > 100003 {
+			t.Fatalf("MinimizeUtxos spent output of [%d], not one of the smallest", *add.Value)
+		}
+	}
+
+	// The number of wallet outputs which remain after each transaction,
+	// change goes back to the wallet.
+	reduction := func(tx *wire.MsgTx, changeIndex int) int {
+		r := len(tx.TxIn)
+		if changeIndex >= 0 {
+			r--
+		}
+		return r
+	}
+	if reduction(minUtxos.Tx, minUtxos.ChangeIndex) < reduction(minFee.Tx, minFee.ChangeIndex) {
+		t.Fatalf("MinimizeUtxos reduced the UTXO count by less than MinimizeFee")
+	}
+
+	// If the smallest outputs cannot pay within the input limit then the
+	// biggest are used.
+	txr.MaxInputs = 2
+	limited, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx with MinimizeUtxos and MaxInputs: %v", err)
+	}
+	if len(limited.Tx.TxIn) != 1 || *limited.Tx.Additional[0].Value != 5000000 {
+		t.Fatalf("MinimizeUtxos did not fall back to the biggest output")
+	}
+
+	// The smallest outputs are segwit so they are used when they can pay the
+	// fee of a segwit transaction, even though they could not pay the fee of
+	// a legacy one.
+	txr.MaxInputs = 4
+	txr.FeeSatPerKB = 100000
+	small := int64(100000 + 100001 + 100002 + 100003)
+	segwitFee := txrules.FeeForSerializeSize(txr.FeeSatPerKB,
+		txsizes.EstimateVirtualSize(0, 4, 0, txr.Outputs, true))
+	legacyFee := txrules.FeeForSerializeSize(txr.FeeSatPerKB,
+		txsizes.EstimateVirtualSize(4, 0, 0, txr.Outputs, true))
+	txr.Outputs = []*wire.TxOut{
+		wire.NewTxOut(small-int64(segwitFee+legacyFee)/2, p2wkhAddr),
+	}
+	segwit, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx with MinimizeUtxos and segwit inputs: %v", err)
+	}
+	if len(segwit.Tx.TxIn) != 4 {
+		t.Fatalf("MinimizeUtxos: expected the 4 smallest segwit outputs, got %d inputs",
+			len(segwit.Tx.TxIn))
+	}
+}
+
+// TestPreferConfirmedThenBiggest checks that confirmed outputs are ordered
+// before unconfirmed ones and that each group is ordered by descending value.
+func TestPreferConfirmedThenBiggest(t *testing.T) {
+	mk := func(height int32, value int64, index uint32) *dbstructs.Unspent {
+		return &dbstructs.Unspent{
+			OutPoint: wire.OutPoint{Hash: *testBlockHash, Index: index},
+			Block:    dbstructs.Block{Height: height},
+			Value:    value,
+		}
+	}
+	credits := []*dbstructs.Unspent{
+		mk(-1, 9000, 0),
+		mk(testBlockHeight, 100, 1),
+		mk(-1, 500, 2),
+		mk(testBlockHeight-10, 3000, 3),
+		mk(testBlockHeight, 3000, 4),
+		mk(-1, 500, 5),
+	}
+	sort.Slice(credits, func(i, j int) bool {
+		return PreferConfirmedThenBiggest(credits[i], credits[j]) < 0
+	})
+
+	expected := []uint32{3, 4, 1, 0, 2, 5}
+	for i, c := range credits {
+		if c.OutPoint.Index != expected[i] {
+			t.Fatalf("position %d: expected output %d, got %d",
+				i, expected[i], c.OutPoint.Index)
+		}
+	}
+
+	if PreferConfirmedThenBiggest(credits[0], credits[0]) != 0 {
+		t.Fatalf("an output must compare equal to itself")
+	}
+}
+
+// TestFinalizeAndBroadcast checks that an EPTF transaction which is signed
+// outside of txToOutputs can be broadcasted, and that it is rejected while any
+// input is unsigned.
+func TestFinalizeAndBroadcast(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000001, p2wkhAddr)},
+	})
+
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(1500000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeEptf,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 2 {
+		t.Fatalf("expected 2 inputs, got %d", len(tx.Tx.TxIn))
+	}
+
+	// Sign the transaction as the external signer would.
+	if err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		return tx.AddAllInputScripts(secretSource{w.Manager, addrmgrNs})
+	}); err != nil {
+		t.Fatalf("unable to sign tx: %v", err)
+	}
+
+	partial := tx.Tx.Copy()
+	partial.Additional = tx.Tx.Additional
+	partial.TxIn[1].Witness = nil
+	if _, err := w.FinalizeAndBroadcast(partial); err == nil {
+		t.Fatalf("expected partially signed tx to be rejected")
+	}
+
+	txid, err := w.FinalizeAndBroadcast(tx.Tx)
+	if err != nil {
+		t.Fatalf("unable to broadcast signed tx: %v", err)
+	}
+	if *txid != tx.Tx.TxHash() {
+		t.Fatalf("unexpected txid [%s]", txid)
+	}
+}
+
+// TestSignEptfInputs checks that only the requested inputs of an EPTF
+// transaction are signed and that inputs which the wallet does not control are
+// left unsigned.
+func TestSignEptfInputs(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000001, p2wkhAddr)},
+	})
+
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(1500000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeEptf,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 2 {
+		t.Fatalf("expected 2 inputs, got %d", len(tx.Tx.TxIn))
+	}
+
+	// Add an input from another party's address.
+	foreign := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x02}, 20)...)
+	foreignValue := int64(500000)
+	tx.Tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 7}, nil, nil))
+	tx.Tx.Additional = append(tx.Tx.Additional, wire.TxInAdditional{
+		PkScript: foreign,
+		Value:    &foreignValue,
+	})
+
+	if err := w.SignEptfInputs(tx.Tx, []int{0, 2}); err != nil {
+		t.Fatalf("unable to sign inputs: %v", err)
+	}
+
+	hashCache := txscript.NewTxSigHashes(tx.Tx)
+	if len(tx.Tx.TxIn[0].Witness) == 0 {
+		t.Fatalf("input 0 was not signed")
+	}
+	if err := validateInput(tx.Tx, 0, hashCache, txscript.StandardVerifyFlags); err != nil {
+		t.Fatalf("input 0 does not validate: %v", err)
+	}
+	for _, i := range []int{1, 2} {
+		in := tx.Tx.TxIn[i]
+		if len(in.Witness) != 0 || len(in.SignatureScript) != 0 {
+			t.Fatalf("input %d should have been left unsigned", i)
+		}
+		if len(tx.Tx.Additional[i].PkScript) == 0 {
+			t.Fatalf("input %d lost its Additional info", i)
+		}
+	}
+
+	if err := w.SignEptfInputs(tx.Tx, []int{3}); err == nil {
+		t.Fatalf("expected error signing out of range input")
+	}
+}
+
+// TestTxToOutputsPreSignHook checks that outputs added by the PreSignHook are
+// signed over and that an error from the hook aborts creating the transaction.
+func TestTxToOutputsPreSignHook(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	data, err := txscript.NullDataScript([]byte("annotation"))
+	if err != nil {
+		t.Fatalf("unable to create OP_RETURN script: %v", err)
+	}
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(10000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeSigned,
+		MaxInputs:   -1,
+		PreSignHook: func(tx *txauthor.AuthoredTx) er.R {
+			tx.Tx.AddTxOut(wire.NewTxOut(0, data))
+			return nil
+		},
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	last := tx.Tx.TxOut[len(tx.Tx.TxOut)-1]
+	if !bytes.Equal(last.PkScript, data) {
+		t.Fatalf("OP_RETURN output added by the hook is missing")
+	}
+	if err := validateMsgTx1(tx.Tx); err != nil {
+		t.Fatalf("tx does not validate: %v", err)
+	}
+
+	txr.PreSignHook = func(*txauthor.AuthoredTx) er.R {
+		return er.New("rejected by hook")
+	}
+	if _, err := w.txToOutputs(txr); err == nil {
+		t.Fatalf("expected error from the hook to abort the tx")
+	}
+}
+
+// TestTxToOutputsDustAbsorbed checks that the change which is too small to be
+// paid to a change output is reported as absorbed into the fee.
+func TestTxToOutputsDustAbsorbed(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	const leftover = 100
+	outputs := []*wire.TxOut{wire.NewTxOut(0, p2wkhAddr)}
+	fee := txrules.FeeForSerializeSize(1000, txsizes.EstimateVirtualSize(0, 1, 0, outputs, true))
+	outputs[0].Value = int64(1000000 - fee - leftover)
+
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     outputs,
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if tx.ChangeIndex >= 0 {
+		t.Fatalf("expected no change output")
+	}
+	if tx.DustAbsorbed != leftover {
+		t.Fatalf("expected [%d] absorbed into the fee, got [%d]", leftover, tx.DustAbsorbed)
+	}
+}
+
+// signedEptfTx creates a transaction with n signed p2wpkh inputs which carries
+// the PkScript and Value of every input in Additional.
+func signedEptfTx(t testing.TB, n int) *wire.MsgTx {
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create key: %v", err)
+	}
+	pkScript, err := scriptbuilder.NewScriptBuilder().AddOp(opcode.OP_0).
+		AddData(btcutil.Hash160(priv.PubKey().SerializeCompressed())).Script()
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	tx := wire.NewMsgTx(constants.TxVersion)
+	for i := 0; i < n; i++ {
+		v := int64(100000 + i)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: *testBlockHash, Index: uint32(i)}, nil, nil))
+		tx.Additional = append(tx.Additional, wire.TxInAdditional{PkScript: pkScript, Value: &v})
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(n)*100000, pkScript))
+	hashCache := txscript.NewTxSigHashes(tx)
+	for i, in := range tx.TxIn {
+		in.Witness, err = txscript.WitnessSignature(tx, hashCache, i,
+			*tx.Additional[i].Value, pkScript, params.SigHashAll, priv, true)
+		if err != nil {
+			t.Fatalf("unable to sign input %d: %v", i, err)
+		}
+	}
+	return tx
+}
+
+// TestValidateEptf checks that ValidateEptf accepts a fully signed transaction
+// and reports the lowest index of the inputs which do not validate.
+func TestValidateEptf(t *testing.T) {
+	tx := signedEptfTx(t, 50)
+	if err := ValidateEptf(tx, txscript.StandardVerifyFlags); err != nil {
+		t.Fatalf("ValidateEptf: %v", err)
+	}
+	if err := validateMsgTx1(tx); err != nil {
+		t.Fatalf("validateMsgTx1: %v", err)
+	}
+
+	tx.TxIn[40].Witness = tx.TxIn[41].Witness
+	tx.TxIn[17].Witness = tx.TxIn[18].Witness
+	err := ValidateEptf(tx, txscript.StandardVerifyFlags)
+	if err == nil {
+		t.Fatalf("ValidateEptf: expected error for bad signatures")
+	}
+	if !InvalidInputError.Is(err) || !strings.Contains(err.Message(), "input [17]") {
+		t.Fatalf("ValidateEptf: expected error for input 17, got %v", err)
+	}
+
+	tx.Additional = tx.Additional[1:]
+	if err := ValidateEptf(tx, txscript.StandardVerifyFlags); err == nil {
+		t.Fatalf("ValidateEptf: expected error for missing Additional")
+	}
+}
+
+func BenchmarkValidateEptf(b *testing.B) {
+	tx := signedEptfTx(b, 500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ValidateEptf(tx, txscript.StandardVerifyFlags); err != nil {
+			b.Fatalf("ValidateEptf: %v", err)
+		}
+	}
+}
+
+func BenchmarkValidateMsgTx1(b *testing.B) {
+	tx := signedEptfTx(b, 500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := validateMsgTx1(tx); err != nil {
+			b.Fatalf("validateMsgTx1: %v", err)
+		}
+	}
+}
+
+// TestBurnHorizon checks that the burn horizon determines how far ahead
+// network steward coinbase outputs are treated as burned.
+func TestBurnHorizon(t *testing.T) {
+	params := &chaincfg.PktTestNetParams
+	w := &Wallet{chainParams: params, burnHorizon: DefaultBurnHorizon}
+
+	const minedAt = 1000
+	uns := &dbstructs.Unspent{
+		Block:        dbstructs.Block{Height: minedAt},
+		FromCoinBase: true,
+		Value: blockchain.PktCalcNetworkStewardPayout(
+			blockchain.CalcBlockSubsidy(minedAt, params)),
+	}
+
+	// The output is burned 129600 blocks after it is mined, 1000 blocks
+	// before then it is within the default horizon but not within 100.
+	height := int32(minedAt + 129600 - 1000)
+	if !w.isBurned(uns, height) {
+		t.Fatalf("expected output to be burned within the default horizon")
+	}
+	if w.isBurnedNow(uns, height) || !w.isBurnedNow(uns, height+1000) {
+		t.Fatalf("expected output to be deletable only once it is actually burned")
+	}
+	if err := w.SetBurnHorizon(100); err != nil {
+		t.Fatalf("SetBurnHorizon: %v", err)
+	}
+	if w.isBurned(uns, height) {
+		t.Fatalf("expected output not to be burned within a horizon of 100")
+	}
+	if !w.isBurned(uns, height+900) {
+		t.Fatalf("expected output to be burned once it is within the horizon")
+	}
+
+	// Outputs which are not network steward payouts are never burned.
+	uns.Value++
+	if w.isBurned(uns, height+900) {
+		t.Fatalf("expected non network steward output not to be burned")
+	}
+
+	if err := w.SetBurnHorizon(-1); err == nil {
+		t.Fatalf("expected error for negative burn horizon")
+	}
+	if err := w.SetBurnHorizon(MaxBurnHorizon + 1); err == nil {
+		t.Fatalf("expected error for burn horizon above the maximum")
+	}
+}
+
+// TestTxToOutputsNonStandard checks that outputs paying to non-standard scripts
+// are rejected unless AllowNonStandard is set.
+func TestTxToOutputsNonStandard(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	// A bare 1-of-4 multisig has more keys than are standard.
+	b := scriptbuilder.NewScriptBuilder().AddOp(opcode.OP_1)
+	for i := 0; i < 4; i++ {
+		priv, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Fatalf("unable to create key: %v", err)
+		}
+		b.AddData(priv.PubKey().SerializeCompressed())
+	}
+	multisig, err := b.AddOp(opcode.OP_4).AddOp(opcode.OP_CHECKMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("unable to create multisig script: %v", err)
+	}
+	bigData, err := scriptbuilder.NewScriptBuilder().AddOp(opcode.OP_RETURN).
+		AddData(make([]byte, txscript.MaxDataCarrierSize+1)).Script()
+	if err != nil {
+		t.Fatalf("unable to create OP_RETURN script: %v", err)
+	}
+
+	for _, script := range [][]byte{multisig, bigData} {
+		txr := CreateTxReq{
+			Outputs: []*wire.TxOut{
+				wire.NewTxOut(10000, p2wkhAddr),
+				wire.NewTxOut(10000, script),
+			},
+			Minconf:     1,
+			FeeSatPerKB: 1000,
+			SendMode:    SendModeUnsigned,
+			MaxInputs:   -1,
+		}
+		_, err := w.txToOutputs(txr)
+		if !NonStandardOutputError.Is(err) {
+			t.Fatalf("expected NonStandardOutputError, got %v", err)
+		}
+		if !strings.Contains(err.Message(), "output [1]") {
+			t.Fatalf("expected error to name output 1, got %v", err)
+		}
+
+		txr.AllowNonStandard = true
+		if _, err := w.txToOutputs(txr); err != nil {
+			t.Fatalf("unable to author tx with AllowNonStandard: %v", err)
+		}
+	}
+}
+
+// TestTxToOutputsInputSequence checks that InputSequence is used for every
+// input and that a relative locktime makes the transaction version 2.
+func TestTxToOutputsInputSequence(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i), p2wkhAddr)},
+		})
+	}
+
+	seq := wire.SequenceForRelativeBlocks(144)
+	txr := CreateTxReq{
+		Outputs:       []*wire.TxOut{wire.NewTxOut(150000, p2wkhAddr)},
+		Minconf:       1,
+		FeeSatPerKB:   1000,
+		SendMode:      SendModeSigned,
+		MaxInputs:     -1,
+		InputSequence: &seq,
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 2 {
+		t.Fatalf("expected 2 inputs, got %d", len(tx.Tx.TxIn))
+	}
+	for i, in := range tx.Tx.TxIn {
+		if in.Sequence != seq {
+			t.Fatalf("input %d: got sequence %#08x, want %#08x", i, in.Sequence, seq)
+		}
+	}
+	if tx.Tx.Version != 2 {
+		t.Fatalf("expected version 2 for a relative locktime, got %d", tx.Tx.Version)
+	}
+
+	// A sequence which disables relative locktime leaves the version alone.
+	seq = constants.MaxTxInSequenceNum - 1
+	tx, err = w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if tx.Tx.Version != constants.TxVersion || tx.Tx.TxIn[0].Sequence != seq {
+		t.Fatalf("got version %d and sequence %#08x", tx.Tx.Version, tx.Tx.TxIn[0].Sequence)
+	}
+}
+
+// TestTxToOutputsHighFee checks that a transaction whose fee is more than the
+// allowed fraction of the amount being sent is rejected when RejectHighFee is
+// set and is otherwise created.
+func TestTxToOutputsHighFee(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	// At 10000 sat/kB the fee is well over half of the 2000 being sent.
+	txr := CreateTxReq{
+		Outputs:       []*wire.TxOut{wire.NewTxOut(2000, p2wkhAddr)},
+		Minconf:       1,
+		FeeSatPerKB:   10000,
+		SendMode:      SendModeUnsigned,
+		MaxInputs:     -1,
+		RejectHighFee: true,
+	}
+	if _, err := w.txToOutputs(txr); !HighFeeError.Is(err) {
+		t.Fatalf("expected HighFeeError, got %v", err)
+	}
+
+	txr.RejectHighFee = false
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	fee, sent, high := checkHighFee(tx, DefaultMaxFeeFraction)
+	if !high || sent != 2000 || fee <= sent/2 {
+		t.Fatalf("expected a high fee, got fee [%s] sending [%s]", fee, sent)
+	}
+
+	// A larger fraction allows the fee.
+	txr.RejectHighFee = true
+	txr.MaxFeeFraction = 2
+	if _, err := w.txToOutputs(txr); err != nil {
+		t.Fatalf("unable to author tx with a larger fee fraction: %v", err)
+	}
+}
+
+// TestTxToOutputsChangeSplit checks that the change is divided between the
+// requested number of outputs paying to new wallet addresses.
+func TestTxToOutputsChangeSplit(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(10000000, p2wkhAddr)},
+	})
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(1000000, dest)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeSigned,
+		MaxInputs:   -1,
+		ChangeSplit: 3,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxOut) != 4 {
+		t.Fatalf("expected 4 outputs, got %d", len(tx.Tx.TxOut))
+	}
+	if bytes.Equal(tx.Tx.TxOut[tx.ChangeIndex].PkScript, dest) {
+		t.Fatalf("ChangeIndex does not point to a change output")
+	}
+
+	var change int64
+	scripts := make(map[string]struct{})
+	for _, out := range tx.Tx.TxOut {
+		if bytes.Equal(out.PkScript, dest) {
+			continue
+		}
+		if bytes.Equal(out.PkScript, p2wkhAddr) {
+			t.Fatalf("change was paid to the input address")
+		}
+		if _, err := w.fetchOutputAddr(out.PkScript); err != nil {
+			t.Fatalf("change output does not belong to the wallet: %v", err)
+		}
+		scripts[string(out.PkScript)] = struct{}{}
+		change += out.Value
+	}
+	if len(scripts) != 3 {
+		t.Fatalf("expected 3 distinct change scripts, got %d", len(scripts))
+	}
+	fee := int64(tx.TotalInput) - change - 1000000
+	if fee <= 0 || fee > 1000 {
+		t.Fatalf("change of [%d] leaves an unexpected fee of [%d]", change, fee)
+	}
+	if err := validateMsgTx1(tx.Tx); err != nil {
+		t.Fatalf("split change tx does not validate: %v", err)
+	}
+
+	// The change is too small to be split this many ways.
+	_, err = w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(1000000, dest)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+		ChangeSplit: 100000,
+	})
+	if !ChangeSplitError.Is(err) {
+		t.Fatalf("expected ChangeSplitError, got %v", err)
+	}
+}
+
+// TestTxToOutputsLockTime checks that the locktime is applied and that inputs
+// are made non-final so that it is enforced.
+func TestTxToOutputsLockTime(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+	bs, err := w.chainClient.BlockStamp()
+	if err != nil {
+		t.Fatalf("unable to get block stamp: %v", err)
+	}
+
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(500000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeSigned,
+		MaxInputs:   -1,
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if tx.Tx.LockTime != 0 || tx.Tx.TxIn[0].Sequence != constants.MaxTxInSequenceNum {
+		t.Fatalf("unexpected locktime [%d] and sequence [%x] by default",
+			tx.Tx.LockTime, tx.Tx.TxIn[0].Sequence)
+	}
+
+	txr.LockTime = LockTimeCurrentHeight
+	tx, err = w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if tx.Tx.LockTime != uint32(bs.Height) {
+		t.Fatalf("expected locktime [%d], got [%d]", bs.Height, tx.Tx.LockTime)
+	}
+	for i, in := range tx.Tx.TxIn {
+		if in.Sequence != constants.MaxTxInSequenceNum-1 {
+			t.Fatalf("input %d: expected non-final sequence, got [%x]", i, in.Sequence)
+		}
+	}
+
+	// A non-final sequence which is requested is kept.
+	seq := wire.SequenceForRelativeBlocks(10)
+	txr.LockTime = 1234
+	txr.InputSequence = &seq
+	tx, err = w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if tx.Tx.LockTime != 1234 || tx.Tx.TxIn[0].Sequence != seq {
+		t.Fatalf("unexpected locktime [%d] and sequence [%x]",
+			tx.Tx.LockTime, tx.Tx.TxIn[0].Sequence)
+	}
+}
+
+// flakyChainClient is a mockChainClient whose BlockStamp fails a number of
+// times before succeeding.
+type flakyChainClient struct {
+	mockChainClient
+	failures int
+	calls    int
+}
+
+func (m *flakyChainClient) BlockStamp() (*waddrmgr.BlockStamp, er.R) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, er.New("connection refused")
+	}
+	return m.mockChainClient.BlockStamp()
+}
+
+// TestTxToOutputsChainRetry checks that retrying is off by default, that a
+// transaction is still created when the chain client fails to give the best
+// block once and retrying is enabled, and that a missing chain client is not
+// waited for.
+func TestTxToOutputsChainRetry(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(500000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+	}
+	flaky := &flakyChainClient{failures: 1}
+	w.chainClient = flaky
+	if _, err := w.txToOutputs(txr); err == nil {
+		t.Fatalf("expected error with retrying off by default")
+	}
+	if flaky.calls != 1 {
+		t.Fatalf("expected 1 call to BlockStamp, got %d", flaky.calls)
+	}
+
+	if err := w.SetChainRetry(2, time.Millisecond); err != nil {
+		t.Fatalf("SetChainRetry: %v", err)
+	}
+	flaky = &flakyChainClient{failures: 1}
+	w.chainClient = flaky
+	if _, err := w.txToOutputs(txr); err != nil {
+		t.Fatalf("unable to author tx with a flaky chain client: %v", err)
+	}
+	if flaky.calls != 2 {
+		t.Fatalf("expected 2 calls to BlockStamp, got %d", flaky.calls)
+	}
+
+	// With no chain client the failure must come back without waiting out
+	// the backoff.
+	if err := w.SetChainRetry(2, time.Hour); err != nil {
+		t.Fatalf("SetChainRetry: %v", err)
+	}
+	w.chainClient = nil
+	start := time.Now()
+	if _, err := w.txToOutputs(txr); err == nil {
+		t.Fatalf("expected error with no chain client")
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Fatalf("missing chain client was retried for %s", elapsed)
+	}
+
+	if err := w.SetChainRetry(-1, time.Millisecond); err == nil {
+		t.Fatalf("expected error for negative retries")
+	}
+}
+
+// TestAddrMgrWithChangeSource checks that the change derivation is recorded
+// both for a normal account and for the imported account, whose change comes
+// from account 0.
+func TestAddrMgrWithChangeSource(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	for _, account := range []uint32{0, waddrmgr.ImportedAddrAccount} {
+		err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) er.R {
+			addrmgrNs, changeSource, derivation := w.addrMgrWithChangeSource(dbtx, account)
+			if derivation.Address != nil {
+				t.Fatalf("account %d: change address recorded before any was made", account)
+			}
+			pkScript, err := changeSource()
+			if err != nil {
+				return err
+			}
+			if derivation.KeyScope != waddrmgr.KeyScopeBIP0084 {
+				t.Fatalf("account %d: got key scope %v, want %v",
+					account, derivation.KeyScope, waddrmgr.KeyScopeBIP0084)
+			}
+			if derivation.Account != 0 {
+				t.Fatalf("account %d: got change account %d, want 0",
+					account, derivation.Account)
+			}
+			want, err := txscript.PayToAddrScript(derivation.Address)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(pkScript, want) {
+				t.Fatalf("account %d: change script does not pay to the recorded address",
+					account)
+			}
+			_, acct, err := w.Manager.AddrAccount(addrmgrNs, derivation.Address)
+			if err != nil {
+				return err
+			}
+			if acct != derivation.Account {
+				t.Fatalf("account %d: change address is in account %d, want %d",
+					account, acct, derivation.Account)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("account %d: %v", account, err)
+		}
+	}
+}
+
+// TestTxToOutputsChangeDerivations checks that txToOutputs records the key
+// scope and account of the change address when change is returned to an input
+// address and when it is split, and records nothing for a foreign change
+// script.
+func TestTxToOutputsChangeDerivations(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+	req := func() CreateTxReq {
+		return CreateTxReq{
+			Outputs:     []*wire.TxOut{wire.NewTxOut(100000, dest)},
+			Minconf:     1,
+			FeeSatPerKB: 1000,
+			SendMode:    SendModeUnsigned,
+			MaxInputs:   -1,
+		}
+	}
+	checkDerivations := func(name string, tx *txauthor.AuthoredTx, count int) {
+		if len(tx.ChangeDerivations) != count {
+			t.Fatalf("%s: got %d change derivations, want %d",
+				name, len(tx.ChangeDerivations), count)
+		}
+		for _, d := range tx.ChangeDerivations {
+			if d.KeyScope != waddrmgr.KeyScopeBIP0084 || d.Account != 0 {
+				t.Fatalf("%s: got scope %v account %d, want %v account 0",
+					name, d.KeyScope, d.Account, waddrmgr.KeyScopeBIP0084)
+			}
+			script, err := txscript.PayToAddrScript(d.Address)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			found := false
+			for _, out := range tx.Tx.TxOut {
+				found = found || bytes.Equal(out.PkScript, script)
+			}
+			if !found {
+				t.Fatalf("%s: no output pays to change address [%s]", name, d.Address)
+			}
+		}
+	}
+
+	tx, err := w.txToOutputs(req())
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	checkDerivations("input address", tx, 1)
+	if tx.ChangeDerivations[0].Address.String() != addr.String() {
+		t.Fatalf("change derivation for [%s], want [%s]",
+			tx.ChangeDerivations[0].Address, addr)
+	}
+
+	split := req()
+	split.ChangeSplit = 3
+	if tx, err = w.txToOutputs(split); err != nil {
+		t.Fatalf("unable to author tx with split change: %v", err)
+	}
+	checkDerivations("split", tx, 3)
+
+	foreign := req()
+	foreign.ChangeScript = dest
+	if tx, err = w.txToOutputs(foreign); err != nil {
+		t.Fatalf("unable to author tx with change script: %v", err)
+	}
+	checkDerivations("change script", tx, 0)
+}
+
+// TestTxToOutputsSortBip69 checks that SortBip69 sorts the inputs and outputs
+// of a signed transaction whose signatures still validate.
+func TestTxToOutputsSortBip69(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(300000+i), p2wkhAddr)},
+		})
+	}
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs: []*wire.TxOut{
+			wire.NewTxOut(500000, dest),
+			wire.NewTxOut(400000, dest),
+		},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeSigned,
+		MaxInputs:   -1,
+		SortBip69:   true,
+	})
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) < 2 || tx.ChangeIndex < 0 {
+		t.Fatalf("expected several inputs and change, got %d inputs and change index %d",
+			len(tx.Tx.TxIn), tx.ChangeIndex)
+	}
+	if !txsort.IsSorted(tx.Tx) {
+		t.Fatalf("transaction is not sorted according to BIP 69")
+	}
+	if !bytes.Equal(tx.Tx.TxOut[tx.ChangeIndex].PkScript, p2wkhAddr) {
+		t.Fatalf("change index %d does not point to the change output", tx.ChangeIndex)
+	}
+	if err := validateMsgTx1(tx.Tx); err != nil {
+		t.Fatalf("sorted transaction does not validate: %v", err)
+	}
+}
+
+// TestTxToOutputsExternalInputs checks that external inputs are always spent
+// alongside the wallet's coins in an EPTF transaction, that their scripts and
+// values are carried in Additional, and that the wallet's inputs can then be
+// signed.
+func TestTxToOutputsExternalInputs(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	foreign := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x02}, 20)...)
+	external := []ExternalInput{
+		{OutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 7}, PkScript: foreign, Value: 400000},
+		{OutPoint: wire.OutPoint{Hash: chainhash.Hash{0x02}}, PkScript: foreign, Value: 300000},
+	}
+	txr := CreateTxReq{
+		Outputs:        []*wire.TxOut{wire.NewTxOut(1500000, foreign)},
+		Minconf:        1,
+		FeeSatPerKB:    1000,
+		SendMode:       SendModeEptf,
+		MaxInputs:      -1,
+		ExternalInputs: external,
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 3 || len(tx.Tx.Additional) != 3 {
+		t.Fatalf("expected 3 inputs, got %d with %d Additional",
+			len(tx.Tx.TxIn), len(tx.Tx.Additional))
+	}
+	if tx.TotalInput != 1700000 {
+		t.Fatalf("expected total input of 1700000, got %d", tx.TotalInput)
+	}
+	var tracked []int
+	for i, in := range tx.Tx.TxIn {
+		isExternal := false
+		for _, ext := range external {
+			if in.PreviousOutPoint == ext.OutPoint {
+				isExternal = true
+				add := tx.Tx.Additional[i]
+				if !bytes.Equal(add.PkScript, ext.PkScript) || *add.Value != int64(ext.Value) {
+					t.Fatalf("input %d has Additional %x %d, want %x %d",
+						i, add.PkScript, *add.Value, ext.PkScript, ext.Value)
+				}
+			}
+		}
+		if !isExternal {
+			tracked = append(tracked, i)
+		}
+	}
+	if len(tracked) != 1 {
+		t.Fatalf("expected 1 wallet input, got %d", len(tracked))
+	}
+	if _, err := encodeEptf(tx.Tx); err != nil {
+		t.Fatalf("unable to encode EPTF: %v", err)
+	}
+
+	if err := w.SignEptfInputs(tx.Tx, []int{0, 1, 2}); err != nil {
+		t.Fatalf("unable to sign inputs: %v", err)
+	}
+	hashCache := txscript.NewTxSigHashes(tx.Tx)
+	if err := validateInput(tx.Tx, tracked[0], hashCache, txscript.StandardVerifyFlags); err != nil {
+		t.Fatalf("wallet input does not validate: %v", err)
+	}
+
+	// External inputs cannot be signed by the wallet.
+	txr.SendMode = SendModeSigned
+	if _, err := w.txToOutputs(txr); err == nil {
+		t.Fatalf("expected error signing external inputs")
+	}
+	txr.SendMode = SendModeEptf
+	txr.ExternalInputs = []ExternalInput{external[0], external[0]}
+	if _, err := w.txToOutputs(txr); err == nil {
+		t.Fatalf("expected error for duplicate external inputs")
+	}
+}
+
+// TestFindEligibleOutputsInputLimit checks that no more than MaxInputs inputs
+// are selected when sweeping, with and without a custom comparator, and when
+// paying with a custom comparator from more than one address.
+func TestFindEligibleOutputsInputLimit(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	var scripts [][]byte
+	for i := 0; i < 2; i++ {
+		addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		if err != nil {
+			t.Fatalf("unable to get new address: %v", err)
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+		}
+		scripts = append(scripts, script)
+	}
+	for i := 0; i < 10; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i*1000), scripts[i%2])},
+		})
+	}
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	tests := []struct {
+		name       string
+		value      int64
+		comparator utils.Comparator
+	}{
+		{"sweep", 0, nil},
+		{"sweep with comparator", 0, PreferSmallest},
+		{"pay with comparator", 250000, PreferSmallest},
+		{"pay", 250000, nil},
+	}
+	for _, test := range tests {
+		tx, err := w.txToOutputs(CreateTxReq{
+			Outputs:         []*wire.TxOut{wire.NewTxOut(test.value, dest)},
+			Minconf:         1,
+			FeeSatPerKB:     1000,
+			SendMode:        SendModeUnsigned,
+			MaxInputs:       3,
+			InputComparator: test.comparator,
+		})
+		if err != nil {
+			t.Fatalf("%s: unable to author tx: %v", test.name, err)
+		}
+		if len(tx.Tx.TxIn) > 3 {
+			t.Errorf("%s: selected %d inputs, more than the limit of 3",
+				test.name, len(tx.Tx.TxIn))
+		}
+	}
+}
+
+// TestFindEligibleOutputsMerge checks that when no single address can pay, the
+// outputs of addresses are merged only until there is enough to pay, with and
+// without an input limit.
+func TestFindEligibleOutputsMerge(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		if err != nil {
+			t.Fatalf("unable to get new address: %v", err)
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+		}
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i*1000), script)},
+		})
+	}
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+	isEnough := enough.MkIsEnough([]*wire.TxOut{wire.NewTxOut(150000, dest)}, 1000)
+	bs, err := w.blockStampWithRetry()
+	if err != nil {
+		t.Fatalf("unable to get block stamp: %v", err)
+	}
+
+	for _, maxInputs := range []int{-1, 2} {
+		err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) er.R {
+			out, _, err := w.findEligibleOutputs(dbtx, isEnough, nil, 1, bs, 0, nil, maxInputs, 0)
+			if err != nil {
+				return err
+			}
+			var selected btcutil.Amount
+			for _, c := range out.credits {
+				selected += btcutil.Amount(c.Value)
+			}
+			if len(out.credits) != 2 || out.unusedCount != 1 {
+				t.Fatalf("max inputs %d: selected %d and left %d outputs, want 2 and 1",
+					maxInputs, len(out.credits), out.unusedCount)
+			}
+			if selected+out.unusedAmt != 303000 {
+				t.Fatalf("max inputs %d: selected %v and left %v, want a total of 303000",
+					maxInputs, selected, out.unusedAmt)
+			}
+			if !isEnough.WellIsIt(len(out.credits), true, selected) {
+				t.Fatalf("max inputs %d: selected %v is not enough", maxInputs, selected)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("max inputs %d: %v", maxInputs, err)
+		}
+	}
+}
+
+// TestFreezeAddress checks that the outputs of a frozen address are not spent
+// even when they are needed to reach the target, and that they can be spent
+// again once the address is unfrozen.
+func TestFreezeAddress(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	var addrs []btcutil.Address
+	var scripts [][]byte
+	for i := 0; i < 2; i++ {
+		addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		if err != nil {
+			t.Fatalf("unable to get new address: %v", err)
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+		}
+		addrs = append(addrs, addr)
+		scripts = append(scripts, script)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(600000, scripts[0])},
+	})
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(700000, scripts[1])},
+	})
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	if err := w.FreezeAddress(addrs[1]); err != nil {
+		t.Fatalf("unable to freeze address: %v", err)
+	}
+	frozen, err := w.FrozenAddresses()
+	if err != nil {
+		t.Fatalf("unable to list frozen addresses: %v", err)
+	}
+	if len(frozen) != 1 || frozen[0] != addrs[1].String() {
+		t.Fatalf("expected [%s] to be frozen, got %v", addrs[1], frozen)
+	}
+
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(500000, dest)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+	}
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	for i, add := range tx.Tx.Additional {
+		if bytes.Equal(add.PkScript, scripts[1]) {
+			t.Fatalf("input %d spends from the frozen address", i)
+		}
+	}
+
+	// Paying more than the unfrozen output needs the frozen one.
+	txr.Outputs = []*wire.TxOut{wire.NewTxOut(1000000, dest)}
+	if _, err := w.txToOutputs(txr); !InsufficientFundsError.Is(err) {
+		t.Fatalf("expected InsufficientFundsError, got %v", err)
+	}
+
+	if err := w.UnfreezeAddress(addrs[1]); err != nil {
+		t.Fatalf("unable to unfreeze address: %v", err)
+	}
+	if frozen, err := w.FrozenAddresses(); err != nil || len(frozen) != 0 {
+		t.Fatalf("expected no frozen addresses, got %v, %v", frozen, err)
+	}
+	if _, err := w.txToOutputs(txr); err != nil {
+		t.Fatalf("unable to author tx after unfreezing: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/internal/txsizes"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
+	"github.com/pkt-cash/pktd/txscript"
 	"github.com/pkt-cash/pktd/wire"
 )
 
@@ -56,7 +57,11 @@ func (ii *IsEnough) WellIsIt(inputCount int, segwit bool, amt btcutil.Amount) bo
 func GetSweepOutput(outs []*wire.TxOut) *wire.TxOut {
 	var sweepOutput *wire.TxOut
 	for _, out := range outs {
-		if out.Value == 0 && votes.GetVote(out.PkScript) == nil {
+		if out.Value != 0 {
+		} else if votes.GetVote(out.PkScript) != nil {
+		} else if txscript.GetScriptClass(out.PkScript) == txscript.NullDataTy {
+			// OP_RETURN data outputs are zero-value by design
+		} else {
 			sweepOutput = out
 		}
 	}
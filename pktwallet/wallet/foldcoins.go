@@ -0,0 +1,204 @@
+package wallet
+
+import (
+	"sort"
+
+	"github.com/pkt-cash/pktd/btcutil"
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
+	"github.com/pkt-cash/pktd/pktlog/log"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/txauthor"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// foldCoinsLockName is the name used for locking outpoints while they are
+// being folded so that they are not selected by concurrent transactions.
+const foldCoinsLockName = "foldcoins"
+
+// smallestEligibleOutputs returns up to max of the smallest confirmed and
+// spendable outputs which belong to account, along with the total number of
+// such outputs.
+func (w *Wallet) smallestEligibleOutputs(
+	dbtx walletdb.ReadTx,
+	account uint32,
+	bs *waddrmgr.BlockStamp,
+	max int,
+) ([]*dbstructs.Unspent, int, er.R) {
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+	addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+
+	frozen, err := w.TxStore.FrozenAddresses(txmgrNs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	accounts := make(map[string]bool)
+	var eligible []*dbstructs.Unspent
+	if _, err := w.TxStore.ForEachUnspentOutput(txmgrNs, nil, nil, func(_ []byte, uns *dbstructs.Unspent) er.R {
+		if !confirmed(1, uns.Block.Height, bs.Height) {
+			return nil
+		} else if w.isImmature(uns, bs.Height) {
+			return nil
+		} else if w.isBurned(uns, bs.Height) {
+			return nil
+		} else if w.LockedOutpoint(uns.OutPoint) {
+			return nil
+		} else if _, ok := frozen[uns.Address]; ok {
+			return nil
+		}
+		inAccount, ok := accounts[uns.Address]
+		if !ok {
+			if addr, err := btcutil.DecodeAddress(uns.Address, w.chainParams); err != nil {
+				log.Warnf("Unable to decode address [%s] from utxo [%s]",
+					uns.Address, uns.OutPoint.String())
+			} else if _, acct, err := w.Manager.AddrAccount(addrmgrNs, addr); err != nil {
+				log.Debugf("Unable to find account of address [%s]: [%s]",
+					uns.Address, err.String())
+			} else {
+				inAccount = acct == account
+			}
+			accounts[uns.Address] = inAccount
+		}
+		if inAccount {
+			eligible = append(eligible, uns)
+		}
+		return nil
+	}); err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(eligible, func(i, j int) bool {
+		return PreferBiggest(eligible[j], eligible[i]) < 0
+	})
+	if len(eligible) > max {
+		return eligible[:max], len(eligible), nil
+	}
+	return eligible, len(eligible), nil
+}
+
+// foldOnce creates and signs a single transaction which spends up to maxInputs
+// of the smallest outputs in the account to a new internal address. If there
+// are fewer than maxInputs outputs remaining then nil is returned. As with
+// txToOutputs, a fee rate below the relay fee is raised to the relay fee.
+func (w *Wallet) foldOnce(
+	account uint32,
+	feeRate btcutil.Amount,
+	maxInputs int,
+) (*wire.MsgTx, er.R) {
+	if relayFee := w.RelayFee(); feeRate < relayFee {
+		log.Debugf("FoldCoins: fee rate [%s/kB] is below the relay fee, using [%s/kB]",
+			feeRate.String(), relayFee.String())
+		feeRate = relayFee
+	}
+	chainClient, err := w.requireChainClient()
+	if err != nil {
+		return nil, err
+	}
+	bs, err := chainClient.BlockStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	dbtx, err := w.db.BeginReadWriteTx()
+	if err != nil {
+		return nil, err
+	}
+	defer dbtx.Rollback()
+
+	credits, count, err := w.smallestEligibleOutputs(dbtx, account, bs, maxInputs)
+	if err != nil {
+		return nil, err
+	}
+	if count < maxInputs {
+		log.Debugf("FoldCoins: [%d] outputs remaining in account [%d], done", count, account)
+		return nil, nil
+	}
+
+	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+	manager, err := w.Manager.FetchScopedKeyManager(waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := manager.NextInternalAddresses(addrmgrNs, account, 1)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addrs[0].Address())
+	if err != nil {
+		return nil, err
+	}
+
+	// A zero value output sweeps all of the inputs to it.
+	outputs := []*wire.TxOut{wire.NewTxOut(0, pkScript)}
+	tx, err := txauthor.NewUnsignedTransaction(outputs, feeRate, makeInputSource(credits),
+		func() ([]byte, er.R) { return pkScript, nil }, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.AddAllInputScripts(secretSource{w.Manager, addrmgrNs}); err != nil {
+		return nil, err
+	}
+	if err := validateMsgTx1(tx.Tx); err != nil {
+		return nil, err
+	}
+	if err := dbtx.Commit(); err != nil {
+		return nil, err
+	}
+	w.watch.WatchAddrs([]btcutil.Address{addrs[0].Address()})
+	return tx.Tx, nil
+}
+
+// FoldCoins consolidates the outputs of an account by repeatedly creating
+// transactions which each spend up to maxInputs of the smallest outputs to a
+// fresh internal address, until fewer than maxInputs confirmed outputs remain.
+// Only confirmed outputs are folded so each transaction spends different coins.
+// The txids of the transactions which were created and published are returned,
+// if an error occurs part way through then the txids of the transactions which
+// were published before the error are returned along with it.
+func (w *Wallet) FoldCoins(
+	account uint32,
+	feeRate btcutil.Amount,
+	maxInputs int,
+) ([]*chainhash.Hash, er.R) {
+	if maxInputs < 2 {
+		return nil, er.Errorf("FoldCoins: maxInputs must be at least 2, got [%d]", maxInputs)
+	} else if maxInputs > MaxInputsPerTx {
+		return nil, er.Errorf("FoldCoins: maxInputs must be at most [%d], got [%d]",
+			MaxInputsPerTx, maxInputs)
+	}
+
+	hu, err := w.holdUnlock()
+	if err != nil {
+		return nil, err
+	}
+	defer hu.release()
+
+	var out []*chainhash.Hash
+	for {
+		tx, err := w.foldOnce(account, feeRate, maxInputs)
+		if err != nil {
+			return out, err
+		} else if tx == nil {
+			return out, nil
+		}
+
+		// Lock the inputs while publishing so that they cannot be selected
+		// by another transaction before they are marked as spent.
+		for _, in := range tx.TxIn {
+			w.LockOutpoint(in.PreviousOutPoint, foldCoinsLockName)
+		}
+		txid, err := w.ReliablyPublishTransaction(tx, "")
+		for _, in := range tx.TxIn {
+			w.UnlockOutpoint(in.PreviousOutPoint)
+		}
+		if err != nil {
+			return out, err
+		}
+		log.Infof("FoldCoins: folded [%d] inputs in tx [%s]", len(tx.TxIn), log.Txid(txid.String()))
+		out = append(out, txid)
+	}
+}
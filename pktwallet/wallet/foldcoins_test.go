@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/pkt-cash/pktd/btcutil"
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// TestFoldCoins checks that folding a wallet with many small outputs reduces
+// the number of outputs until fewer than maxInputs confirmed outputs remain.
+func TestFoldCoins(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", err)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(20000+i), p2wkhAddr)},
+		})
+	}
+
+	countOutputs := func() (confirmed, unconfirmed int) {
+		if err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			_, err := w.TxStore.ForEachUnspentOutput(ns, nil, nil,
+				func(_ []byte, uns *dbstructs.Unspent) er.R {
+					if uns.Block.Height < 0 {
+						unconfirmed++
+					} else {
+						confirmed++
+					}
+					return nil
+				})
+			return err
+		}); err != nil {
+			t.Fatalf("unable to count outputs: %v", err)
+		}
+		return
+	}
+
+	if _, err := w.FoldCoins(0, 1000, 1); err == nil {
+		t.Fatalf("expected error folding with maxInputs < 2")
+	}
+
+	txids, err := w.FoldCoins(0, 1000, 4)
+	if err != nil {
+		t.Fatalf("unable to fold coins: %v", err)
+	}
+	if len(txids) != 2 {
+		t.Fatalf("expected 2 fold transactions, got %d", len(txids))
+	}
+	confirmed, unconfirmed := countOutputs()
+	if confirmed != 2 || unconfirmed != 2 {
+		t.Fatalf("expected 2 confirmed and 2 unconfirmed outputs, got %d and %d",
+			confirmed, unconfirmed)
+	}
+
+	// With a lower threshold the two remaining confirmed outputs are folded
+	// but the unconfirmed outputs are left alone.
+	txids, err = w.FoldCoins(0, 1000, 2)
+	if err != nil {
+		t.Fatalf("unable to fold coins: %v", err)
+	}
+	if len(txids) != 1 {
+		t.Fatalf("expected 1 fold transaction, got %d", len(txids))
+	}
+	if confirmed, _ := countOutputs(); confirmed != 0 {
+		t.Fatalf("expected no confirmed outputs, got %d", confirmed)
+	}
+}
+
+// TestFoldOnceRelayFeeFloor checks that a fold transaction never pays less than
+// the relay fee.
+func TestFoldOnceRelayFeeFloor(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", err)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	var total int64
+	for i := 0; i < 3; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(20000+i), p2wkhAddr)},
+		})
+		total += int64(20000 + i)
+	}
+	if err := w.SetRelayFee(txrules.DefaultRelayFeePerKb * 5); err != nil {
+		t.Fatalf("SetRelayFee: %v", err)
+	}
+
+	// The fold transactions are not published so each one spends the same
+	// outputs to a new address of the same size.
+	fee := func(feeRate btcutil.Amount) int64 {
+		tx, err := w.foldOnce(0, feeRate, 3)
+		if err != nil {
+			t.Fatalf("unable to fold: %v", err)
+		} else if tx == nil {
+			t.Fatalf("expected a fold transaction")
+		}
+		return total - tx.TxOut[0].Value
+	}
+	relayFee := fee(txrules.DefaultRelayFeePerKb * 5)
+	if got := fee(0); got != relayFee {
+		t.Fatalf("fold with zero fee rate paid %d, want relay fee %d", got, relayFee)
+	}
+	if got := fee(txrules.DefaultRelayFeePerKb); got != relayFee {
+		t.Fatalf("fold below the relay fee paid %d, want relay fee %d", got, relayFee)
+	}
+}
@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/pktlog/log"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// ImportTransactions reads a stream of serialized transactions, each one
+// prefixed with its length as a varint, and records all of them in the wallet
+// within a single database transaction. The transactions are recorded as
+// unmined, a transaction which is already in a block is only marked as mined
+// once the wallet is rescanned from that block. If any transaction cannot be
+// read or recorded then nothing is imported and the error is returned, clients
+// are only notified of the transactions once all of them are recorded. The
+// number of transactions imported is returned.
+func (w *Wallet) ImportTransactions(r io.Reader) (int, er.R) {
+	br := bufio.NewReader(r)
+	var recs []*wtxmgr.TxRecord
+	err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) er.R {
+		now := time.Now()
+		for {
+			if _, errr := br.Peek(1); errr == io.EOF {
+				return nil
+			}
+			serialized, err := wire.ReadVarBytes(br, 0, wire.MaxBlockPayload, "transaction")
+			if err != nil {
+				return err
+			}
+			var tx wire.MsgTx
+			r := bytes.NewReader(serialized)
+			if err := tx.Deserialize(r); err != nil {
+				return err
+			} else if r.Len() > 0 {
+				return er.Errorf("transaction [%d] has [%d] unexpected trailing bytes",
+					len(recs), r.Len())
+			}
+			rec, err := wtxmgr.NewTxRecordFromMsgTx(&tx, now)
+			if err != nil {
+				return err
+			}
+			if err := w.insertRelevantTx(dbtx, rec, nil); err != nil {
+				return err
+			}
+			recs = append(recs, rec)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+		for _, rec := range recs {
+			w.notifyRelevantTx(dbtx, rec, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Cannot notify imported transactions: %v", err)
+	}
+	log.Infof("Imported [%d] transactions", len(recs))
+	return len(recs), nil
+}
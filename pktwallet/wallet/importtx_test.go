@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// TestImportTransactions checks that a stream of transactions is imported
+// into the wallet and that a corrupt stream imports nothing and notifies
+// nothing.
+func TestImportTransactions(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+
+	var stream bytes.Buffer
+	unspents := make(map[wire.OutPoint]int64)
+	for i := 0; i < 3; i++ {
+		tx := &wire.MsgTx{
+			TxIn: []*wire.TxIn{{
+				PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{byte(i + 1)}},
+			}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(10000*(i+1)), p2wkhAddr)},
+		}
+		var b bytes.Buffer
+		if err := tx.Serialize(&b); err != nil {
+			t.Fatalf("unable to serialize tx: %v", err)
+		}
+		if err := wire.WriteVarBytes(&stream, 0, b.Bytes()); err != nil {
+			t.Fatalf("unable to write tx: %v", err)
+		}
+		unspents[wire.OutPoint{Hash: tx.TxHash()}] = tx.TxOut[0].Value
+	}
+	encoded := stream.Bytes()
+
+	listUnspent := func() map[wire.OutPoint]int64 {
+		out := make(map[wire.OutPoint]int64)
+		if err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			_, err := w.TxStore.ForEachUnspentOutput(ns, nil, nil,
+				func(_ []byte, uns *dbstructs.Unspent) er.R {
+					out[uns.OutPoint] = uns.Value
+					return nil
+				})
+			return err
+		}); err != nil {
+			t.Fatalf("unable to list unspent outputs: %v", err)
+		}
+		return out
+	}
+
+	ntfns := w.NtfnServer.TransactionNotifications()
+	defer ntfns.Done()
+	notified := make(chan *TransactionNotifications, len(unspents)+1)
+	go func() {
+		for n := range ntfns.C {
+			notified <- n
+		}
+	}()
+
+	// A truncated stream is rejected without importing anything.
+	if _, err := w.ImportTransactions(bytes.NewReader(encoded[:len(encoded)-1])); err == nil {
+		t.Fatalf("expected error importing truncated stream")
+	}
+	if got := listUnspent(); len(got) != 0 {
+		t.Fatalf("truncated stream imported %d outputs", len(got))
+	}
+	select {
+	case n := <-notified:
+		t.Fatalf("truncated stream notified %d transactions", len(n.UnminedTransactions))
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	count, err := w.ImportTransactions(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("unable to import transactions: %v", err)
+	}
+	if count != len(unspents) {
+		t.Fatalf("imported %d transactions, want %d", count, len(unspents))
+	}
+	got := listUnspent()
+	if len(got) != len(unspents) {
+		t.Fatalf("got %d unspent outputs, want %d", len(got), len(unspents))
+	}
+	for op, value := range unspents {
+		if got[op] != value {
+			t.Fatalf("output %s has value %d, want %d", op, got[op], value)
+		}
+	}
+	for i := 0; i < len(unspents); i++ {
+		select {
+		case <-notified:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d notifications, want %d", i, len(unspents))
+		}
+	}
+}
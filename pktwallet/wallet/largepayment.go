@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"bytes"
+
+	"github.com/pkt-cash/pktd/btcutil"
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
+	"github.com/pkt-cash/pktd/pktlog/log"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// largePaymentLockName is the name used for locking outpoints while a part of
+// a large payment is being published.
+const largePaymentLockName = "largepayment"
+
+// payOnce creates, signs and publishes a single transaction paying up to value
+// to pkScript using at most maxInputs inputs. If the inputs are not enough to
+// pay the whole value then they are all spent to pkScript with no change. The
+// txid and the amount which was paid to pkScript are returned.
+func (w *Wallet) payOnce(
+	pkScript []byte,
+	value int64,
+	feeRate btcutil.Amount,
+	maxInputs int,
+) (*chainhash.Hash, int64, er.R) {
+	tx, err := w.txToOutputs(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(value, pkScript)},
+		Minconf:     1,
+		FeeSatPerKB: feeRate,
+		SendMode:    SendModeSigned,
+		MaxInputs:   maxInputs,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	var paid int64
+	for i, out := range tx.Tx.TxOut {
+		if i != tx.ChangeIndex && bytes.Equal(out.PkScript, pkScript) {
+			paid += out.Value
+		}
+	}
+	if paid <= 0 {
+		return nil, 0, er.Errorf("transaction pays nothing to the output, "+
+			"[%d] remaining is less than the fee", value)
+	}
+
+	// Lock the inputs while publishing so that they cannot be selected
+	// by another transaction before they are marked as spent.
+	for _, in := range tx.Tx.TxIn {
+		w.LockOutpoint(in.PreviousOutPoint, largePaymentLockName)
+	}
+	txid, err := w.ReliablyPublishTransaction(tx.Tx, "")
+	for _, in := range tx.Tx.TxIn {
+		w.UnlockOutpoint(in.PreviousOutPoint)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return txid, paid, nil
+}
+
+func (w *Wallet) sendLargePayment(
+	outputs []*wire.TxOut,
+	feeRate btcutil.Amount,
+	maxInputs int,
+) ([]*chainhash.Hash, er.R) {
+	hu, err := w.holdUnlock()
+	if err != nil {
+		return nil, err
+	}
+	defer hu.release()
+
+	var out []*chainhash.Hash
+	for _, o := range outputs {
+		if o.Value <= 0 {
+			return out, er.Errorf("SendLargePayment: output value must be positive, got [%d]", o.Value)
+		}
+		remaining := o.Value
+		for remaining > 0 {
+			txid, paid, err := w.payOnce(o.PkScript, remaining, feeRate, maxInputs)
+			if err != nil {
+				return out, err
+			}
+			remaining -= paid
+			log.Infof("SendLargePayment: paid [%s] in tx [%s], [%s] remaining",
+				btcutil.Amount(paid).String(), log.Txid(txid.String()),
+				btcutil.Amount(remaining).String())
+			out = append(out, txid)
+		}
+	}
+	return out, nil
+}
+
+// SendLargePayment pays outputs which may need more than MaxInputsPerTx inputs
+// by creating and publishing a series of transactions. Each output is paid in
+// turn, while the confirmed coins which fit in one transaction are not enough
+// to pay what remains of the output, they are all spent to it with no change,
+// the final transaction pays the remainder and returns change to the wallet.
+// Only confirmed coins are spent so the change of one round is never needed by
+// the next. The txids of the transactions which were published are returned,
+// if an error occurs part way through then the txids of the transactions which
+// were published before the error are returned along with it.
+func (w *Wallet) SendLargePayment(
+	outputs []*wire.TxOut,
+	feeRate btcutil.Amount,
+) ([]*chainhash.Hash, er.R) {
+	return w.sendLargePayment(outputs, feeRate, MaxInputsPerTx)
+}
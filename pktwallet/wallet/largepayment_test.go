@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// TestSendLargePayment checks that an output which needs more inputs than fit
+// in one transaction is paid in full by a series of transactions.
+func TestSendLargePayment(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i), p2wkhAddr)},
+		})
+	}
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	// Four inputs are needed but only three fit in a transaction.
+	const value = 350000
+	txids, err := w.sendLargePayment([]*wire.TxOut{wire.NewTxOut(value, dest)}, 1000, 3)
+	if err != nil {
+		t.Fatalf("unable to send large payment: %v", err)
+	}
+	if len(txids) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txids))
+	}
+
+	var paid int64
+	for _, txid := range txids {
+		var details *wtxmgr.TxDetails
+		if err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			var err er.R
+			details, err = w.TxStore.TxDetails(ns, txid)
+			return err
+		}); err != nil || details == nil {
+			t.Fatalf("unable to find published tx [%s]: %v", txid, err)
+		}
+		for _, out := range details.MsgTx.TxOut {
+			if bytes.Equal(out.PkScript, dest) {
+				paid += out.Value
+			}
+		}
+	}
+	if paid != value {
+		t.Fatalf("expected [%d] to be paid in total, got [%d]", value, paid)
+	}
+
+	// What is left is not enough to pay again.
+	if _, err := w.sendLargePayment([]*wire.TxOut{wire.NewTxOut(value, dest)}, 1000, 3); err == nil {
+		t.Fatalf("expected error paying more than the wallet has")
+	}
+}
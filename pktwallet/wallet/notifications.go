@@ -63,7 +63,7 @@ func lookupInputAccount(dbtx walletdb.ReadTx, w *Wallet, details *wtxmgr.TxDetai
 		return 0
 	}
 	prevOut := prev.MsgTx.TxOut[prevOP.Index]
-	_, addrs, _, err := txscript.ExtractPkScriptAddrs(prevOut.PkScript, w.chainParams)
+	_, addrs, _, err := txscript.TxOutAddresses(prevOut, w.chainParams)
 	var inputAcct uint32
 	if err == nil && len(addrs) > 0 {
 		_, inputAcct, err = w.Manager.AddrAccount(addrmgrNs, addrs[0])
@@ -81,7 +81,7 @@ func lookupOutputChain(dbtx walletdb.ReadTx, w *Wallet, details *wtxmgr.TxDetail
 	addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
 
 	output := details.MsgTx.TxOut[cred.Index]
-	_, addrs, _, err := txscript.ExtractPkScriptAddrs(output.PkScript, w.chainParams)
+	_, addrs, _, err := txscript.TxOutAddresses(output, w.chainParams)
 	var ma waddrmgr.ManagedAddress
 	if err == nil && len(addrs) > 0 {
 		ma, err = w.Manager.Address(addrmgrNs, addrs[0])
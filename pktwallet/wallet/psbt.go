@@ -167,7 +167,7 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, account uint32,
 		if err != nil {
 			return 0, err
 		}
-		_, changeSource := w.addrMgrWithChangeSource(dbtx, account)
+		_, changeSource, _ := w.addrMgrWithChangeSource(dbtx, account)
 
 		// Ask the txauthor to create a transaction with our selected
 		// coins. This will perform fee estimation and add a change
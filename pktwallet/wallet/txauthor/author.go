@@ -6,8 +6,11 @@
 package txauthor
 
 import (
+	"bytes"
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/txscript/params"
@@ -16,6 +19,7 @@ import (
 
 	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/chaincfg"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/enough"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
 	"github.com/pkt-cash/pktd/txscript"
@@ -48,6 +52,29 @@ type AuthoredTx struct {
 	Tx          *wire.MsgTx
 	TotalInput  btcutil.Amount
 	ChangeIndex int // negative if no change
+
+	// DustAbsorbed is the change which was added to the fee rather than
+	// paid to a change output because it would have been dust.
+	DustAbsorbed btcutil.Amount
+
+	// FeeSatPerKB is the fee rate which the transaction was authored with,
+	// it may be higher than the rate which was requested.
+	FeeSatPerKB btcutil.Amount
+
+	// ChangeDerivations records the key scope and account of each wallet
+	// address which change is paid to, it is empty if there is no change
+	// or if the change does not pay to a wallet address.
+	ChangeDerivations []ChangeDerivation
+}
+
+// ChangeDerivation records where a change address was derived from, so that
+// the derivation can be tracked by the caller.
+type ChangeDerivation struct {
+	KeyScope waddrmgr.KeyScope
+	Account  uint32
+
+	// Address is the change address, nil if no change address was made.
+	Address btcutil.Address
 }
 
 // ChangeSource provides P2PKH change output scripts for transaction creation.
@@ -65,7 +92,8 @@ type ChangeSource func() ([]byte, er.R)
 // appended to the transaction outputs.  Since the change output may not be
 // necessary, fetchChange is called zero or one times to generate this script.
 // This function must return a P2WPKH script or smaller, otherwise fee estimation
-// will be incorrect.
+// will be incorrect. If the remaining value is dust then it is added to the fee
+// and reported in DustAbsorbed.
 //
 // If successful, the transaction, total input value spent, and all previous
 // output scripts are returned.  If the input source was unable to provide
@@ -92,7 +120,9 @@ func NewUnsignedTransaction(outputs []*wire.TxOut, relayFeePerKb btcutil.Amount,
 			return nil, err
 		}
 		if inputAmount < targetAmount+targetFee {
-			if partialOk && len(outputs) == 1 {
+			// Only fall back to a partial payment once, if the inputs
+			// cannot even pay the fee then the payment is impossible.
+			if partialOk && len(outputs) == 1 && sweepTo == nil {
 				targetAmount = 0
 				sweepTo = outputs[0]
 			} else {
@@ -141,6 +171,7 @@ func NewUnsignedTransaction(outputs []*wire.TxOut, relayFeePerKb btcutil.Amount,
 			Additional: inputAdditionals,
 		}
 		changeIndex := -1
+		dustAbsorbed := btcutil.Amount(0)
 		changeAmount := inputAmount - targetAmount - maxRequiredFee
 		if changeAmount != 0 && !txrules.IsDustAmount(changeAmount,
 			txsizes.P2WPKHPkScriptSize, txrules.DefaultRelayFeePerKb) {
@@ -156,12 +187,16 @@ func NewUnsignedTransaction(outputs []*wire.TxOut, relayFeePerKb btcutil.Amount,
 			l := len(outputs)
 			unsignedTransaction.TxOut = append(outputs[:l:l], change)
 			changeIndex = l
+		} else {
+			dustAbsorbed = changeAmount
 		}
 
 		return &AuthoredTx{
-			Tx:          unsignedTransaction,
-			TotalInput:  inputAmount,
-			ChangeIndex: changeIndex,
+			Tx:           unsignedTransaction,
+			TotalInput:   inputAmount,
+			ChangeIndex:  changeIndex,
+			DustAbsorbed: dustAbsorbed,
+			FeeSatPerKB:  relayFeePerKb,
 		}, nil
 	}
 }
@@ -175,12 +210,192 @@ func RandomizeOutputPosition(outputs []*wire.TxOut, index int) int {
 	return int(r)
 }
 
+// RandomizeOutputPositionWith is the same as RandomizeOutputPosition but it
+// takes randomness from r, this allows for deterministic output ordering in
+// tests.  r is not safe for concurrent use so the caller must synchronize it.
+func RandomizeOutputPositionWith(outputs []*wire.TxOut, index int, r *rand.Rand) int {
+	n := r.Int31n(int32(len(outputs)))
+	outputs[n], outputs[index] = outputs[index], outputs[n]
+	return int(n)
+}
+
 // RandomizeChangePosition randomizes the position of an authored transaction's
 // change output.  This should be done before signing.
 func (tx *AuthoredTx) RandomizeChangePosition() {
 	tx.ChangeIndex = RandomizeOutputPosition(tx.Tx.TxOut, tx.ChangeIndex)
 }
 
+// RandomizeChangePositionWith randomizes the position of an authored
+// transaction's change output using randomness from r.  This should be done
+// before signing.
+func (tx *AuthoredTx) RandomizeChangePositionWith(r *rand.Rand) {
+	tx.ChangeIndex = RandomizeOutputPositionWith(tx.Tx.TxOut, tx.ChangeIndex, r)
+}
+
+// splitChangeAmounts returns the value which is available to be divided between
+// change outputs having scripts of the given sizes in place of the change output
+// of tx, after paying the fee for the additional outputs, along with the least
+// amount which must be available for none of them to be dust.
+func splitChangeAmounts(
+	tx *AuthoredTx,
+	scriptSizes []int,
+	relayFeePerKb btcutil.Amount,
+) (btcutil.Amount, btcutil.Amount) {
+	change := tx.Tx.TxOut[tx.ChangeIndex]
+	count := len(tx.Tx.TxOut)
+	extraSize := wire.VarIntSerializeSize(uint64(count-1+len(scriptSizes))) -
+		wire.VarIntSerializeSize(uint64(count)) - change.SerializeSize()
+	var minimum btcutil.Amount
+	for _, size := range scriptSizes {
+		extraSize += 8 + wire.VarIntSerializeSize(uint64(size)) + size
+		minimum += txrules.GetDustThreshold(size, txrules.DefaultRelayFeePerKb)
+	}
+	available := btcutil.Amount(change.Value)
+	if extraSize > 0 {
+		available -= txrules.FeeForSerializeSize(relayFeePerKb, extraSize)
+	}
+	return available, minimum
+}
+
+// SplitChange replaces the change output of the transaction with n change
+// outputs paying to scripts from fetchChange, which is called n times, so that
+// the change is harder to tell apart from the payment. The fee for the extra
+// outputs is paid from the change at relayFeePerKb and what remains is divided
+// at random with none of the outputs being dust, each change output is placed
+// at a random position and ChangeIndex is set to one of them. If the change is
+// too small to be split n ways then an error is returned before fetchChange is
+// called and the transaction is unchanged. Randomness is taken from r, or from
+// a cryptographically seeded prng if r is nil, r is not safe for concurrent use
+// so the caller must synchronize it. This should be done before signing.
+func (tx *AuthoredTx) SplitChange(
+	n int,
+	fetchChange ChangeSource,
+	relayFeePerKb btcutil.Amount,
+	r *rand.Rand,
+) er.R {
+	if n < 2 {
+		return nil
+	} else if tx.ChangeIndex < 0 {
+		return er.New("transaction has no change output to split")
+	}
+	int63n := cprng.Int63n
+	if r != nil {
+		int63n = r.Int63n
+	}
+
+	// Check first assuming P2WPKH change scripts so that no change scripts
+	// are fetched for a split which is not possible.
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = txsizes.P2WPKHPkScriptSize
+	}
+	if available, minimum := splitChangeAmounts(tx, sizes, relayFeePerKb); available < minimum {
+		return er.Errorf("change of [%s] cannot be split into [%d] outputs, at least [%s] is needed",
+			btcutil.Amount(tx.Tx.TxOut[tx.ChangeIndex].Value).String(), n,
+			(minimum + btcutil.Amount(tx.Tx.TxOut[tx.ChangeIndex].Value) - available).String())
+	}
+	scripts := make([][]byte, n)
+	for i := range scripts {
+		script, err := fetchChange()
+		if err != nil {
+			return err
+		}
+		scripts[i] = script
+		sizes[i] = len(script)
+	}
+	available, minimum := splitChangeAmounts(tx, sizes, relayFeePerKb)
+	if available < minimum {
+		return er.Errorf("change of [%s] cannot be split into [%d] outputs with the given scripts",
+			btcutil.Amount(tx.Tx.TxOut[tx.ChangeIndex].Value).String(), n)
+	}
+
+	// Cut what is left above the dust limits at n-1 random points.
+	surplus := int64(available - minimum)
+	cuts := make([]int64, n+1)
+	for i := 1; i < n; i++ {
+		cuts[i] = int63n(surplus + 1)
+	}
+	cuts[n] = surplus
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+
+	outputs := make([]*wire.TxOut, 0, len(tx.Tx.TxOut)-1+n)
+	outputs = append(outputs, tx.Tx.TxOut[:tx.ChangeIndex]...)
+	outputs = append(outputs, tx.Tx.TxOut[tx.ChangeIndex+1:]...)
+	changeIndex := -1
+	for i, script := range scripts {
+		value := int64(txrules.GetDustThreshold(len(script), txrules.DefaultRelayFeePerKb)) +
+			cuts[i+1] - cuts[i]
+		pos := int(int63n(int64(len(outputs) + 1)))
+		outputs = append(outputs, nil)
+		copy(outputs[pos+1:], outputs[pos:])
+		outputs[pos] = wire.NewTxOut(value, script)
+		if changeIndex < 0 {
+			changeIndex = pos
+		} else if changeIndex >= pos {
+			changeIndex++
+		}
+	}
+	tx.Tx.TxOut = outputs
+	tx.ChangeIndex = changeIndex
+	return nil
+}
+
+// bip69Inputs sorts the inputs of a transaction according to BIP 69 while
+// keeping the Additional info of each input aligned with it.
+type bip69Inputs struct {
+	tx *wire.MsgTx
+}
+
+func (s bip69Inputs) Len() int { return len(s.tx.TxIn) }
+
+func (s bip69Inputs) Swap(i, j int) {
+	s.tx.TxIn[i], s.tx.TxIn[j] = s.tx.TxIn[j], s.tx.TxIn[i]
+	if len(s.tx.Additional) == len(s.tx.TxIn) {
+		s.tx.Additional[i], s.tx.Additional[j] = s.tx.Additional[j], s.tx.Additional[i]
+	}
+}
+
+// Less sorts by the previous output hash, in reversed (rpc-style) byte order,
+// then by the previous output index.
+func (s bip69Inputs) Less(i, j int) bool {
+	iop := s.tx.TxIn[i].PreviousOutPoint
+	jop := s.tx.TxIn[j].PreviousOutPoint
+	if iop.Hash == jop.Hash {
+		return iop.Index < jop.Index
+	}
+	for b := len(iop.Hash) - 1; b >= 0; b-- {
+		if iop.Hash[b] != jop.Hash[b] {
+			return iop.Hash[b] < jop.Hash[b]
+		}
+	}
+	return false
+}
+
+// SortBip69 sorts the inputs of an authored transaction by outpoint and the
+// outputs by value then script, as described in BIP 69, keeping Additional
+// aligned with the inputs and ChangeIndex pointing at the change output.
+// This should be done before signing and in place of randomizing the change
+// position.
+func (tx *AuthoredTx) SortBip69() {
+	var change *wire.TxOut
+	if tx.ChangeIndex >= 0 {
+		change = tx.Tx.TxOut[tx.ChangeIndex]
+	}
+	sort.Sort(bip69Inputs{tx.Tx})
+	sort.SliceStable(tx.Tx.TxOut, func(i, j int) bool {
+		a, b := tx.Tx.TxOut[i], tx.Tx.TxOut[j]
+		if a.Value == b.Value {
+			return bytes.Compare(a.PkScript, b.PkScript) < 0
+		}
+		return a.Value < b.Value
+	})
+	for i, out := range tx.Tx.TxOut {
+		if out == change {
+			tx.ChangeIndex = i
+		}
+	}
+}
+
 // SecretsSource provides private keys and redeem scripts necessary for
 // constructing transaction input signatures.  Secrets are looked up by the
 // corresponding Address for the previous output script.  Addresses for lookup
@@ -5,13 +5,17 @@
 package txauthor_test
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/btcutil/txsort"
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
 	. "github.com/pkt-cash/pktd/pktwallet/wallet/txauthor"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
 	"github.com/pkt-cash/pktd/wire"
+	"github.com/pkt-cash/pktd/wire/constants"
 
 	"github.com/pkt-cash/pktd/pktwallet/wallet/internal/txsizes"
 )
@@ -223,3 +227,181 @@ func TestNewUnsignedTransaction(t *testing.T) {
 		}
 	}
 }
+
+func TestNewUnsignedTransactionPartial(t *testing.T) {
+	changeSource := func() ([]byte, er.R) {
+		return make([]byte, txsizes.P2WPKHPkScriptSize), nil
+	}
+	relayFee := txrules.DefaultRelayFeePerKb
+
+	// The inputs are not enough so they are all paid to the output.
+	outputs := p2pkhOutputs(1e8)
+	tx, err := NewUnsignedTransaction(outputs, relayFee,
+		makeInputSource(p2pkhOutputs(1e6, 2e6)), changeSource, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tx.ChangeIndex >= 0 {
+		t.Errorf("Partial payment should not have change")
+	}
+	if len(tx.Tx.TxIn) != 2 {
+		t.Errorf("Used %d outputs from input source, Expected 2", len(tx.Tx.TxIn))
+	}
+	if v := tx.Tx.TxOut[0].Value; v <= 0 || v >= 3e6 {
+		t.Errorf("Partial payment of %d is not the inputs less the fee", v)
+	}
+
+	// The inputs cannot even pay the fee.
+	_, err = NewUnsignedTransaction(p2pkhOutputs(1e8), relayFee,
+		makeInputSource(p2pkhOutputs(10)), changeSource, true)
+	if !ImpossibleTxError.Is(err) {
+		t.Errorf("Expected ImpossibleTxError, got %v", err)
+	}
+	_, err = NewUnsignedTransaction(p2pkhOutputs(1e8), relayFee,
+		makeInputSource(nil), changeSource, true)
+	if !ImpossibleTxError.Is(err) {
+		t.Errorf("Expected ImpossibleTxError, got %v", err)
+	}
+}
+
+func TestNewUnsignedTransactionDustAbsorbed(t *testing.T) {
+	changeSource := func() ([]byte, er.R) {
+		return make([]byte, txsizes.P2WPKHPkScriptSize), nil
+	}
+	relayFee := txrules.DefaultRelayFeePerKb
+
+	// With no Additional pkScript the input is estimated as p2pkh.
+	fee := txrules.FeeForSerializeSize(relayFee,
+		txsizes.EstimateVirtualSize(1, 0, 0, p2pkhOutputs(1e6), true))
+	for _, leftover := range []btcutil.Amount{0, 1, 100} {
+		tx, err := NewUnsignedTransaction(p2pkhOutputs(1e6-fee-leftover), relayFee,
+			makeInputSource(p2pkhOutputs(1e6)), changeSource, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if tx.ChangeIndex >= 0 {
+			t.Errorf("Leftover %v: dust change output was added", leftover)
+		}
+		if tx.DustAbsorbed != leftover {
+			t.Errorf("Leftover %v: got DustAbsorbed %v", leftover, tx.DustAbsorbed)
+		}
+	}
+
+	// Change which is not dust is not absorbed.
+	tx, err := NewUnsignedTransaction(p2pkhOutputs(1e6-fee-1e5), relayFee,
+		makeInputSource(p2pkhOutputs(1e6)), changeSource, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tx.ChangeIndex < 0 || tx.DustAbsorbed != 0 {
+		t.Errorf("Expected change output and no DustAbsorbed, got change index %d and %v",
+			tx.ChangeIndex, tx.DustAbsorbed)
+	}
+}
+
+func TestSplitChange(t *testing.T) {
+	relayFee := txrules.DefaultRelayFeePerKb
+	fetched := 0
+	changeSource := func() ([]byte, er.R) {
+		fetched++
+		script := make([]byte, txsizes.P2WPKHPkScriptSize)
+		script[0] = byte(fetched)
+		return script, nil
+	}
+	author := func() *AuthoredTx {
+		tx, err := NewUnsignedTransaction(p2pkhOutputs(1e6, 2e6), relayFee,
+			makeInputSource(p2pkhOutputs(1e7)), changeSource, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return tx
+	}
+
+	tx := author()
+	change := tx.Tx.TxOut[tx.ChangeIndex].Value
+	fetched = 0
+	if err := tx.SplitChange(3, changeSource, relayFee, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fetched != 3 || len(tx.Tx.TxOut) != 5 {
+		t.Fatalf("Expected 3 change scripts and 5 outputs, got %d and %d",
+			fetched, len(tx.Tx.TxOut))
+	}
+	if tx.Tx.TxOut[tx.ChangeIndex].PkScript[0] == 0 {
+		t.Errorf("ChangeIndex %d does not point to a change output", tx.ChangeIndex)
+	}
+	var sum int64
+	for _, out := range tx.Tx.TxOut {
+		if out.PkScript[0] == 0 {
+			continue
+		}
+		if txrules.IsDustOutput(out, relayFee) {
+			t.Errorf("Change output of %d is dust", out.Value)
+		}
+		sum += out.Value
+	}
+	extraFee := int64(txrules.FeeForSerializeSize(relayFee, 2*(8+1+txsizes.P2WPKHPkScriptSize)))
+	if sum != change-extraFee {
+		t.Errorf("Change outputs sum to %d, want %d", sum, change-extraFee)
+	}
+
+	// Change which is too small to split is left alone.
+	tx, err := NewUnsignedTransaction(p2pkhOutputs(1e6), relayFee,
+		makeInputSource(p2pkhOutputs(1e6+1000)), changeSource, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tx.ChangeIndex < 0 {
+		t.Fatalf("Expected a change output")
+	}
+	fetched = 0
+	if err := tx.SplitChange(2, changeSource, relayFee, nil); err == nil {
+		t.Fatalf("Expected error splitting small change")
+	}
+	if fetched != 0 || len(tx.Tx.TxOut) != 2 {
+		t.Errorf("Transaction was modified by a failed split")
+	}
+}
+
+func TestSortBip69(t *testing.T) {
+	values := []int64{1, 2, 3, 4}
+	hashes := []chainhash.Hash{{0x02}, {0x01}, {0x00, 0x01}, {0x01}}
+	indexes := []uint32{0, 1, 0, 0}
+	msgTx := wire.NewMsgTx(constants.TxVersion)
+	for i := range values {
+		msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: hashes[i], Index: indexes[i]}, nil, nil))
+		msgTx.Additional = append(msgTx.Additional, wire.TxInAdditional{Value: &values[i]})
+	}
+	msgTx.AddTxOut(wire.NewTxOut(5000, []byte{0x02}))
+	msgTx.AddTxOut(wire.NewTxOut(3000, []byte{0x01}))
+	msgTx.AddTxOut(wire.NewTxOut(5000, []byte{0x01}))
+	change := msgTx.TxOut[0]
+	tx := &AuthoredTx{Tx: msgTx, ChangeIndex: 0}
+
+	tx.SortBip69()
+	if !txsort.IsSorted(msgTx) {
+		t.Fatalf("Transaction is not sorted according to BIP 69")
+	}
+	if msgTx.TxOut[tx.ChangeIndex] != change {
+		t.Errorf("ChangeIndex %d does not point to the change output", tx.ChangeIndex)
+	}
+	for i, in := range msgTx.TxIn {
+		want := -1
+		for j := range hashes {
+			if hashes[j] == in.PreviousOutPoint.Hash && indexes[j] == in.PreviousOutPoint.Index {
+				want = j
+			}
+		}
+		if *msgTx.Additional[i].Value != values[want] {
+			t.Errorf("Input %d has Additional value %d, want %d",
+				i, *msgTx.Additional[i].Value, values[want])
+		}
+	}
+
+	// Without change the ChangeIndex stays negative.
+	tx = &AuthoredTx{Tx: msgTx.Copy(), ChangeIndex: -1}
+	tx.SortBip69()
+	if tx.ChangeIndex != -1 {
+		t.Errorf("ChangeIndex %d, want -1", tx.ChangeIndex)
+	}
+}
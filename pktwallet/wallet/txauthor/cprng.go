@@ -37,3 +37,9 @@ func (c *cprngType) Int31n(n int32) int32 {
 	c.mu.Lock()
 	return c.r.Int31n(n)
 }
+
+func (c *cprngType) Int63n(n int64) int64 {
+	defer c.mu.Unlock() // Int63n may panic
+	c.mu.Lock()
+	return c.r.Int63n(n)
+}
@@ -105,3 +105,17 @@ func FeeForSerializeSize(relayFeePerKb btcutil.Amount, txSerializeSize int) btcu
 
 	return fee
 }
+
+// MinFee returns the fee which tx must pay to meet a fee rate of feePerKb. The
+// fee is calculated from the virtual size of the transaction, so witness data
+// is discounted, and it is rounded up so that the rate is never undershot.
+func MinFee(tx *wire.MsgTx, feePerKb btcutil.Amount) btcutil.Amount {
+	weight := int64(tx.SerializeSizeStripped()*(blockchain.WitnessScaleFactor-1) +
+		tx.SerializeSize())
+	vsize := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+	fee := (int64(feePerKb)*vsize + 999) / 1000
+	if fee < 0 || fee > int64(btcutil.MaxUnits()) {
+		return btcutil.MaxUnits()
+	}
+	return btcutil.Amount(fee)
+}
@@ -0,0 +1,48 @@
+package txrules
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkt-cash/pktd/blockchain"
+	"github.com/pkt-cash/pktd/btcutil"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+func TestMinFee(t *testing.T) {
+	legacy := wire.NewMsgTx(1)
+	legacy.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, bytes.Repeat([]byte{0x01}, 107), nil))
+	legacy.AddTxOut(wire.NewTxOut(1000, bytes.Repeat([]byte{0x02}, 25)))
+
+	witness := wire.NewMsgTx(1)
+	witness.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil,
+		[][]byte{bytes.Repeat([]byte{0x03}, 72), bytes.Repeat([]byte{0x04}, 33)}))
+	witness.AddTxOut(wire.NewTxOut(1000, bytes.Repeat([]byte{0x02}, 22)))
+
+	for _, tx := range []*wire.MsgTx{legacy, witness} {
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+		vsize := (weight + 3) / 4
+		for _, rate := range []btcutil.Amount{1, 1000, 1234, 100000} {
+			want := btcutil.Amount((int64(rate)*vsize + 999) / 1000)
+			if got := MinFee(tx, rate); got != want {
+				t.Errorf("MinFee(vsize %d, rate %d): got %d, want %d", vsize, rate, got, want)
+			}
+		}
+	}
+
+	// Legacy transactions get no discount.
+	if got, want := MinFee(legacy, 1000), btcutil.Amount(legacy.SerializeSize()); got != want {
+		t.Errorf("MinFee of legacy tx: got %d, want %d", got, want)
+	}
+
+	// Witness data is discounted.
+	if got := MinFee(witness, 1000); got >= btcutil.Amount(witness.SerializeSize()) {
+		t.Errorf("MinFee of witness tx: got %d, expected less than its size %d",
+			got, witness.SerializeSize())
+	}
+
+	// Any non-zero rate rounds up to at least one unit.
+	if got := MinFee(legacy, 1); got != 1 {
+		t.Errorf("MinFee at rate 1: got %d, want 1", got)
+	}
+}
@@ -6,8 +6,15 @@
 package wallet
 
 import (
+	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/pktlog/log"
 	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/unspent"
 	"github.com/pkt-cash/pktd/txscript"
 	"github.com/pkt-cash/pktd/wire"
 )
@@ -67,6 +74,110 @@ func (w *Wallet) FetchInputInfo(prevOut *wire.OutPoint) (*wire.MsgTx,
 	}, confs, nil
 }
 
+// IsOutputBurned reports whether the unspent output op would be burned if it
+// were spent in a block at atHeight. Only network steward coinbase outputs
+// which are left unspent for too long are burned, so outputs of unmined
+// transactions and of normal transactions are never burned. Because a
+// transaction may take some time to be mined, callers checking an output
+// before spending it should pass a height somewhat above the current tip.
+// If the output is not a known unspent output of the wallet then ErrNotMine
+// is returned.
+func (w *Wallet) IsOutputBurned(op wire.OutPoint, atHeight int32) (bool, er.R) {
+	burned := false
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		if uns, err := unspent.Get(txmgrNs, &op); err != nil {
+			return err
+		} else if uns != nil {
+			burned = txrules.IsBurned(uns, w.chainParams, atHeight)
+		} else if pkScript, err := wtxmgr.AddressForOutPoint(txmgrNs, &op); err != nil {
+			return err
+		} else if pkScript == nil {
+			return ErrNotMine.Default()
+		}
+		return nil
+	})
+	return burned, err
+}
+
+// SpendableOutput describes an unspent output of the wallet for coin control,
+// along with the reasons why it might not be selected as an input.
+type SpendableOutput struct {
+	OutPoint      wire.OutPoint
+	Value         btcutil.Amount
+	Address       string
+	Confirmations int32
+
+	// Immature is set for a coinbase output which has not yet reached
+	// coinbase maturity.
+	Immature bool
+
+	// Burned is set for a network steward coinbase output which is left
+	// unspent for too long to be spent, see IsOutputBurned.
+	Burned bool
+
+	// Locked is set for an output which was locked with LockOutpoint.
+	Locked bool
+}
+
+// ListSpendableOutputs returns every unspent output of account which has at
+// least minconf confirmations, classified in the same way as when inputs are
+// selected for a transaction. Nothing is selected and, unlike when creating a
+// transaction, burned outputs are reported rather than deleted.
+func (w *Wallet) ListSpendableOutputs(account uint32, minconf int32) ([]SpendableOutput, er.R) {
+	chainClient, err := w.requireChainClient()
+	if err != nil {
+		return nil, err
+	}
+	bs, err := chainClient.BlockStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []SpendableOutput
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+
+		accounts := make(map[string]bool)
+		_, err := w.TxStore.ForEachUnspentOutput(txmgrNs, nil, nil, func(_ []byte, uns *dbstructs.Unspent) er.R {
+			confs := confirms(uns.Block.Height, bs.Height)
+			if confs < minconf {
+				return nil
+			}
+			inAccount, ok := accounts[uns.Address]
+			if !ok {
+				if addr, err := btcutil.DecodeAddress(uns.Address, w.chainParams); err != nil {
+					log.Warnf("Unable to decode address [%s] from utxo [%s]",
+						uns.Address, uns.OutPoint.String())
+				} else if _, acct, err := w.Manager.AddrAccount(addrmgrNs, addr); err != nil {
+					log.Debugf("Unable to find account of address [%s]: [%s]",
+						uns.Address, err.String())
+				} else {
+					inAccount = acct == account
+				}
+				accounts[uns.Address] = inAccount
+			}
+			if !inAccount {
+				return nil
+			}
+			immature := w.isImmature(uns, bs.Height)
+			out = append(out, SpendableOutput{
+				OutPoint:      uns.OutPoint,
+				Value:         btcutil.Amount(uns.Value),
+				Address:       uns.Address,
+				Confirmations: confs,
+				Immature:      immature,
+				Burned:        !immature && w.isBurned(uns, bs.Height),
+				Locked:        w.LockedOutpoint(uns.OutPoint),
+			})
+			return nil
+		})
+		return err
+	})
+	return out, err
+}
+
 // fetchOutputAddr attempts to fetch the managed address corresponding to the
 // passed output script. This function is used to look up the proper key which
 // should be used to sign a specified input.
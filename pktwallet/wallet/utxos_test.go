@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/pkt-cash/pktd/blockchain"
+	"github.com/pkt-cash/pktd/chaincfg"
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/wire"
+	"github.com/pkt-cash/pktd/wire/constants"
+)
+
+// TestIsOutputBurned checks that only a network steward coinbase output which
+// is left unspent for too long is reported as burned.
+func TestIsOutputBurned(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// The burn rule only applies to chains which have a network steward.
+	w.chainParams = &chaincfg.PktTestNetParams
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+
+	stewardPayout := blockchain.PktCalcNetworkStewardPayout(
+		blockchain.CalcBlockSubsidy(testBlockHeight, w.chainParams))
+	coinbase := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: constants.MaxPrevOutIndex},
+		}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(stewardPayout, p2wkhAddr)},
+	}
+	addUtxo(t, w, coinbase)
+	normal := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(stewardPayout, p2wkhAddr)},
+	}
+	addUtxo(t, w, normal)
+
+	coinbaseOp := wire.OutPoint{Hash: coinbase.TxHash(), Index: 0}
+	normalOp := wire.OutPoint{Hash: normal.TxHash(), Index: 0}
+	tests := []struct {
+		name     string
+		op       wire.OutPoint
+		atHeight int32
+		burned   bool
+	}{
+		{"burned coinbase", coinbaseOp, testBlockHeight + 129600, true},
+		{"unburned coinbase", coinbaseOp, testBlockHeight + 129599, false},
+		{"non-coinbase", normalOp, testBlockHeight + 129600, false},
+	}
+	for _, test := range tests {
+		burned, err := w.IsOutputBurned(test.op, test.atHeight)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if burned != test.burned {
+			t.Fatalf("%s: got burned %v, want %v", test.name, burned,
+				test.burned)
+		}
+	}
+
+	unknown := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 0}
+	if _, err := w.IsOutputBurned(unknown, testBlockHeight); !ErrNotMine.Is(err) {
+		t.Fatalf("expected ErrNotMine for unknown output, got %v", err)
+	}
+}
+
+// TestListSpendableOutputs checks that the unspent outputs of an account are
+// listed with their locked and immature state and that minconf is respected.
+func TestListSpendableOutputs(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+
+	bs, err := w.chainClient.BlockStamp()
+	if err != nil {
+		t.Fatalf("unable to get block stamp: %v", err)
+	}
+
+	normal := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(100000, p2wkhAddr)},
+	}
+	addUtxo(t, w, normal)
+	locked := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(200000, p2wkhAddr)},
+	}
+	addUtxo(t, w, locked)
+	coinbase := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: constants.MaxPrevOutIndex},
+		}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(300000, p2wkhAddr)},
+	}
+	addUtxoAt(t, w, coinbase, bs.Height-9)
+
+	lockedOp := wire.OutPoint{Hash: locked.TxHash(), Index: 0}
+	w.LockOutpoint(lockedOp, "test")
+
+	outputs, err := w.ListSpendableOutputs(0, 1)
+	if err != nil {
+		t.Fatalf("unable to list outputs: %v", err)
+	}
+	if len(outputs) != 3 {
+		t.Fatalf("expected 3 outputs, got %d", len(outputs))
+	}
+	for _, o := range outputs {
+		var immature, isLocked bool
+		switch o.OutPoint.Hash {
+		case normal.TxHash():
+		case locked.TxHash():
+			isLocked = true
+		case coinbase.TxHash():
+			immature = true
+			if o.Confirmations != 10 {
+				t.Fatalf("expected coinbase to have 10 confirmations, got %d",
+					o.Confirmations)
+			}
+		default:
+			t.Fatalf("unexpected output [%s]", o.OutPoint.String())
+		}
+		if o.Immature != immature || o.Locked != isLocked || o.Burned {
+			t.Fatalf("output [%s]: got immature %v locked %v burned %v",
+				o.OutPoint.String(), o.Immature, o.Locked, o.Burned)
+		}
+		if o.Address != addr.EncodeAddress() {
+			t.Fatalf("output [%s]: unexpected address [%s]",
+				o.OutPoint.String(), o.Address)
+		}
+	}
+
+	// The coinbase output does not have enough confirmations.
+	outputs, err = w.ListSpendableOutputs(0, 11)
+	if err != nil {
+		t.Fatalf("unable to list outputs: %v", err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 outputs with minconf 11, got %d", len(outputs))
+	}
+
+	// No outputs belong to another account.
+	outputs, err = w.ListSpendableOutputs(1, 1)
+	if err != nil {
+		t.Fatalf("unable to list outputs: %v", err)
+	}
+	if len(outputs) != 0 {
+		t.Fatalf("expected no outputs in account 1, got %d", len(outputs))
+	}
+}
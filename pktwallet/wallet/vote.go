@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"bytes"
+
+	"github.com/pkt-cash/pktd/blockchain/votecompute/votes"
+	"github.com/pkt-cash/pktd/btcutil"
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// MixedVoteInputsError is returned when a vote transaction would need to spend
+// coins from more than one address, such a vote cannot be attributed to a
+// single voter.
+var MixedVoteInputsError = er.GenericErrorType.CodeWithDetail("MixedVoteInputsError",
+	"unable to cast vote because the transaction would spend coins from more than one address")
+
+// voteData creates the payload of the OP_RETURN output which casts a vote for
+// the target pkScript, op is one of votes.VOTE, votes.CANDIDATE or
+// votes.WITHDRAW.
+func voteData(target []byte, op byte) []byte {
+	return votes.DefaultNamespace.EncodeVote(op, target)
+}
+
+// castVoteReq creates the transaction request for a vote transaction, all
+// inputs come from fromAddress and change is returned to it.
+func castVoteReq(
+	fromAddress btcutil.Address,
+	target []byte,
+	op byte,
+	feeRate btcutil.Amount,
+) CreateTxReq {
+	return CreateTxReq{
+		InputAddresses: []btcutil.Address{fromAddress},
+		Minconf:        1,
+		FeeSatPerKB:    feeRate,
+		SendMode:       SendModeSigned,
+		ChangeAddress:  &fromAddress,
+		MaxInputs:      -1,
+		Label:          "vote",
+		DataOutputs:    [][]byte{voteData(target, op)},
+	}
+}
+
+// checkVoteInputs makes sure that every input of a vote transaction spends
+// from fromScript so that the vote is attributed to the right address.
+func checkVoteInputs(tx *wire.MsgTx, fromScript []byte) er.R {
+	if len(tx.Additional) != len(tx.TxIn) {
+		return er.Errorf("len(tx.Additional) = [%d] but len(tx.TxIn) = [%d], cannot check vote",
+			len(tx.Additional), len(tx.TxIn))
+	}
+	for _, add := range tx.Additional {
+		if !bytes.Equal(add.PkScript, fromScript) {
+			return MixedVoteInputsError.Default()
+		}
+	}
+	return nil
+}
+
+// CastVote creates, signs and broadcasts a transaction which casts a network
+// steward vote from fromAddress for the address having the pkScript target.
+// The op is votes.VOTE for a plain vote, votes.CANDIDATE if fromAddress also
+// declares itself as willing to be a candidate or votes.WITHDRAW if it
+// withdraws a previous candidacy. All inputs of the transaction are taken from
+// fromAddress and change is returned to it, if the transaction would need coins
+// from any other address then MixedVoteInputsError is returned and nothing is
+// broadcasted.
+func (w *Wallet) CastVote(
+	fromAddress btcutil.Address,
+	target []byte,
+	op byte,
+	feeRate btcutil.Amount,
+) (*chainhash.Hash, er.R) {
+	if op != votes.VOTE && op != votes.CANDIDATE && op != votes.WITHDRAW {
+		return nil, er.Errorf("CastVote: unknown vote operation [%d]", op)
+	}
+	fromScript, err := txscript.PayToAddrScript(fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	txr := castVoteReq(fromAddress, target, op, feeRate)
+	tx, err := w.CreateSimpleTx(txr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVoteInputs(tx.Tx, fromScript); err != nil {
+		return nil, err
+	}
+	return w.ReliablyPublishTransaction(tx.Tx, txr.Label)
+}
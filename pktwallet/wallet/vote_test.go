@@ -0,0 +1,153 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/pktd/blockchain/votecompute/votes"
+	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/walletdb"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/wire"
+)
+
+// TestCastVoteTx checks that a vote transaction spends only from the voting
+// address and that the wallet recognizes the vote once it is mined.
+func TestCastVoteTx(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fromAddr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	fromScript, err := txscript.PayToAddrScript(fromAddr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	otherAddr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	otherScript, err := txscript.PayToAddrScript(otherAddr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, fromScript)},
+	})
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(5000000, otherScript)},
+	})
+
+	txr := castVoteReq(fromAddr, otherScript, votes.CANDIDATE, 1000)
+	tx, err := w.txToOutputs(txr)
+	if err != nil {
+		t.Fatalf("unable to author vote tx: %v", err)
+	}
+	if err := checkVoteInputs(tx.Tx, fromScript); err != nil {
+		t.Fatalf("vote tx spends from the wrong address: %v", err)
+	}
+	if err := checkVoteInputs(tx.Tx, otherScript); !MixedVoteInputsError.Is(err) {
+		t.Fatalf("expected MixedVoteInputsError, got %v", err)
+	}
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx.Tx, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	block := &wtxmgr.BlockMeta{
+		Block: dbstructs.Block{
+			Hash:   *testBlockHash,
+			Height: testBlockHeight + 1,
+		},
+		Time: time.Unix(1387737910, 0),
+	}
+	var vote *wtxmgr.DbNsVote2
+	if err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) er.R {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := w.TxStore.InsertTx(ns, rec, block); err != nil {
+			return err
+		}
+		vote, err = wtxmgr.FetchAddressNsVote(ns, fromAddr)
+		return err
+	}); err != nil {
+		t.Fatalf("failed inserting vote tx: %v", err)
+	}
+	if vote == nil {
+		t.Fatalf("vote was not recorded for [%s]", fromAddr)
+	}
+	if !vote.IsCandidate {
+		t.Fatalf("expected vote to declare candidacy")
+	}
+	if vote.VoteFor != otherAddr.String() {
+		t.Fatalf("expected vote for [%s], got [%s]", otherAddr, vote.VoteFor)
+	}
+	if vote.VoteTxid != tx.Tx.TxHash().String() {
+		t.Fatalf("unexpected vote txid [%s]", vote.VoteTxid)
+	}
+}
+
+// TestCastVoteWithdraw checks that an explicit withdrawal of candidacy is
+// stored with the vote and is not mistaken for a candidacy declaration.
+func TestCastVoteWithdraw(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fromAddr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	fromScript, err := txscript.PayToAddrScript(fromAddr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(2000000, fromScript)},
+	})
+
+	if _, err := w.CastVote(fromAddr, fromScript, 0x03, 1000); err == nil {
+		t.Fatalf("expected unknown vote operation to be rejected")
+	}
+
+	tx, err := w.txToOutputs(castVoteReq(fromAddr, fromScript, votes.WITHDRAW, 1000))
+	if err != nil {
+		t.Fatalf("unable to author vote tx: %v", err)
+	}
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx.Tx, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	block := &wtxmgr.BlockMeta{
+		Block: dbstructs.Block{
+			Hash:   *testBlockHash,
+			Height: testBlockHeight + 1,
+		},
+		Time: time.Unix(1387737910, 0),
+	}
+	var vote *wtxmgr.DbNsVote2
+	if err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) er.R {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := w.TxStore.InsertTx(ns, rec, block); err != nil {
+			return err
+		}
+		vote, err = wtxmgr.FetchAddressNsVote(ns, fromAddr)
+		return err
+	}); err != nil {
+		t.Fatalf("failed inserting vote tx: %v", err)
+	}
+	if vote == nil {
+		t.Fatalf("vote was not recorded for [%s]", fromAddr)
+	}
+	if vote.IsCandidate || !vote.WithdrawsCandidacy {
+		t.Fatalf("withdrawal stored as candidate=%v withdraw=%v",
+			vote.IsCandidate, vote.WithdrawsCandidacy)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math/rand"
 	"sort"
 	"strings"
 	"sync"
@@ -31,6 +32,7 @@ import (
 	"github.com/pkt-cash/pktd/chaincfg/globalcfg"
 	"github.com/pkt-cash/pktd/pktwallet/chain"
 	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
+	"github.com/pkt-cash/pktd/pktwallet/wallet/internal/txsizes"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/seedwords"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/txauthor"
 	"github.com/pkt-cash/pktd/pktwallet/wallet/txrules"
@@ -141,6 +143,26 @@ type Wallet struct {
 
 	rescanJLock sync.Mutex
 	rescanJ     *rescanJob
+
+	// changeRand, if non-nil, is used in place of the cryptographically
+	// seeded prng for placing the change output, see SetChangePositionSource.
+	changeRandMtx sync.Mutex
+	changeRand    *rand.Rand
+
+	// burnHorizon is the number of blocks ahead of the current height at
+	// which coinbase outputs are checked for being burned, it is accessed
+	// atomically, see SetBurnHorizon.
+	burnHorizon int32
+
+	// chainRetries and chainRetryBackoff control how fetching the best
+	// block is retried when creating a transaction, they are accessed
+	// atomically, see SetChainRetry.
+	chainRetries      int32
+	chainRetryBackoff int64
+
+	// relayFee is the minimum fee rate in satoshis per kB which peers will
+	// relay, it is accessed atomically, see SetRelayFee.
+	relayFee int64
 }
 
 type rescanJob struct {
@@ -636,8 +658,9 @@ func getBlockStamp(chainClient chain.Interface, height int32) (*waddrmgr.BlockSt
 }
 
 type (
-	SendMode    uint8
-	CreateTxReq struct {
+	SendMode      uint8
+	SelectionGoal uint8
+	CreateTxReq   struct {
 		InputAddresses  []btcutil.Address
 		Outputs         []*wire.TxOut
 		Minconf         int32
@@ -648,6 +671,80 @@ type (
 		InputComparator utils.Comparator
 		MaxInputs       int
 		Label           string
+
+		// DataOutputs are payloads which will each be carried in a
+		// zero-value OP_RETURN output, for example a vote.
+		DataOutputs [][]byte
+
+		// AllowUnconfirmedChainLimit, if greater than zero, prevents
+		// spending unconfirmed change when doing so would create a chain
+		// of more than this many unconfirmed wallet transactions.
+		AllowUnconfirmedChainLimit int
+
+		// ChangeScript, if set, is the pkScript which change will be paid
+		// to, overriding ChangeAddress. It need not belong to the wallet
+		// but it must be a standard script.
+		ChangeScript []byte
+
+		// SelectionGoal determines which outputs are preferred as inputs,
+		// it is ignored if InputComparator is set.
+		SelectionGoal SelectionGoal
+
+		// PreSignHook, if set, is called with the transaction after input
+		// selection and change randomization but before it is signed, it
+		// may adjust the outputs as long as the fee remains sufficient.
+		// If it returns an error then creating the transaction fails.
+		PreSignHook func(*txauthor.AuthoredTx) er.R
+
+		// AllowNonStandard permits outputs which pay to scripts that
+		// peers do not consider standard, such a transaction may not be
+		// relayed.
+		AllowNonStandard bool
+
+		// InputSequence, if set, is used as the sequence number of every
+		// input, for example wire.SequenceForRelativeBlocks to spend
+		// outputs which are encumbered by a relative locktime (CSV). If
+		// it enables a relative locktime then the transaction is made
+		// version 2 so that the locktime is enforced per BIP0068.
+		InputSequence *uint32
+
+		// MaxFeeFraction is the fraction of the value being sent which the
+		// fee may be before a warning is logged, if zero then
+		// DefaultMaxFeeFraction is used. If RejectHighFee is set then
+		// creating the transaction fails with HighFeeError instead.
+		MaxFeeFraction float64
+		RejectHighFee  bool
+
+		// ChangeSplit, if greater than 1, divides the change between this
+		// many outputs paying random amounts to new internal addresses,
+		// so that the change is harder to tell apart from the payment.
+		// It cannot be used with ChangeAddress or ChangeScript.
+		ChangeSplit int
+
+		// LockTime, if non-zero, is the locktime of the transaction, if it
+		// is LockTimeCurrentHeight then the current block height is used.
+		// If every input is final then their sequence numbers are reduced
+		// so that the locktime is enforced.
+		LockTime uint32
+
+		// SortBip69, if true, sorts the inputs and outputs as described in
+		// BIP 69 before signing, rather than placing the change output at
+		// a random position.
+		SortBip69 bool
+
+		// ExternalInputs are spent in addition to the wallet's own coins,
+		// they are not tracked by the wallet so their scripts and values
+		// are taken from here. The transaction is not signed so SendMode
+		// must be SendModeUnsigned or SendModeEptf.
+		ExternalInputs []ExternalInput
+
+		// DryRun, if true, authors the transaction without signing it
+		// and rolls back the database transaction so that nothing is
+		// written, no change addresses are derived and no outputs are
+		// deleted. SendMode must be SendModeUnsigned. If ChangeSplit is
+		// used then the change outputs pay to placeholder scripts of the
+		// same size as the real ones.
+		DryRun bool
 	}
 	createTxRequest struct {
 		req  CreateTxReq
@@ -663,6 +760,24 @@ const (
 	SendModeUnsigned SendMode = 0
 	SendModeSigned   SendMode = 1
 	SendModeBcasted  SendMode = 2
+
+	// SendModeEptf creates an unsigned transaction which carries the
+	// PkScript and Value of every input so that it can be serialized in
+	// EPTF and handed off to an external signer.
+	SendModeEptf SendMode = 3
+)
+
+const (
+	// MinimizeFee uses the default selection which stops as soon as enough
+	// outputs have been found to pay for the transaction.
+	MinimizeFee SelectionGoal = 0
+
+	// MinimizeUtxos prefers spending the smallest outputs, using as many
+	// inputs as needed within the input limit, so that the wallet's outputs
+	// are consolidated as a side effect of normal sends. If the smallest
+	// outputs cannot pay within the input limit then the default selection
+	// is used.
+	MinimizeUtxos SelectionGoal = 1
 )
 
 // txCreator is responsible for the input selection and creation of
@@ -682,7 +797,7 @@ out:
 		select {
 		case txr := <-w.createTxRequests:
 			var hu heldUnlock
-			if txr.req.SendMode > SendModeUnsigned {
+			if txr.req.SendMode == SendModeSigned || txr.req.SendMode == SendModeBcasted {
 				h, err := w.holdUnlock()
 				if err != nil {
 					txr.resp <- createTxResponse{nil, err}
@@ -721,6 +836,140 @@ func (w *Wallet) CreateSimpleTx(r CreateTxReq) (*txauthor.AuthoredTx, er.R) {
 	return resp.tx, resp.err
 }
 
+// CreateEptfTx creates a new unsigned transaction in the same way as
+// CreateSimpleTx and returns it serialized in EPTF (electrum partial
+// transaction format). Every input is guaranteed to carry the PkScript and
+// Value of the output which it spends so that a hardware or otherwise
+// external signer is able to sign it.
+func (w *Wallet) CreateEptfTx(r CreateTxReq) ([]byte, er.R) {
+	r.SendMode = SendModeEptf
+	tx, err := w.CreateSimpleTx(r)
+	if err != nil {
+		return nil, err
+	}
+	return encodeEptf(tx.Tx)
+}
+
+// PreviewTx selects inputs and authors a transaction for the request in the
+// same way as CreateSimpleTx but as a dry run, and returns worst case
+// estimates of the serialized size, virtual size and weight which the
+// transaction will have once signed, along with the absolute fee which it
+// pays. Nothing is written to the database.
+func (w *Wallet) PreviewTx(r CreateTxReq) (size, vsize, weight int, fee btcutil.Amount, err er.R) {
+	r.SendMode = SendModeUnsigned
+	r.DryRun = true
+	tx, err := w.CreateSimpleTx(r)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	signed := withWorstCaseInputScripts(tx.Tx)
+	size = signed.SerializeSize()
+	weight = int(blockchain.GetTransactionWeight(btcutil.NewTx(signed)))
+	vsize = (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+
+	fee = tx.TotalInput
+	for _, out := range tx.Tx.TxOut {
+		fee -= btcutil.Amount(out.Value)
+	}
+	return size, vsize, weight, fee, nil
+}
+
+// withWorstCaseInputScripts returns a copy of the unsigned transaction tx with
+// every input script and witness replaced by a placeholder of the largest size
+// which signing the input can produce, so that the size of the copy is the
+// worst case size of tx once signed.
+func withWorstCaseInputScripts(tx *wire.MsgTx) *wire.MsgTx {
+	out := tx.Copy()
+	p2wpkhWitness := wire.TxWitness{make([]byte, 73), make([]byte, 33)}
+	for i, in := range out.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+		if i >= len(tx.Additional) {
+			continue
+		}
+		switch pkScript := tx.Additional[i].PkScript; {
+		case txscript.IsPayToScriptHash(pkScript):
+			// Assumed to be a nested P2WPKH.
+			in.SignatureScript = make([]byte, txsizes.RedeemNestedP2WPKHScriptSize)
+			in.Witness = p2wpkhWitness
+		case txscript.IsPayToWitnessPubKeyHash(pkScript):
+			in.Witness = p2wpkhWitness
+		default:
+			in.SignatureScript = make([]byte, txsizes.RedeemP2PKHSigScriptSize)
+		}
+	}
+	return out
+}
+
+// MaxSendable returns the largest amount which can be sent to the address in a
+// single transaction at feeRate, spending every eligible output with at least
+// minconf confirmations up to the maximum number of inputs in a transaction,
+// with no change. If the amount which remains after the fee is dust then zero
+// is returned with InsufficientFundsError. Nothing is written to the database.
+func (w *Wallet) MaxSendable(
+	to btcutil.Address,
+	feeRate btcutil.Amount,
+	minconf int32,
+) (btcutil.Amount, er.R) {
+	pkScript, err := txscript.PayToAddrScript(to)
+	if err != nil {
+		return 0, err
+	}
+	// A zero value output sweeps all of the inputs to it.
+	tx, err := w.CreateSimpleTx(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(0, pkScript)},
+		Minconf:     minconf,
+		FeeSatPerKB: feeRate,
+		SendMode:    SendModeUnsigned,
+		MaxInputs:   -1,
+		DryRun:      true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var amount btcutil.Amount
+	for _, out := range tx.Tx.TxOut {
+		if bytes.Equal(out.PkScript, pkScript) {
+			amount += btcutil.Amount(out.Value)
+		}
+	}
+	if txrules.IsDustAmount(amount, len(pkScript), txrules.DefaultRelayFeePerKb) {
+		return 0, InsufficientFundsError.New(
+			fmt.Sprintf("only [%s] can be sent after the fee, which is dust", amount.String()), nil)
+	}
+	return amount, nil
+}
+
+// EstimateChange selects inputs and authors a transaction for the request in
+// the same way as CreateSimpleTx but as a dry run, and returns the value which
+// would be returned to the wallet as change, zero if there would be no change.
+// If ChangeSplit is used then the total of the change outputs is returned, no
+// change addresses are derived. Nothing is written to the database.
+func (w *Wallet) EstimateChange(r CreateTxReq) (btcutil.Amount, er.R) {
+	r.SendMode = SendModeUnsigned
+	r.DryRun = true
+	tx, err := w.CreateSimpleTx(r)
+	if err != nil {
+		return 0, err
+	} else if tx.ChangeIndex < 0 {
+		return 0, nil
+	} else if r.ChangeSplit <= 1 {
+		return btcutil.Amount(tx.Tx.TxOut[tx.ChangeIndex].Value), nil
+	}
+	requested := make(map[*wire.TxOut]struct{}, len(r.Outputs))
+	for _, out := range r.Outputs {
+		requested[out] = struct{}{}
+	}
+	var change btcutil.Amount
+	for _, out := range tx.Tx.TxOut {
+		if _, ok := requested[out]; !ok && txscript.GetScriptClass(out.PkScript) != txscript.NullDataTy {
+			change += btcutil.Amount(out.Value)
+		}
+	}
+	return change, nil
+}
+
 type (
 	unlockRequest struct {
 		passphrase []byte
@@ -794,6 +1043,14 @@ out:
 		case req := <-w.changePassphrases:
 			err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) er.R {
 				addrmgrNs := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+				// Check the private passphrase before changing the public
+				// one, the manager updates its in memory keys as each
+				// passphrase is changed so a failure part way through would
+				// leave them out of sync with the rolled back database.
+				if err := w.Manager.CheckPassphrase(addrmgrNs, req.privateOld); err != nil {
+					return err
+				}
 				err := w.Manager.ChangePassphrase(
 					addrmgrNs, req.publicOld, req.publicNew,
 					false, &waddrmgr.DefaultScryptOptions,
@@ -1389,7 +1646,7 @@ outputs:
 
 		var address string
 		var accountName string
-		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(output.PkScript, net)
+		_, addrs, _, _ := txscript.TxOutAddresses(output, net)
 		if len(addrs) == 1 {
 			addr := addrs[0]
 			address = addr.EncodeAddress()
@@ -2011,6 +2268,8 @@ func (w *Wallet) LockedOutpoint(op wire.OutPoint) bool {
 // LockOutpoint marks an outpoint as locked, that is, it should not be used as
 // an input for newly created transactions.
 func (w *Wallet) LockOutpoint(op wire.OutPoint, name string) {
+	w.lockedOutpointsMtx.Lock()
+	defer w.lockedOutpointsMtx.Unlock()
 	w.lockedOutpoints[op] = name
 }
 
@@ -2042,6 +2301,8 @@ func (w *Wallet) ResetLockedOutpoints(lockName *string) {
 // intended to be used by marshaling the result as a JSON array for
 // listlockunspent RPC results.
 func (w *Wallet) LockedOutpoints() []btcjson.LockedUnspent {
+	w.lockedOutpointsMtx.Lock()
+	defer w.lockedOutpointsMtx.Unlock()
 	locked := make([]btcjson.LockedUnspent, len(w.lockedOutpoints))
 	i := 0
 	for op, ln := range w.lockedOutpoints {
@@ -2055,6 +2316,44 @@ func (w *Wallet) LockedOutpoints() []btcjson.LockedUnspent {
 	return locked
 }
 
+// FreezeAddress marks an address as frozen so that its outputs are never used
+// as inputs for newly created transactions, including outputs which it
+// receives later. Unlike LockOutpoint, the freeze is stored in the database
+// and remains until UnfreezeAddress is called.
+func (w *Wallet) FreezeAddress(addr btcutil.Address) er.R {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) er.R {
+		txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.FreezeAddress(txmgrNs, addr)
+	})
+}
+
+// UnfreezeAddress removes the freeze on an address so that its outputs may be
+// used as inputs for newly created transactions.
+func (w *Wallet) UnfreezeAddress(addr btcutil.Address) er.R {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) er.R {
+		txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.UnfreezeAddress(txmgrNs, addr)
+	})
+}
+
+// FrozenAddresses returns the addresses which are currently frozen.
+func (w *Wallet) FrozenAddresses() ([]string, er.R) {
+	var out []string
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) er.R {
+		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
+		frozen, err := w.TxStore.FrozenAddresses(txmgrNs)
+		if err != nil {
+			return err
+		}
+		for addr := range frozen {
+			out = append(out, addr)
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out, err
+}
+
 // LeaseOutput locks an output to the given ID, preventing it from being
 // available for coin selection. The absolute time of the lock's expiration is
 // returned. The expiration of the lock can be extended by successive
@@ -2444,6 +2743,74 @@ func (w *Wallet) PublishTransaction(tx *wire.MsgTx, label string) er.R {
 	return err
 }
 
+// FinalizeAndBroadcast publishes a transaction which was created by the
+// wallet in EPTF and then signed externally, for example when spending from a
+// watch-only address. The transaction must carry the Additional info of every
+// input so that each signature can be validated before it is broadcasted. If
+// any input is still unsigned then an error is returned and nothing is
+// broadcasted.
+func (w *Wallet) FinalizeAndBroadcast(signed *wire.MsgTx) (*chainhash.Hash, er.R) {
+	for i, txIn := range signed.TxIn {
+		if len(txIn.SignatureScript) == 0 && len(txIn.Witness) == 0 {
+			return nil, er.Errorf("input [%d] spending [%s] is not signed, cannot broadcast",
+				i, txIn.PreviousOutPoint.String())
+		}
+	}
+	if err := validateMsgTx1(signed); err != nil {
+		return nil, err
+	}
+	// Copy does not carry the Additional info so the tx is no longer EPTF.
+	return w.ReliablyPublishTransaction(signed.Copy(), "")
+}
+
+// SignEptfInputs signs the inputs of an EPTF transaction at the given indices
+// using the Additional info which is carried with each input. Inputs which
+// spend from addresses that the wallet does not control are skipped with a
+// warning so that they can be signed by another party, inputs which are not
+// listed are left as they are.
+func (w *Wallet) SignEptfInputs(tx *wire.MsgTx, inputs []int) er.R {
+	if len(tx.Additional) != len(tx.TxIn) {
+		return er.Errorf("len(tx.Additional) = [%d] but len(tx.TxIn) = [%d], "+
+			"tx is not in EPTF", len(tx.Additional), len(tx.TxIn))
+	}
+	for _, i := range inputs {
+		if i < 0 || i >= len(tx.TxIn) {
+			return er.Errorf("input index [%d] out of range, tx has [%d] inputs",
+				i, len(tx.TxIn))
+		}
+	}
+	hu, err := w.holdUnlock()
+	if err != nil {
+		return err
+	}
+	defer hu.release()
+
+	hashCache := txscript.NewTxSigHashes(tx)
+	return walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		ss := secretSource{w.Manager, addrmgrNs}
+		for _, i := range inputs {
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+				tx.Additional[i].PkScript, w.chainParams)
+			if err != nil || len(addrs) != 1 {
+				log.Warnf("SignEptfInputs: skipping input [%d], unable to get address from pkScript", i)
+				continue
+			}
+			if _, err := w.Manager.Address(addrmgrNs, addrs[0]); err != nil {
+				log.Warnf("SignEptfInputs: skipping input [%d], address [%s] is not in the wallet",
+					i, addrs[0].EncodeAddress())
+				continue
+			}
+			if err := txauthor.SignInputScript(tx, i, params.SigHashAll, hashCache,
+				ss, ss, w.chainParams); err != nil {
+				err.AddMessage(fmt.Sprintf("unable to sign input [%d]", i))
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // reliablyPublishTransaction is a superset of publishTransaction which contains
 // the primary logic required for publishing a transaction, updating the
 // relevant database state, and finally possible removing the transaction from
@@ -3364,6 +3731,10 @@ func Open(db walletdb.DB, pubPass []byte, cbs *waddrmgr.OpenCallbacks,
 		chainParams:        params,
 		quit:               make(chan struct{}),
 		watch:              watcher.New(),
+		burnHorizon:        DefaultBurnHorizon,
+		chainRetries:       DefaultChainRetries,
+		chainRetryBackoff:  int64(DefaultChainRetryBackoff),
+		relayFee:           int64(txrules.DefaultRelayFeePerKb),
 	}
 
 	w.NtfnServer = newNotificationServer(w)
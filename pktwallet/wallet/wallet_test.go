@@ -1,15 +1,21 @@
 package wallet
 
 import (
+	"bytes"
 	"encoding/hex"
 	"testing"
 	"time"
 
+	"github.com/pkt-cash/pktd/blockchain"
 	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/chaincfg/genesis"
+	"github.com/pkt-cash/pktd/pktwallet/waddrmgr"
 	"github.com/pkt-cash/pktd/pktwallet/walletdb"
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/wire"
 )
 
 var (
@@ -203,3 +209,275 @@ func TestLabelTransaction(t *testing.T) {
 		})
 	}
 }
+
+// dumpWalletDB returns every key and value in the address manager and
+// transaction store namespaces of the wallet database, keyed by the path of the
+// bucket which holds them, so that tests can check that nothing was written.
+func dumpWalletDB(t *testing.T, w *Wallet) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	var dump func(path string, b walletdb.ReadBucket) er.R
+	dump = func(path string, b walletdb.ReadBucket) er.R {
+		return b.ForEach(func(k, v []byte) er.R {
+			key := path + "/" + hex.EncodeToString(k)
+			if v == nil {
+				if nested := b.NestedReadBucket(k); nested != nil {
+					return dump(key, nested)
+				}
+			}
+			out[key] = hex.EncodeToString(v)
+			return nil
+		})
+	}
+	if err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) er.R {
+		for _, ns := range [][]byte{waddrmgrNamespaceKey, wtxmgrNamespaceKey} {
+			if err := dump(string(ns), dbtx.ReadBucket(ns)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("unable to dump wallet db: %v", err)
+	}
+	return out
+}
+
+// assertWalletDBUnchanged fails the test if the wallet database differs from
+// the dump before.
+func assertWalletDBUnchanged(t *testing.T, w *Wallet, before map[string]string) {
+	t.Helper()
+	after := dumpWalletDB(t, w)
+	for k, v := range before {
+		if a, ok := after[k]; !ok {
+			t.Fatalf("key [%s] was deleted from the wallet db", k)
+		} else if a != v {
+			t.Fatalf("key [%s] was changed in the wallet db", k)
+		}
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			t.Fatalf("key [%s] was added to the wallet db", k)
+		}
+	}
+}
+
+// TestPreviewTx checks that the numbers returned by PreviewTx match the
+// transaction which is built and signed for the same request, and that a
+// preview does not write to the database.
+func TestPreviewTx(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i), p2wkhAddr)},
+		})
+	}
+
+	txr := CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(250000, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		MaxInputs:   -1,
+	}
+	before := dumpWalletDB(t, w)
+	size, vsize, weight, fee, err := w.PreviewTx(txr)
+	if err != nil {
+		t.Fatalf("unable to preview tx: %v", err)
+	}
+	assertWalletDBUnchanged(t, w, before)
+
+	// Splitting the change would derive new addresses, a preview must not.
+	split := txr
+	split.ChangeSplit = 3
+	if _, _, _, _, err := w.PreviewTx(split); err != nil {
+		t.Fatalf("unable to preview tx with split change: %v", err)
+	}
+	assertWalletDBUnchanged(t, w, before)
+
+	// A dry run is never signed.
+	dry := txr
+	dry.SendMode = SendModeSigned
+	dry.DryRun = true
+	if _, err := w.CreateSimpleTx(dry); err == nil {
+		t.Fatalf("expected signed dry run to be rejected")
+	}
+
+	txr.SendMode = SendModeSigned
+	tx, err := w.CreateSimpleTx(txr)
+	if err != nil {
+		t.Fatalf("unable to create tx: %v", err)
+	}
+	realFee := tx.TotalInput
+	for _, out := range tx.Tx.TxOut {
+		realFee -= btcutil.Amount(out.Value)
+	}
+	if fee != realFee {
+		t.Fatalf("preview fee %v, real fee %v", fee, realFee)
+	}
+
+	// The preview is a worst case estimate, DER signatures are often one
+	// or two bytes shorter than the maximum.
+	realSize := tx.Tx.SerializeSize()
+	realWeight := int(blockchain.GetTransactionWeight(btcutil.NewTx(tx.Tx)))
+	realVsize := (realWeight + blockchain.WitnessScaleFactor - 1) /
+		blockchain.WitnessScaleFactor
+	slack := 2 * len(tx.Tx.TxIn)
+	if size < realSize || size > realSize+slack {
+		t.Fatalf("preview size %d, real size %d", size, realSize)
+	}
+	if weight < realWeight || weight > realWeight+slack {
+		t.Fatalf("preview weight %d, real weight %d", weight, realWeight)
+	}
+	if vsize < realVsize || vsize > realVsize+slack {
+		t.Fatalf("preview vsize %d, real vsize %d", vsize, realVsize)
+	}
+}
+
+// TestMaxSendable checks that the amount returned by MaxSendable is the amount
+// which a sweep of the wallet to the same address pays.
+func TestMaxSendable(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(int64(100000+i), p2wkhAddr)},
+		})
+	}
+
+	before := dumpWalletDB(t, w)
+	max, err := w.MaxSendable(addr, 1000, 1)
+	if err != nil {
+		t.Fatalf("unable to get max sendable: %v", err)
+	}
+	assertWalletDBUnchanged(t, w, before)
+
+	tx, err := w.CreateSimpleTx(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(0, p2wkhAddr)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		SendMode:    SendModeSigned,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to create sweep tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 3 || len(tx.Tx.TxOut) != 1 {
+		t.Fatalf("expected sweep of 3 inputs to 1 output, got %d and %d",
+			len(tx.Tx.TxIn), len(tx.Tx.TxOut))
+	}
+	if max != btcutil.Amount(tx.Tx.TxOut[0].Value) {
+		t.Fatalf("max sendable %v, sweep pays %v", max,
+			btcutil.Amount(tx.Tx.TxOut[0].Value))
+	}
+	if max >= 300003 || max < 300003-1000 {
+		t.Fatalf("unexpected max sendable %v", max)
+	}
+
+	// Nothing can be sent when only dust would remain after the fee.
+	w2, cleanup2 := testWallet(t)
+	defer cleanup2()
+	addr2, err := w2.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr2)
+	}
+	p2wkhAddr2, err := txscript.PayToAddrScript(addr2)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w2, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(300, p2wkhAddr2)},
+	})
+	if max, err := w2.MaxSendable(addr2, 1000, 1); !InsufficientFundsError.Is(err) || max != 0 {
+		t.Fatalf("expected zero and InsufficientFundsError, got %v and %v", max, err)
+	}
+}
+
+// TestEstimateChange checks that the estimated change matches the change of
+// the transaction which is really built, including when the change is split,
+// and that a sweep has no change.
+func TestEstimateChange(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", addr)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+	dest := append([]byte{opcode.OP_0, opcode.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	for _, split := range []int{0, 3} {
+		req := func() CreateTxReq {
+			return CreateTxReq{
+				Outputs:     []*wire.TxOut{wire.NewTxOut(400000, dest)},
+				Minconf:     1,
+				FeeSatPerKB: 1000,
+				SendMode:    SendModeSigned,
+				MaxInputs:   -1,
+				ChangeSplit: split,
+			}
+		}
+		before := dumpWalletDB(t, w)
+		estimate, err := w.EstimateChange(req())
+		if err != nil {
+			t.Fatalf("split %d: unable to estimate change: %v", split, err)
+		}
+		assertWalletDBUnchanged(t, w, before)
+		tx, err := w.CreateSimpleTx(req())
+		if err != nil {
+			t.Fatalf("split %d: unable to create tx: %v", split, err)
+		}
+		var change btcutil.Amount
+		for _, out := range tx.Tx.TxOut {
+			if !bytes.Equal(out.PkScript, dest) {
+				change += btcutil.Amount(out.Value)
+			}
+		}
+		if estimate <= 0 || estimate != change {
+			t.Fatalf("split %d: estimated change %v, built tx has %v",
+				split, estimate, change)
+		}
+	}
+
+	// A sweep has no change.
+	estimate, err := w.EstimateChange(CreateTxReq{
+		Outputs:     []*wire.TxOut{wire.NewTxOut(0, dest)},
+		Minconf:     1,
+		FeeSatPerKB: 1000,
+		MaxInputs:   -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to estimate change of sweep: %v", err)
+	}
+	if estimate != 0 {
+		t.Fatalf("expected no change for a sweep, got %v", estimate)
+	}
+}
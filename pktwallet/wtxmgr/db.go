@@ -76,6 +76,7 @@ var (
 	bucketUnminedInputs  = []byte("mi")
 	bucketLockedOutputs  = []byte("lo")
 	bucketAddrVotes      = []byte("votes")
+	bucketFrozenAddrs    = []byte("frozen")
 )
 
 // Root (namespace) bucket keys
@@ -892,6 +893,8 @@ func deleteRawUnminedCredit(ns walletdb.ReadWriteBucket, k []byte) er.R {
 type DbNsVote2 struct {
 	// True this the address has indicated it's candidacy in the most recent vote
 	IsCandidate bool `json:"is_candidate,omitempty"`
+	// True if the address has explicitly withdrawn it's candidacy in the most recent vote
+	WithdrawsCandidacy bool `json:"withdraws_candidacy,omitempty"`
 	// Who the address voted for, empty string if they voted for themselves or nobody
 	VoteFor string `json:"vote_for,omitempty"`
 	// The transaction ID of the vote
@@ -1219,6 +1222,50 @@ func unlockOutput(ns walletdb.ReadWriteBucket, op wire.OutPoint) er.R {
 	return nil
 }
 
+// freezeAddress records that the outputs paying to the encoded address must
+// never be selected for spending.
+func freezeAddress(ns walletdb.ReadWriteBucket, addr string) er.R {
+	frozenAddrs, err := ns.CreateBucketIfNotExists(bucketFrozenAddrs)
+	if err != nil {
+		str := "failed to create frozen addresses bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	if err := frozenAddrs.Put([]byte(addr), []byte{}); err != nil {
+		str := fmt.Sprintf("%s: put failed for %s", bucketFrozenAddrs, addr)
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// unfreezeAddress removes the freeze on the encoded address, if any.
+func unfreezeAddress(ns walletdb.ReadWriteBucket, addr string) er.R {
+	// The bucket may not exist, indicating that no address has ever been
+	// frozen, so we can just return now.
+	frozenAddrs := ns.NestedReadWriteBucket(bucketFrozenAddrs)
+	if frozenAddrs == nil {
+		return nil
+	}
+	if err := frozenAddrs.Delete([]byte(addr)); err != nil {
+		str := fmt.Sprintf("%s: delete failed for %s", bucketFrozenAddrs, addr)
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// fetchFrozenAddresses returns the set of encoded addresses which are frozen.
+func fetchFrozenAddresses(ns walletdb.ReadBucket) (map[string]struct{}, er.R) {
+	out := make(map[string]struct{})
+	frozenAddrs := ns.NestedReadBucket(bucketFrozenAddrs)
+	if frozenAddrs == nil {
+		return out, nil
+	}
+	err := frozenAddrs.ForEach(func(k, _ []byte) er.R {
+		out[string(k)] = struct{}{}
+		return nil
+	})
+	return out, err
+}
+
 // forEachLockedOutput iterates over all existing locked outputs and invokes the
 // callback `f` for each.
 func forEachLockedOutput(ns walletdb.ReadBucket,
@@ -201,17 +201,42 @@ func Create(ns walletdb.ReadWriteBucket) er.R {
 	return createStore(ns)
 }
 
-func getVote(rec *TxRecord, block *BlockMeta, chainParams *chaincfg.Params) *DbNsVote2 {
+// getVote returns the vote cast by a transaction, if any. The voter is the
+// address spent by the first input which is known to the wallet. Votes which
+// do not pass NsVote.Validate are logged and dropped.
+func getVote(
+	ns walletdb.ReadBucket,
+	rec *TxRecord,
+	block *BlockMeta,
+	chainParams *chaincfg.Params,
+) *DbNsVote2 {
 	for _, output := range rec.MsgTx.TxOut {
 		if output.Value != 0 {
 			continue
 		}
 		if v := votes.GetVote(output.PkScript); v != nil {
+			for _, input := range rec.MsgTx.TxIn {
+				if pk, err := AddressForOutPoint(ns, &input.PreviousOutPoint); err != nil {
+					log.Warnf("Error decoding address spent from because [%s]", err.String())
+				} else if pk != nil {
+					v.VoterPkScript = pk
+					break
+				}
+			}
+			if block.Height > 0 {
+				v.VoteCastInBlock = uint32(block.Height)
+			}
+			if err := v.Validate(); err != nil {
+				log.Warnf("Dropping invalid vote in tx [%s] because [%s]",
+					rec.Hash, err.String())
+				return nil
+			}
 			return &DbNsVote2{
-				IsCandidate: v.VoterIsWillingCandidate,
-				VoteFor:     txscript.PkScriptToAddress(v.VoteForPkScript, chainParams).String(),
-				VoteTxid:    rec.Hash.String(),
-				VoteBlock:   block.Height,
+				IsCandidate:        v.VoterIsWillingCandidate,
+				WithdrawsCandidacy: v.VoterWithdrawsCandidacy,
+				VoteFor:            txscript.PkScriptToAddress(v.VoteForPkScript, chainParams).String(),
+				VoteTxid:           rec.Hash.String(),
+				VoteBlock:          block.Height,
 			}
 		}
 	}
@@ -234,7 +259,7 @@ func (s *Store) updateMinedBalance(ns walletdb.ReadWriteBucket, rec *TxRecord,
 
 	spentByAddress := map[string]btcutil.Amount{}
 
-	vote := getVote(rec, block, s.chainParams)
+	vote := getVote(ns, rec, block, s.chainParams)
 
 	for i, input := range rec.MsgTx.TxIn {
 		uns, err := unspent.Get(ns, &input.PreviousOutPoint)
@@ -295,6 +320,8 @@ func (s *Store) updateMinedBalance(ns walletdb.ReadWriteBucket, rec *TxRecord,
 				candidate := ""
 				if vote.IsCandidate {
 					candidate = "+CANDIDATE"
+				} else if vote.WithdrawsCandidacy {
+					candidate = "-CANDIDATE"
 				}
 				log.Infof("🗳️ %s [%s%s] from [%s] tx [%s] @ [%s]",
 					log.GreenBg("Confirmed vote"),
@@ -910,6 +937,7 @@ func (s *Store) ForEachUnspentOutput(
 			Address:      addr,
 			Value:        txOut.Value,
 			FromCoinBase: false,
+			PkScript:     txOut.PkScript,
 		}
 		// Use the final key to come from the main search loop so that further calls
 		// will arrive here as quickly as possible.
@@ -924,6 +952,28 @@ func (s *Store) ForEachUnspentOutput(
 	return visits, nil
 }
 
+// ForEachUnspentOutputByType is the same as ForEachUnspentOutput except that
+// only outputs whose script is one of the provided script classes are visited.
+// For example passing txscript.WitnessV0PubKeyHashTy and
+// txscript.WitnessV0ScriptHashTy visits only segwit outputs.
+func (s *Store) ForEachUnspentOutputByType(
+	ns walletdb.ReadBucket,
+	beginKey []byte,
+	addrs map[string]struct{},
+	classes []txscript.ScriptClass,
+	visitor func(key []byte, c *dbstructs.Unspent) er.R,
+) (int, er.R) {
+	return s.ForEachUnspentOutput(ns, beginKey, addrs, func(k []byte, uns *dbstructs.Unspent) er.R {
+		class := txscript.GetScriptClass(uns.PkScript)
+		for _, c := range classes {
+			if c == class {
+				return visitor(k, uns)
+			}
+		}
+		return nil
+	})
+}
+
 // GetUnspentOutputs returns all unspent received transaction outputs.
 // The order is undefined.
 func (s *Store) GetUnspentOutputs(ns walletdb.ReadBucket) ([]dbstructs.Unspent, er.R) {
@@ -1115,6 +1165,25 @@ func (s *Store) UnlockOutput(ns walletdb.ReadWriteBucket, id LockID,
 	return unlockOutput(ns, op)
 }
 
+// FreezeAddress marks an address as frozen, outputs which pay to it are never
+// selected for spending until it is unfrozen. Unlike output locks, the freeze
+// does not expire and it applies to outputs received after it is made.
+func (s *Store) FreezeAddress(ns walletdb.ReadWriteBucket, addr btcutil.Address) er.R {
+	return freezeAddress(ns, addr.String())
+}
+
+// UnfreezeAddress removes the freeze on an address, if it is not frozen then
+// nothing is done.
+func (s *Store) UnfreezeAddress(ns walletdb.ReadWriteBucket, addr btcutil.Address) er.R {
+	return unfreezeAddress(ns, addr.String())
+}
+
+// FrozenAddresses returns the set of frozen addresses, keyed by their string
+// form which is the same as the Address of an unspent output.
+func (s *Store) FrozenAddresses(ns walletdb.ReadBucket) (map[string]struct{}, er.R) {
+	return fetchFrozenAddresses(ns)
+}
+
 // DeleteExpiredLockedOutputs iterates through all existing locked outputs and
 // deletes those which have already expired.
 func (s *Store) DeleteExpiredLockedOutputs(ns walletdb.ReadWriteBucket) er.R {
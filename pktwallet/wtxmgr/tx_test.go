@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkt-cash/pktd/blockchain/votecompute/votes"
 	"github.com/pkt-cash/pktd/btcutil"
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/chaincfg"
@@ -22,6 +23,9 @@ import (
 	_ "github.com/pkt-cash/pktd/pktwallet/walletdb/bdb"
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/dbstructs"
 	"github.com/pkt-cash/pktd/pktwallet/wtxmgr/utilfun"
+	"github.com/pkt-cash/pktd/txscript"
+	"github.com/pkt-cash/pktd/txscript/opcode"
+	"github.com/pkt-cash/pktd/txscript/params"
 	"github.com/pkt-cash/pktd/wire"
 	"github.com/pkt-cash/pktd/wire/constants"
 )
@@ -2716,3 +2720,167 @@ func TestOutputLocks(t *testing.T) {
 		})
 	}
 }
+
+// TestForEachUnspentOutputByType checks that only unspent outputs with a
+// matching script class are visited, both confirmed and unconfirmed.
+func TestForEachUnspentOutputByType(t *testing.T) {
+	t.Parallel()
+
+	s, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	p2wkh := append([]byte{0x00, 0x14}, make([]byte, 20)...)
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, make([]byte, 20)...), 0x88, 0xac)
+
+	minedTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(1e8, p2wkh),
+			wire.NewTxOut(2e8, p2pkh),
+		},
+	}
+	minedRec, err := NewTxRecordFromMsgTx(minedTx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	minedHash := minedTx.TxHash()
+	unminedTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{PreviousOutPoint: wire.OutPoint{Index: 7}}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(3e8, p2pkh),
+			wire.NewTxOut(4e8, p2wkh),
+		},
+	}
+	unminedRec, err := NewTxRecordFromMsgTx(unminedTx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unminedHash := unminedTx.TxHash()
+	block := &BlockMeta{
+		Block: dbstructs.Block{Hash: chainhash.Hash{1}, Height: 100},
+		Time:  time.Now(),
+	}
+
+	commitDBTx(t, s, db, func(ns walletdb.ReadWriteBucket) {
+		if err := s.InsertTx(ns, minedRec, block); err != nil {
+			t.Fatal(err)
+		}
+		for i := uint32(0); i < 2; i++ {
+			if err := s.AddCredit(ns, minedRec, block, i, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.InsertTx(ns, unminedRec, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i := uint32(0); i < 2; i++ {
+			if err := s.AddCredit(ns, unminedRec, nil, i, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	visit := func(classes ...txscript.ScriptClass) map[wire.OutPoint]struct{} {
+		out := make(map[wire.OutPoint]struct{})
+		commitDBTx(t, s, db, func(ns walletdb.ReadWriteBucket) {
+			_, err := s.ForEachUnspentOutputByType(ns, nil, nil, classes,
+				func(_ []byte, uns *dbstructs.Unspent) er.R {
+					out[uns.OutPoint] = struct{}{}
+					return nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+		return out
+	}
+
+	segwit := visit(txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy)
+	want := []wire.OutPoint{{Hash: minedHash, Index: 0}, {Hash: unminedHash, Index: 1}}
+	if len(segwit) != len(want) {
+		t.Fatalf("expected %d segwit outputs, got %d", len(want), len(segwit))
+	}
+	for _, op := range want {
+		if _, ok := segwit[op]; !ok {
+			t.Fatalf("missing segwit output %v", op)
+		}
+	}
+
+	legacy := visit(txscript.PubKeyHashTy)
+	want = []wire.OutPoint{{Hash: minedHash, Index: 1}, {Hash: unminedHash, Index: 0}}
+	if len(legacy) != len(want) {
+		t.Fatalf("expected %d legacy outputs, got %d", len(want), len(legacy))
+	}
+	for _, op := range want {
+		if _, ok := legacy[op]; !ok {
+			t.Fatalf("missing legacy output %v", op)
+		}
+	}
+
+	if none := visit(); len(none) != 0 {
+		t.Fatalf("expected no outputs without any class, got %d", len(none))
+	}
+}
+
+// TestGetVoteValidate checks that getVote attributes a vote to the address
+// spent by the transaction and drops votes which fail NsVote.Validate.
+func TestGetVoteValidate(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	voterScript := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x01}, 20)...)
+	fundTx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, voterScript)},
+	}
+	insertConfirmedCredit(t, store, db, fundTx, 0, &BlockMeta{
+		Block: dbstructs.Block{Height: 100},
+	})
+	fundHash := fundTx.TxHash()
+
+	voteScript := func(voteFor []byte) []byte {
+		data := append([]byte{votes.VOTE}, voteFor...)
+		script := []byte{opcode.OP_RETURN, opcode.OP_PUSHDATA2,
+			byte(len(data)), byte(len(data) >> 8)}
+		return append(script, data...)
+	}
+	voteTx := func(prevOut wire.OutPoint, voteFor []byte) *TxRecord {
+		rec, err := NewTxRecordFromMsgTx(&wire.MsgTx{
+			TxIn:  []*wire.TxIn{{PreviousOutPoint: prevOut}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(0, voteScript(voteFor))},
+		}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+	voteBlock := &BlockMeta{Block: dbstructs.Block{Height: 101}}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		valid := voteTx(wire.OutPoint{Hash: fundHash}, voterScript)
+		if v := getVote(ns, valid, voteBlock, store.chainParams); v == nil {
+			t.Fatalf("valid vote was dropped")
+		} else if v.VoteBlock != 101 || v.VoteTxid != valid.Hash.String() {
+			t.Fatalf("unexpected vote %+v", v)
+		}
+
+		unknownVoter := voteTx(wire.OutPoint{Index: 7}, voterScript)
+		if v := getVote(ns, unknownVoter, voteBlock, store.chainParams); v != nil {
+			t.Fatalf("vote from unknown voter was stored: %+v", v)
+		}
+
+		tooLong := voteTx(wire.OutPoint{Hash: fundHash},
+			make([]byte, params.MaxScriptSize+1))
+		if v := getVote(ns, tooLong, voteBlock, store.chainParams); v != nil {
+			t.Fatalf("vote for oversized script was stored: %+v", v)
+		}
+	})
+}
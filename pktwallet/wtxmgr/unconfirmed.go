@@ -207,6 +207,60 @@ func (s *Store) unminedTxRecords(ns walletdb.ReadBucket) (map[chainhash.Hash]*Tx
 	return unmined, err
 }
 
+// UnminedChangeDepth returns the length of the chain of unmined wallet
+// transactions which ends with the transaction txHash.  A transaction is only
+// counted if it spends coins which belong to the wallet, so outputs received
+// from others are not considered change and have a depth of zero, as do the
+// outputs of mined transactions.  Spending an output of txHash creates a chain
+// which is one deeper than the returned value.
+func (s *Store) UnminedChangeDepth(ns walletdb.ReadBucket, txHash *chainhash.Hash) (int, er.R) {
+	return unminedChangeDepth(ns, txHash, make(map[chainhash.Hash]int))
+}
+
+func unminedChangeDepth(
+	ns walletdb.ReadBucket,
+	txHash *chainhash.Hash,
+	memo map[chainhash.Hash]int,
+) (int, er.R) {
+	if depth, ok := memo[*txHash]; ok {
+		return depth, nil
+	}
+	v := existsRawUnmined(ns, txHash[:])
+	if v == nil {
+		memo[*txHash] = 0
+		return 0, nil
+	}
+	var rec TxRecord
+	if err := readRawTxRecord(txHash, v, &rec); err != nil {
+		return 0, err
+	}
+	ours := false
+	depth := 0
+	for _, input := range rec.MsgTx.TxIn {
+		prevOut := &input.PreviousOutPoint
+		if pkScript, err := AddressForOutPoint(ns, prevOut); err != nil {
+			return 0, err
+		} else if pkScript == nil {
+			continue
+		}
+		ours = true
+		d, err := unminedChangeDepth(ns, &prevOut.Hash, memo)
+		if err != nil {
+			return 0, err
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	if ours {
+		depth++
+	} else {
+		depth = 0
+	}
+	memo[*txHash] = depth
+	return depth, nil
+}
+
 // UnminedTxHashes returns the hashes of all transactions not known to have been
 // mined in a block.
 func (s *Store) UnminedTxHashes(ns walletdb.ReadBucket) ([]*chainhash.Hash, er.R) {
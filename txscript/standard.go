@@ -719,6 +719,13 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 	return scriptClass, addrs, requiredSigs, nil
 }
 
+// TxOutAddresses returns the type of script, addresses and required signatures
+// associated with the PkScript of the passed transaction output, in the same
+// way as ExtractPkScriptAddrs.
+func TxOutAddresses(txOut *wire.TxOut, chainParams *chaincfg.Params) (ScriptClass, []btcutil.Address, int, er.R) {
+	return ExtractPkScriptAddrs(txOut.PkScript, chainParams)
+}
+
 // PkScriptToAddress returns the address corrisponding to a script.
 // Because most multi-signature scripts are segwit and are thus able to be represented as
 // addresses, most scripts are able to be represented directly as addresses, but if there
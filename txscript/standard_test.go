@@ -382,6 +382,61 @@ func TestExtractPkScriptAddrs(t *testing.T) {
 	}
 }
 
+// TestTxOutAddresses ensures that the type, addresses and number of required
+// signatures are extracted from the PkScript of standard transaction outputs.
+func TestTxOutAddresses(t *testing.T) {
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(bytes.Repeat([]byte{0x01}, 20),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressWitnessPubKeyHash: %v", err)
+	}
+	p2wsh, err := btcutil.NewAddressWitnessScriptHash(bytes.Repeat([]byte{0x02}, 32),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressWitnessScriptHash: %v", err)
+	}
+	tests := []struct {
+		name  string
+		addr  btcutil.Address
+		class ScriptClass
+	}{
+		{"p2pkh", newAddressPubKeyHash(bytes.Repeat([]byte{0x03}, 20)), PubKeyHashTy},
+		{"p2sh", newAddressScriptHash(bytes.Repeat([]byte{0x04}, 20)), ScriptHashTy},
+		{"p2wpkh", p2wpkh, WitnessV0PubKeyHashTy},
+		{"p2wsh", p2wsh, WitnessV0ScriptHashTy},
+	}
+	for _, test := range tests {
+		pkScript, err := PayToAddrScript(test.addr)
+		if err != nil {
+			t.Fatalf("%s: PayToAddrScript: %v", test.name, err)
+		}
+		class, addrs, reqSigs, err := TxOutAddresses(wire.NewTxOut(1000, pkScript),
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if class != test.class || reqSigs != 1 {
+			t.Errorf("%s: got class %s and %d required signatures", test.name,
+				class, reqSigs)
+		}
+		if len(addrs) != 1 || addrs[0].EncodeAddress() != test.addr.EncodeAddress() {
+			t.Errorf("%s: got addresses %v, want %v", test.name, addrs, test.addr)
+		}
+	}
+
+	nullData, err := NullDataScript([]byte("data"))
+	if err != nil {
+		t.Fatalf("NullDataScript: %v", err)
+	}
+	class, addrs, reqSigs, err := TxOutAddresses(wire.NewTxOut(0, nullData),
+		&chaincfg.MainNetParams)
+	if err != nil || class != NullDataTy || len(addrs) != 0 || reqSigs != 0 {
+		t.Errorf("null data: got class %s, addresses %v, %d required signatures, "+
+			"error %v", class, addrs, reqSigs, err)
+	}
+}
+
 // TestCalcScriptInfo ensures the CalcScriptInfo provides the expected results
 // for various valid and invalid script pairs.
 func TestCalcScriptInfo(t *testing.T) {
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/pkt-cash/pktd/chaincfg/chainhash"
@@ -594,6 +595,44 @@ func BenchmarkTxHash(b *testing.B) {
 	}
 }
 
+// readMegaTx reads the very large transaction used by the benchmarks.
+func readMegaTx(b *testing.B) *MsgTx {
+	// tx bb41a757f405890fb0f5856228e23b715702d714d59bf2b1feb70d8b2b4e3e08
+	// from the main block chain.
+	fi, err := os.Open("testdata/megatx.bin.bz2")
+	if err != nil {
+		b.Fatalf("Failed to read transaction data: %v", err)
+	}
+	defer fi.Close()
+	var tx MsgTx
+	if err := tx.Deserialize(bzip2.NewReader(fi)); err != nil {
+		b.Fatalf("Failed to deserialize transaction: %v", err)
+	}
+	return &tx
+}
+
+// BenchmarkTxHashLarge performs a benchmark on how long it takes to hash a
+// very large transaction.
+func BenchmarkTxHashLarge(b *testing.B) {
+	tx := readMegaTx(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx.TxHash()
+	}
+}
+
+// BenchmarkTxHashStreamingLarge performs a benchmark on how long it takes to
+// hash a very large transaction without an intermediate buffer.
+func BenchmarkTxHashStreamingLarge(b *testing.B) {
+	tx := readMegaTx(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx.TxHashStreaming()
+	}
+}
+
 // BenchmarkDoubleHashB performs a benchmark on how long it takes to perform a
 // double hash returning a byte slice.
 func BenchmarkDoubleHashB(b *testing.B) {
@@ -625,3 +664,31 @@ func BenchmarkDoubleHashH(b *testing.B) {
 		_ = chainhash.DoubleHashH(txBytes)
 	}
 }
+
+// BenchmarkSerializeTxFresh performs a benchmark on serializing a transaction
+// into a newly allocated buffer each time.
+func BenchmarkSerializeTxFresh(b *testing.B) {
+	tx := multiWitnessTx
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			b.Fatalf("Serialize: %v", err)
+		}
+	}
+}
+
+// BenchmarkSerializeTxPooled performs a benchmark on serializing a transaction
+// into buffers taken from a sync.Pool with SerializeInto.
+func BenchmarkSerializeTxPooled(b *testing.B) {
+	tx := multiWitnessTx
+	pool := sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := pool.Get().(*bytes.Buffer)
+		if err := tx.SerializeInto(buf); err != nil {
+			b.Fatalf("SerializeInto: %v", err)
+		}
+		pool.Put(buf)
+	}
+}
@@ -23,3 +23,8 @@ var MessageError *er.ErrorCode = er.GenericErrorType.Code("wire.MessageError")
 func messageError(f string, desc string) er.R {
 	return MessageError.New(fmt.Sprintf("%s: %s", f, desc), nil)
 }
+
+// EptfScriptConflictError is returned when encoding with StrictEptfEncoding
+// and an input has both a SignatureScript and an Additional PkScript, so it is
+// unclear whether the input is signed or still needs to be signed.
+var EptfScriptConflictError *er.ErrorCode = er.GenericErrorType.Code("wire.EptfScriptConflictError")
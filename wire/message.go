@@ -87,6 +87,7 @@ const (
 	// will cause an error in encoding.
 	EptfEncoding
 	_ForceEptfEncoding
+	_StrictEptfEncoding
 )
 
 const (
@@ -94,8 +95,30 @@ const (
 	// data. If Additional missing or incomplete, then this will cause an error
 	// in encoding.
 	ForceEptfEncoding = EptfEncoding | _ForceEptfEncoding
+
+	// StrictEptfEncoding is EptfEncoding which fails with
+	// EptfScriptConflictError if any input has both a SignatureScript and an
+	// Additional PkScript. Normally the SignatureScript is silently preferred
+	// but this usually means the transaction was constructed incorrectly, so
+	// this is useful for debugging partially signed transactions.
+	StrictEptfEncoding = EptfEncoding | _StrictEptfEncoding
 )
 
+// withWitness returns true if transactions should be encoded and decoded with
+// their witness data. Encoding flags are a bitmask so WitnessEncoding may be
+// combined with the PacketCrypt flags, EptfEncoding implies WitnessEncoding
+// because a partial transaction is always encoded with its witnesses.
+//
+// The valid combinations for a MsgTx are BaseEncoding, WitnessEncoding,
+// EptfEncoding, ForceEptfEncoding and StrictEptfEncoding, any of which may be
+// ORed with WitnessEncoding or with PacketCryptEncoding/NoPacketCryptEncoding,
+// which only affect blocks. If EptfEncoding is requested but the transaction has
+// no Additional data then it is encoded as with WitnessEncoding, whereas
+// ForceEptfEncoding fails.
+func (enc MessageEncoding) withWitness() bool {
+	return enc&(WitnessEncoding|EptfEncoding) != 0
+}
+
 // LatestEncoding is the most recently specified encoding for the Bitcoin wire
 // protocol.
 var LatestEncoding = WitnessEncoding
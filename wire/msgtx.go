@@ -6,10 +6,17 @@ package wire
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkt-cash/pktd/btcutil/er"
 	"github.com/pkt-cash/pktd/chaincfg/chainhash"
@@ -36,6 +43,17 @@ const (
 	// a transaction which fits into a message could possibly have.
 	maxTxInPerMessage = (MaxMessagePayload / minTxInPayload) + 1
 
+	// txInAdditionalSize is the number of bytes of memory used for the
+	// additional info of one input of a decoded EPTF transaction on a 64-bit
+	// platform, a TxInAdditional of 32 bytes plus the int64 which its Value
+	// points to.
+	txInAdditionalSize = 32 + 8
+
+	// maxTxInAdditionalBytes is the worst case number of bytes allocated for
+	// the additional info of a decoded EPTF transaction, which is when it
+	// declares maxTxInPerMessage inputs, a little over 32MB.
+	maxTxInAdditionalBytes = maxTxInPerMessage * txInAdditionalSize
+
 	// maxTxOutPerMessage is the maximum number of transactions outputs that
 	// a transaction which fits into a message could possibly have.
 	maxTxOutPerMessage = (MaxMessagePayload / constants.MinTxOutPayload) + 1
@@ -88,15 +106,73 @@ const (
 // fields.
 var witessMarkerBytes = []byte{0x00, 0x01}
 
+// eptfMagicBytes begin every transaction which is encoded in EPTF, in place of
+// the version.
+var eptfMagicBytes = []byte("EPTF\xff\x00")
+
+// IsEptf returns true if b begins with the magic bytes of a transaction which is
+// encoded in EPTF. Only the prefix is checked, b is not decoded so it may still
+// fail to deserialize.
+func IsEptf(b []byte) bool {
+	return bytes.HasPrefix(b, eptfMagicBytes)
+}
+
 // scriptFreeList defines a free list of byte slices (up to the maximum number
-// defined by the freeListMaxItems constant) that have a cap according to the
-// freeListMaxScriptSize constant.  It is used to provide temporary buffers for
-// deserializing scripts in order to greatly reduce the number of allocations
-// required.
+// of items given when it was created) that have a cap of maxScriptSize.  It is
+// used to provide temporary buffers for deserializing scripts in order to
+// greatly reduce the number of allocations required.
 //
 // The caller can obtain a buffer from the free list by calling the Borrow
 // function and should return it via the Return function when done using it.
-type scriptFreeList chan []byte
+type scriptFreeList struct {
+	// The counters are accessed atomically and are kept first so that they
+	// are 64 bit aligned on 32 bit platforms.
+	borrows   uint64
+	returns   uint64
+	misses    uint64
+	oversized uint64
+
+	pool          chan []byte
+	maxScriptSize uint64
+}
+
+// ScriptFreeListStats are counters describing how the script free list has
+// been used since it was created, see ScriptPoolStats.
+type ScriptFreeListStats struct {
+	// Borrows is the number of buffers borrowed which were small enough to
+	// be taken from the free list.
+	Borrows uint64
+
+	// Returns is the number of buffers which were put back on the free list.
+	Returns uint64
+
+	// Misses is the number of Borrows which found the free list empty and
+	// allocated a new buffer.
+	Misses uint64
+
+	// Oversized is the number of buffers borrowed which were larger than the
+	// maximum script size and so bypassed the free list.
+	Oversized uint64
+}
+
+// stats returns a snapshot of the counters of the free list.
+func (c *scriptFreeList) stats() ScriptFreeListStats {
+	return ScriptFreeListStats{
+		Borrows:   atomic.LoadUint64(&c.borrows),
+		Returns:   atomic.LoadUint64(&c.returns),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Oversized: atomic.LoadUint64(&c.oversized),
+	}
+}
+
+// newScriptFreeList creates a free list which holds up to items buffers of
+// maxScriptSize bytes each.
+func newScriptFreeList(items int, maxScriptSize uint64) *scriptFreeList {
+	return &scriptFreeList{
+		pool:          make(chan []byte, items),
+		maxScriptSize: maxScriptSize,
+	}
+}
 
 // Borrow returns a byte slice from the free list with a length according the
 // provided size.  A new buffer is allocated if there are any items available.
@@ -105,16 +181,22 @@ type scriptFreeList chan []byte
 // a new buffer of the appropriate size is allocated and returned.  It is safe
 // to attempt to return said buffer via the Return function as it will be
 // ignored and allowed to go the garbage collector.
-func (c scriptFreeList) Borrow(size uint64) []byte {
-	if size > freeListMaxScriptSize {
+func (c *scriptFreeList) Borrow(size uint64) []byte {
+	if atomic.LoadUint32(&scriptPoolDisabled) != 0 {
+		return make([]byte, size)
+	}
+	if size > c.maxScriptSize {
+		atomic.AddUint64(&c.oversized, 1)
 		return make([]byte, size)
 	}
 
+	atomic.AddUint64(&c.borrows, 1)
 	var buf []byte
 	select {
-	case buf = <-c:
+	case buf = <-c.pool:
 	default:
-		buf = make([]byte, freeListMaxScriptSize)
+		atomic.AddUint64(&c.misses, 1)
+		buf = make([]byte, c.maxScriptSize)
 	}
 	return buf[:size]
 }
@@ -124,17 +206,22 @@ func (c scriptFreeList) Borrow(size uint64) []byte {
 // the Borrow function.  Any slices that are not of the appropriate size, such
 // as those whose size is greater than the largest allowed free list item size
 // are simply ignored so they can go to the garbage collector.
-func (c scriptFreeList) Return(buf []byte) {
+func (c *scriptFreeList) Return(buf []byte) {
+	if atomic.LoadUint32(&scriptPoolDisabled) != 0 {
+		return
+	}
+
 	// Ignore any buffers returned that aren't the expected size for the
 	// free list.
-	if cap(buf) != freeListMaxScriptSize {
+	if uint64(cap(buf)) != c.maxScriptSize {
 		return
 	}
 
 	// Return the buffer to the free list when it's not full.  Otherwise let
 	// it be garbage collected.
 	select {
-	case c <- buf:
+	case c.pool <- buf:
+		atomic.AddUint64(&c.returns, 1)
 	default:
 		// Let it go to the garbage collector.
 	}
@@ -143,7 +230,95 @@ func (c scriptFreeList) Return(buf []byte) {
 // Create the concurrent safe free list to use for script deserialization.  As
 // previously described, this free list is maintained to significantly reduce
 // the number of allocations.
-var scriptPool scriptFreeList = make(chan []byte, freeListMaxItems)
+var scriptPool = newScriptFreeList(freeListMaxItems, freeListMaxScriptSize)
+
+// scriptPoolDisabled is non-zero while the free list is disabled, it is
+// accessed atomically.
+var scriptPoolDisabled uint32
+
+// DisableScriptPool, when disabled is true, causes every script buffer used for
+// deserialization to be newly allocated and never reused, as though there were
+// no free list.  This makes memory profiles deterministic and attributes each
+// allocation to the decode call which made it, at the cost of performance.  The
+// free list is enabled by default and it may be changed at any time.
+func DisableScriptPool(disabled bool) {
+	if disabled {
+		atomic.StoreUint32(&scriptPoolDisabled, 1)
+	} else {
+		atomic.StoreUint32(&scriptPoolDisabled, 0)
+	}
+}
+
+// scriptPoolMtx prevents concurrent calls to SetScriptFreeListSize.
+var scriptPoolMtx sync.Mutex
+
+// SetScriptFreeListSize replaces the free list which is used for script
+// deserialization with one holding up to items buffers of maxScriptSize bytes
+// each.  The peak memory usage of the free list is items * maxScriptSize, so
+// memory constrained deployments may wish to shrink it, at the cost of more
+// allocations and garbage collector pressure when deserializing transactions.
+// Scripts which are larger than maxScriptSize always bypass the free list.
+//
+// This must be called during initialization, before any messages are decoded.
+// Buffers borrowed from the previous free list are safely ignored when they are
+// returned.
+func SetScriptFreeListSize(items, maxScriptSize int) er.R {
+	if items < 0 {
+		return er.Errorf("script free list items must not be negative, got [%d]", items)
+	}
+	if maxScriptSize <= 0 {
+		return er.Errorf("script free list max script size must be positive, got [%d]",
+			maxScriptSize)
+	}
+	scriptPoolMtx.Lock()
+	defer scriptPoolMtx.Unlock()
+	scriptPool = newScriptFreeList(items, uint64(maxScriptSize))
+	return nil
+}
+
+// ScriptPoolStats returns the usage counters of the free list which is used
+// for script deserialization.  A high ratio of Misses to Borrows suggests that
+// the free list is too small for the load, see SetScriptFreeListSize.  The
+// counters start from zero whenever the free list is replaced.
+func ScriptPoolStats() ScriptFreeListStats {
+	scriptPoolMtx.Lock()
+	pool := scriptPool
+	scriptPoolMtx.Unlock()
+	return pool.stats()
+}
+
+// witnessItemsPerInputLimit and witnessItemSizeLimit are the bounds applied to
+// witness data when decoding transactions, see SetWitnessLimits.
+var (
+	witnessItemsPerInputLimit uint64 = maxWitnessItemsPerInput
+	witnessItemSizeLimit      uint32 = maxWitnessItemSize
+)
+
+// witnessLimitsMtx prevents concurrent calls to SetWitnessLimits.
+var witnessLimitsMtx sync.Mutex
+
+// SetWitnessLimits changes the maximum number of witness items per input and
+// the maximum size of a single witness item which are accepted when decoding a
+// transaction.  The defaults are derived from the current consensus rules, a
+// node which wants to relay more strictly may lower them and one which is
+// experimenting with a soft fork may raise them.
+//
+// This must be called during initialization, before any messages are decoded.
+func SetWitnessLimits(maxItemsPerInput, maxItemSize int) er.R {
+	if maxItemsPerInput <= 0 {
+		return er.Errorf("max witness items per input must be positive, got [%d]",
+			maxItemsPerInput)
+	}
+	if maxItemSize <= 0 || maxItemSize > MaxMessagePayload {
+		return er.Errorf("max witness item size must be between 1 and [%d], got [%d]",
+			MaxMessagePayload, maxItemSize)
+	}
+	witnessLimitsMtx.Lock()
+	defer witnessLimitsMtx.Unlock()
+	witnessItemsPerInputLimit = uint64(maxItemsPerInput)
+	witnessItemSizeLimit = uint32(maxItemSize)
+	return nil
+}
 
 // OutPoint defines a bitcoin data type that is used to track previous
 // transaction outputs.
@@ -206,6 +381,32 @@ func NewTxIn(prevOut *OutPoint, signatureScript []byte, witness [][]byte) *TxIn
 	}
 }
 
+// SequenceForRelativeBlocks returns the sequence number which, per BIP0068,
+// prevents an input from being spent until n blocks after the output which it
+// spends was mined. Relative locktimes only apply to transactions of version 2
+// or higher.
+func SequenceForRelativeBlocks(n uint16) uint32 {
+	return uint32(n)
+}
+
+// SequenceForRelativeTime returns the sequence number which, per BIP0068,
+// prevents an input from being spent until d has passed since the output which
+// it spends was mined. Relative time locks have a granularity of 512 seconds so
+// d is rounded up to the next multiple of 512 seconds. An error is returned if
+// d is negative or too long to be represented, the maximum is 65535 * 512
+// seconds. Relative locktimes only apply to transactions of version 2 or higher.
+func SequenceForRelativeTime(d time.Duration) (uint32, er.R) {
+	const unit = time.Duration(1<<constants.SequenceLockTimeGranularity) * time.Second
+	const max = unit * constants.SequenceLockTimeMask
+	if d < 0 {
+		return 0, er.Errorf("relative locktime [%s] is negative", d)
+	} else if d > max {
+		return 0, er.Errorf("relative locktime [%s] is more than the maximum of [%s]", d, max)
+	}
+	units := (d + unit - 1) / unit
+	return constants.SequenceLockTimeIsSeconds | uint32(units), nil
+}
+
 // TxWitness defines the witness for a TxIn. A witness is to be interpreted as
 // a slice of byte slices, or a stack with one or many elements.
 type TxWitness [][]byte
@@ -227,6 +428,30 @@ func (t TxWitness) SerializeSize() int {
 	return n
 }
 
+// TaprootAnnexTag is the first byte of the annex of a taproot witness.
+const TaprootAnnexTag = 0x50
+
+// HasAnnex returns true if the witness carries a taproot annex, which per
+// BIP0341 is when there are at least two witness items and the last one
+// begins with TaprootAnnexTag.  Whether the input actually spends a taproot
+// output is not known here so the caller must check that.
+func (t TxWitness) HasAnnex() bool {
+	if len(t) < 2 {
+		return false
+	}
+	last := t[len(t)-1]
+	return len(last) > 0 && last[0] == TaprootAnnexTag
+}
+
+// Annex returns the taproot annex of the witness, including the leading
+// TaprootAnnexTag, or nil if the witness does not carry one.
+func (t TxWitness) Annex() []byte {
+	if !t.HasAnnex() {
+		return nil
+	}
+	return t[len(t)-1]
+}
+
 // TxOut defines a bitcoin transaction output.
 type TxOut struct {
 	Value    int64
@@ -285,6 +510,99 @@ func (msg *MsgTx) AddTxOut(to *TxOut) {
 	msg.TxOut = append(msg.TxOut, to)
 }
 
+// InsertTxIn inserts a transaction input at index, shifting the inputs at and
+// after index along by one. If the transaction has Additional info then an
+// empty entry is inserted at the same index so that it remains aligned with
+// the inputs. An error is returned if index is out of range.
+func (msg *MsgTx) InsertTxIn(index int, ti *TxIn) er.R {
+	if index < 0 || index > len(msg.TxIn) {
+		return er.Errorf("InsertTxIn: index [%d] out of range, tx has [%d] inputs",
+			index, len(msg.TxIn))
+	}
+	if len(msg.Additional) > 0 {
+		if len(msg.Additional) != len(msg.TxIn) {
+			return er.Errorf("InsertTxIn: len(Additional) = [%d] but len(TxIn) = [%d]",
+				len(msg.Additional), len(msg.TxIn))
+		}
+		msg.Additional = append(msg.Additional, TxInAdditional{})
+		copy(msg.Additional[index+1:], msg.Additional[index:])
+		msg.Additional[index] = TxInAdditional{}
+	}
+	msg.TxIn = append(msg.TxIn, nil)
+	copy(msg.TxIn[index+1:], msg.TxIn[index:])
+	msg.TxIn[index] = ti
+	return nil
+}
+
+// InsertTxOut inserts a transaction output at index, shifting the outputs at
+// and after index along by one. An error is returned if index is out of range.
+func (msg *MsgTx) InsertTxOut(index int, to *TxOut) er.R {
+	if index < 0 || index > len(msg.TxOut) {
+		return er.Errorf("InsertTxOut: index [%d] out of range, tx has [%d] outputs",
+			index, len(msg.TxOut))
+	}
+	msg.TxOut = append(msg.TxOut, nil)
+	copy(msg.TxOut[index+1:], msg.TxOut[index:])
+	msg.TxOut[index] = to
+	return nil
+}
+
+// OutputSum returns the total value of the outputs of the transaction. If the
+// total does not fit in an int64 then math.MaxInt64 is returned.
+func (msg *MsgTx) OutputSum() int64 {
+	var sum int64
+	for _, txOut := range msg.TxOut {
+		if txOut.Value > 0 && sum > math.MaxInt64-txOut.Value {
+			return math.MaxInt64
+		}
+		sum += txOut.Value
+	}
+	return sum
+}
+
+// InputSum returns the total value of the outputs spent by the transaction,
+// using the Value of each input from Additional, as decoded from EPTF. An error
+// is returned if the value of any input is unknown or if the total does not
+// fit in an int64.
+func (msg *MsgTx) InputSum() (int64, er.R) {
+	if len(msg.Additional) != len(msg.TxIn) {
+		return 0, er.Errorf("len(Additional) = [%d] but len(TxIn) = [%d]",
+			len(msg.Additional), len(msg.TxIn))
+	}
+	var sum int64
+	for i, add := range msg.Additional {
+		if add.Value == nil {
+			return 0, er.Errorf("value of input [%d] is unknown", i)
+		}
+		v := *add.Value
+		if v < 0 {
+			return 0, er.Errorf("value of input [%d] is negative [%d]", i, v)
+		} else if sum > math.MaxInt64-v {
+			return 0, er.Errorf("sum of input values overflows at input [%d]", i)
+		}
+		sum += v
+	}
+	return sum, nil
+}
+
+// IsCoinBase determines whether or not the transaction is a coinbase.  A
+// coinbase is a special transaction created by miners that has no inputs.
+// This is represented in the block chain by a transaction with a single input
+// that has a previous output transaction index set to the maximum value along
+// with a zero hash.
+func (msg *MsgTx) IsCoinBase() bool {
+	// A coin base must only have one transaction input.
+	if len(msg.TxIn) != 1 {
+		return false
+	}
+
+	// The previous output of a coin base must have a max value index and
+	// a zero hash.
+	prevOut := &msg.TxIn[0].PreviousOutPoint
+	return prevOut.Index == constants.MaxPrevOutIndex &&
+		prevOut.Hash == (chainhash.Hash{})
+}
+
 // TxHash generates the Hash for the transaction.
 func (msg *MsgTx) TxHash() chainhash.Hash {
 	// Encode the transaction and calculate double sha256 on the result.
@@ -296,6 +614,19 @@ func (msg *MsgTx) TxHash() chainhash.Hash {
 	return chainhash.DoubleHashH(buf.Bytes())
 }
 
+// TxHashStreaming generates the same hash as TxHash, but the transaction is
+// serialized directly into the hasher rather than into an intermediate buffer,
+// so hashing a very large transaction does not require a copy of it in memory.
+func (msg *MsgTx) TxHashStreaming() chainhash.Hash {
+	// As with TxHash, encoding into a hasher can not fail except for nil
+	// pointers, which would cause a run-time panic.
+	h := sha256.New()
+	_ = msg.SerializeNoWitness(h)
+	var first chainhash.Hash
+	h.Sum(first[:0])
+	return chainhash.Hash(sha256.Sum256(first[:]))
+}
+
 // WitnessHash generates the hash of the transaction serialized according to
 // the new witness serialization defined in BIP0141 and BIP0144. The final
 // output is used within the Segregated Witness commitment of all the witnesses
@@ -311,6 +642,156 @@ func (msg *MsgTx) WitnessHash() chainhash.Hash {
 	return msg.TxHash()
 }
 
+// isWitnessProgram returns true if pkScript is a native segwit output script,
+// a version opcode followed by a single push of 2 to 40 bytes.
+func isWitnessProgram(pkScript []byte) bool {
+	if len(pkScript) < 4 || len(pkScript) > 42 {
+		return false
+	}
+	if pkScript[0] != opcode.OP_0 &&
+		(pkScript[0] < opcode.OP_1 || pkScript[0] > opcode.OP_16) {
+		return false
+	}
+	return int(pkScript[1]) == len(pkScript)-2
+}
+
+// PlannedTxHash returns the txid which the transaction will have once it is
+// fully signed. Signatures of segwit inputs are not part of the txid so an
+// EPTF transaction whose unsigned inputs all spend native segwit outputs
+// already has a known txid. An error is returned if any unsigned input spends
+// a non-segwit output, or one whose pkScript is not known, because its
+// SignatureScript will change the txid.
+func (msg *MsgTx) PlannedTxHash() (chainhash.Hash, er.R) {
+	for i, txIn := range msg.TxIn {
+		if isSignedEptfInput(txIn) {
+			continue
+		}
+		if i >= len(msg.Additional) || len(msg.Additional[i].PkScript) == 0 {
+			return chainhash.Hash{}, er.Errorf("input [%d] is unsigned and its "+
+				"pkScript is unknown, cannot compute txid", i)
+		}
+		if !isWitnessProgram(msg.Additional[i].PkScript) {
+			return chainhash.Hash{}, er.Errorf("input [%d] is unsigned and spends "+
+				"a non-segwit output, cannot compute txid", i)
+		}
+	}
+	return msg.TxHash(), nil
+}
+
+// AdditionalByOutpoint returns the additional info of each input, as decoded
+// from EPTF, keyed by the outpoint which the input spends.  An error is
+// returned if there is not exactly one TxInAdditional per input or if two
+// inputs spend the same outpoint.
+func (msg *MsgTx) AdditionalByOutpoint() (map[OutPoint]TxInAdditional, er.R) {
+	if len(msg.Additional) != len(msg.TxIn) {
+		return nil, er.Errorf("len(Additional) = [%d] but len(TxIn) = [%d]",
+			len(msg.Additional), len(msg.TxIn))
+	}
+	out := make(map[OutPoint]TxInAdditional, len(msg.TxIn))
+	for i, txIn := range msg.TxIn {
+		if _, ok := out[txIn.PreviousOutPoint]; ok {
+			return nil, er.Errorf("outpoint [%s] is spent by more than one input",
+				txIn.PreviousOutPoint.String())
+		}
+		out[txIn.PreviousOutPoint] = msg.Additional[i]
+	}
+	return out, nil
+}
+
+// SpentOutpoints returns the set of outpoints which are spent by the inputs of
+// the transaction.  If two inputs spend the same outpoint then the set has
+// fewer entries than the transaction has inputs, see HasDuplicateInputs.
+func (msg *MsgTx) SpentOutpoints() map[OutPoint]struct{} {
+	out := make(map[OutPoint]struct{}, len(msg.TxIn))
+	for _, txIn := range msg.TxIn {
+		out[txIn.PreviousOutPoint] = struct{}{}
+	}
+	return out
+}
+
+// HasDuplicateInputs returns true if more than one input of the transaction
+// spends the same outpoint, such a transaction conflicts with itself.
+func (msg *MsgTx) HasDuplicateInputs() bool {
+	return len(msg.SpentOutpoints()) != len(msg.TxIn)
+}
+
+// isSignedEptfInput returns true if txIn carries a signature, either in the
+// SignatureScript or in the witness.
+func isSignedEptfInput(txIn *TxIn) bool {
+	return len(txIn.SignatureScript) > 0 || len(txIn.Witness) > 0
+}
+
+// sameEptfSignature returns true if the two inputs carry identical signatures.
+func sameEptfSignature(a, b *TxIn) bool {
+	if !bytes.Equal(a.SignatureScript, b.SignatureScript) || len(a.Witness) != len(b.Witness) {
+		return false
+	}
+	for i := range a.Witness {
+		if !bytes.Equal(a.Witness[i], b.Witness[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CombineEptf merges two copies of the same partially signed transaction, as
+// produced by different signers, into one which carries every signature from
+// both. The transactions must have the same version, locktime, inputs and
+// outputs. Each input takes its signature from whichever copy has it, if both
+// copies have different signatures for the same input then an error is
+// returned. The Additional info of a is used, falling back to that of b for
+// inputs where a has none. Neither a nor b is modified.
+func CombineEptf(a, b *MsgTx) (*MsgTx, er.R) {
+	if a.Version != b.Version {
+		return nil, er.Errorf("CombineEptf: version [%d] != [%d]", a.Version, b.Version)
+	} else if a.LockTime != b.LockTime {
+		return nil, er.Errorf("CombineEptf: locktime [%d] != [%d]", a.LockTime, b.LockTime)
+	} else if len(a.TxIn) != len(b.TxIn) {
+		return nil, er.Errorf("CombineEptf: [%d] inputs != [%d] inputs", len(a.TxIn), len(b.TxIn))
+	} else if len(a.TxOut) != len(b.TxOut) {
+		return nil, er.Errorf("CombineEptf: [%d] outputs != [%d] outputs",
+			len(a.TxOut), len(b.TxOut))
+	}
+	for i, out := range a.TxOut {
+		if out.Value != b.TxOut[i].Value || !bytes.Equal(out.PkScript, b.TxOut[i].PkScript) {
+			return nil, er.Errorf("CombineEptf: output [%d] differs", i)
+		}
+	}
+
+	out := a.Copy()
+	bIn := b.Copy().TxIn
+	if len(a.Additional) > 0 || len(b.Additional) > 0 {
+		out.Additional = make([]TxInAdditional, len(a.TxIn))
+	}
+	for i, in := range out.TxIn {
+		other := bIn[i]
+		if in.PreviousOutPoint != other.PreviousOutPoint {
+			return nil, er.Errorf("CombineEptf: input [%d] spends [%s] in one transaction "+
+				"and [%s] in the other", i, in.PreviousOutPoint.String(),
+				other.PreviousOutPoint.String())
+		} else if in.Sequence != other.Sequence {
+			return nil, er.Errorf("CombineEptf: input [%d] sequence [%d] != [%d]",
+				i, in.Sequence, other.Sequence)
+		}
+		if !isSignedEptfInput(in) {
+			in.SignatureScript = other.SignatureScript
+			in.Witness = other.Witness
+		} else if isSignedEptfInput(other) && !sameEptfSignature(in, other) {
+			return nil, er.Errorf("CombineEptf: input [%d] spending [%s] has conflicting "+
+				"signatures", i, in.PreviousOutPoint.String())
+		}
+		if out.Additional == nil {
+			continue
+		}
+		if i < len(a.Additional) && len(a.Additional[i].PkScript) > 0 {
+			out.Additional[i] = a.Additional[i]
+		} else if i < len(b.Additional) {
+			out.Additional[i] = b.Additional[i]
+		}
+	}
+	return out, nil
+}
+
 // Copy creates a deep copy of a transaction so that the original does not get
 // modified when the copy is manipulated.
 func (msg *MsgTx) Copy() *MsgTx {
@@ -386,12 +867,57 @@ func (msg *MsgTx) Copy() *MsgTx {
 	return &newTx
 }
 
+// normalizeBytes returns nil if b is empty, otherwise b.
+func normalizeBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+// Normalize gives the transaction a canonical representation, so that two
+// transactions which serialize the same way and carry the same Additional info
+// are also equal according to reflect.DeepEqual. Empty scripts, witness stacks
+// and witness items are set to nil, and if no entry of Additional carries any
+// information then Additional is set to nil. Transactions which are decoded,
+// copied or built by hand can otherwise differ in whether these are nil, so
+// they should be normalized before they are compared.
+func (msg *MsgTx) Normalize() {
+	for _, ti := range msg.TxIn {
+		ti.SignatureScript = normalizeBytes(ti.SignatureScript)
+		if len(ti.Witness) == 0 {
+			ti.Witness = nil
+		}
+		for i, item := range ti.Witness {
+			ti.Witness[i] = normalizeBytes(item)
+		}
+	}
+	for _, to := range msg.TxOut {
+		to.PkScript = normalizeBytes(to.PkScript)
+	}
+	hasAdditional := false
+	for i := range msg.Additional {
+		add := &msg.Additional[i]
+		add.PkScript = normalizeBytes(add.PkScript)
+		if add.PkScript != nil || add.Value != nil {
+			hasAdditional = true
+		}
+	}
+	if !hasAdditional {
+		msg.Additional = nil
+	}
+}
+
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 // See Deserialize for decoding transactions stored to disk, such as in a
 // database, as opposed to decoding transactions from the wire.
 func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R {
 
+	// When decoding from a sliceReader the scripts are subslices of the
+	// input rather than buffers borrowed from the pool.
+	_, aliased := r.(*sliceReader)
+
 	version, err := binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
@@ -424,7 +950,7 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 	// A count of zero (meaning no TxIn's to the uninitiated) indicates
 	// this is a transaction with witness data.
 	var flag [1]byte
-	if count == 0 && enc == WitnessEncoding {
+	if count == 0 && enc.withWitness() {
 		// Next, we need to read the flag, which is a single byte.
 		if _, errr := io.ReadFull(r, flag[:]); errr != nil {
 			return er.E(errr)
@@ -457,6 +983,9 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 			maxTxInPerMessage)
 		return messageError("MsgTx.BtcDecode", str)
 	}
+	if err := checkSizeLimit(r, count*minTxInPayload, "transaction inputs"); err != nil {
+		return err
+	}
 
 	// returnScriptBuffers is a closure that returns any script buffers that
 	// were borrowed from the pool when there are any deserialization
@@ -464,6 +993,9 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 	// replaces the scripts with the location in a contiguous buffer and
 	// returns them.
 	returnScriptBuffers := func() {
+		if aliased {
+			return
+		}
 		for _, txIn := range msg.TxIn {
 			if txIn == nil {
 				continue
@@ -491,8 +1023,9 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 	var totalScriptSize uint64
 	txIns := make([]TxIn, count)
 	msg.TxIn = make([]*TxIn, count)
+	var additionalValues []int64
 	if eptf {
-		msg.Additional = make([]TxInAdditional, count)
+		msg.Additional, additionalValues = makeTxInAdditional(count)
 	}
 	for i := uint64(0); i < count; i++ {
 		// The pointer is set now in case a script buffer is borrowed
@@ -527,6 +1060,10 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 			maxTxOutPerMessage)
 		return messageError("MsgTx.BtcDecode", str)
 	}
+	if err := checkSizeLimit(r, count*constants.MinTxOutPayload, "transaction outputs"); err != nil {
+		returnScriptBuffers()
+		return err
+	}
 
 	// Deserialize the outputs.
 	txOuts := make([]TxOut, count)
@@ -546,7 +1083,7 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 
 	// If the transaction's flag byte isn't 0x00 at this point, then one or
 	// more of its inputs has accompanying witness data.
-	if flag[0] != 0 && enc == WitnessEncoding {
+	if flag[0] != 0 && enc.withWitness() {
 		for i, txin := range msg.TxIn {
 			// For each input, the witness is encoded as a stack
 			// with one or more items. Therefore, we first read a
@@ -573,20 +1110,24 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 					return err
 				} else {
 					witCount = wc
-					iamt := int64(amt)
-					msg.Additional[i].Value = &iamt
+					additionalValues[i] = int64(amt)
+					msg.Additional[i].Value = &additionalValues[i]
 				}
 			}
 
 			// Prevent a possible memory exhaustion attack by
 			// limiting the witCount value to a sane upper bound.
-			if witCount > maxWitnessItemsPerInput {
+			if witCount > witnessItemsPerInputLimit {
 				returnScriptBuffers()
 				str := fmt.Sprintf("too many witness items to fit "+
 					"into max message size [count %d, max %d]",
-					witCount, maxWitnessItemsPerInput)
+					witCount, witnessItemsPerInputLimit)
 				return messageError("MsgTx.BtcDecode", str)
 			}
+			if err := checkSizeLimit(r, witCount, "witness items"); err != nil {
+				returnScriptBuffers()
+				return err
+			}
 
 			// Then for witCount number of stack items, each item
 			// has a varint length prefix, followed by the witness
@@ -594,7 +1135,7 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 			txin.Witness = make([][]byte, witCount)
 			for j := uint64(0); j < witCount; j++ {
 				txin.Witness[j], err = readScript(r, pver,
-					maxWitnessItemSize, "script witness item")
+					witnessItemSizeLimit, "script witness item")
 				if err != nil {
 					returnScriptBuffers()
 					return err
@@ -610,6 +1151,10 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er.R
 		return err
 	}
 
+	if aliased {
+		return nil
+	}
+
 	// Create a single allocation to house all of the scripts and set each
 	// input signature script and output public key script to the
 	// appropriate subslice of the overall contiguous buffer.  Then, return
@@ -706,6 +1251,135 @@ func (msg *MsgTx) DeserializeNoWitness(r io.Reader) er.R {
 	return msg.BtcDecode(r, 0, BaseEncoding)
 }
 
+// DeserializeAuto decodes a transaction from r which may be encoded either in
+// the standard format or in EPTF, and returns whether it was EPTF.  The first
+// bytes are read ahead to look for the EPTF magic and are then replayed ahead
+// of the rest of r, so the caller does not need to know the format in advance.
+func DeserializeAuto(r io.Reader) (*MsgTx, bool, er.R) {
+	peeked := make([]byte, len(eptfMagicBytes))
+	n, errr := io.ReadFull(r, peeked)
+	if errr != nil && errr != io.EOF && errr != io.ErrUnexpectedEOF {
+		return nil, false, er.E(errr)
+	}
+	peeked = peeked[:n]
+	eptf := IsEptf(peeked)
+
+	var msg MsgTx
+	if err := msg.Deserialize(io.MultiReader(bytes.NewReader(peeked), r)); err != nil {
+		return nil, eptf, err
+	}
+	return &msg, eptf, nil
+}
+
+// sliceReader is an io.Reader over a byte slice which allows readScript to
+// take scripts as subslices of the input instead of copying them.
+type sliceReader struct {
+	b   []byte
+	off int
+}
+
+func (s *sliceReader) Read(p []byte) (int, error) {
+	if s.off >= len(s.b) && len(p) > 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, s.b[s.off:])
+	s.off += n
+	return n, nil
+}
+
+// next returns the next n bytes of the input without copying them, the cap of
+// the result is limited so that appending to it cannot overwrite the input.
+func (s *sliceReader) next(n uint64) ([]byte, bool) {
+	if n > uint64(len(s.b)-s.off) {
+		s.off = len(s.b)
+		return nil, false
+	}
+	end := s.off + int(n)
+	b := s.b[s.off:end:end]
+	s.off = end
+	return b, true
+}
+
+// DeserializeFromBytes decodes a transaction from the beginning of b into the
+// receiver in the same way as Deserialize and returns the number of bytes
+// which it consumed, so that the transactions of a block which is held in
+// memory can be decoded one after another by offset.
+//
+// NOTE: The signature scripts, witness items and public key scripts of the
+// decoded transaction are subslices of b rather than copies, so b must not be
+// modified while the transaction is in use and the transaction keeps all of b
+// from being garbage collected. Use Deserialize if this is not acceptable.
+func (msg *MsgTx) DeserializeFromBytes(b []byte) (int, er.R) {
+	r := &sliceReader{b: b}
+	err := msg.BtcDecode(r, 0, WitnessEncoding)
+	return r.off, err
+}
+
+// txSizeLimitReader is a reader which refuses any read that would take the
+// total number of bytes read past limit.
+type txSizeLimitReader struct {
+	r        io.Reader
+	limit    uint32
+	read     uint32
+	exceeded bool
+}
+
+func (l *txSizeLimitReader) Read(p []byte) (int, error) {
+	if uint64(l.read)+uint64(len(p)) > uint64(l.limit) {
+		l.exceeded = true
+		return 0, er.Native(messageError("txSizeLimitReader.Read",
+			fmt.Sprintf("transaction is larger than the limit of [%d] bytes", l.limit)))
+	}
+	n, err := l.r.Read(p)
+	l.read += uint32(n)
+	return n, err
+}
+
+// reserve fails if n more bytes would take the total number of bytes read past
+// the limit, so that a declared count or length which cannot fit is rejected
+// before anything is allocated for it.
+func (l *txSizeLimitReader) reserve(n uint64, fieldName string) er.R {
+	if remaining := uint64(l.limit - l.read); n > remaining {
+		l.exceeded = true
+		str := fmt.Sprintf("%s need at least [%d] bytes but only [%d] remain "+
+			"within the limit of [%d] bytes", fieldName, n, remaining, l.limit)
+		return messageError("txSizeLimitReader.reserve", str)
+	}
+	return nil
+}
+
+// checkSizeLimit reserves n bytes of the remaining size limit if r is limited
+// by DeserializeLimited, otherwise it does nothing.
+func checkSizeLimit(r io.Reader, n uint64, fieldName string) er.R {
+	if l, ok := r.(*txSizeLimitReader); ok {
+		return l.reserve(n, fieldName)
+	}
+	return nil
+}
+
+// DeserializeLimited decodes a transaction from r into the receiver in the
+// same way as Deserialize, but it aborts with a MessageError as soon as the
+// transaction is found to be larger than maxSize bytes, before the remainder
+// of the transaction is read.  This allows callers reading many transactions
+// back to back to bound the memory used by any single one of them, maxSize
+// must not exceed MaxBlockPayload.
+func (msg *MsgTx) DeserializeLimited(r io.Reader, maxSize uint32) er.R {
+	if maxSize > MaxBlockPayload {
+		str := fmt.Sprintf("size limit [%d] is larger than MaxBlockPayload [%d]",
+			maxSize, MaxBlockPayload)
+		return messageError("MsgTx.DeserializeLimited", str)
+	}
+	lr := &txSizeLimitReader{r: r, limit: maxSize}
+	if err := msg.Deserialize(lr); err != nil {
+		if lr.exceeded {
+			str := fmt.Sprintf("transaction is larger than the limit of [%d] bytes", maxSize)
+			return messageError("MsgTx.DeserializeLimited", str)
+		}
+		return err
+	}
+	return nil
+}
+
 func write32(w io.Writer, x uint32) er.R {
 	var b [4]byte
 	binary.LittleEndian.PutUint32(b[:], x)
@@ -732,13 +1406,22 @@ func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) er.R
 			return er.New("EptfEncoding was specified but transaction has incomplete input " +
 				"additional info")
 		}
+		if enc&_StrictEptfEncoding != 0 {
+			for i, ti := range msg.TxIn {
+				if len(ti.SignatureScript) > 0 && len(msg.Additional[i].PkScript) > 0 {
+					return EptfScriptConflictError.New(fmt.Sprintf("input [%d] spending [%s] "+
+						"has both a SignatureScript and an Additional PkScript",
+						i, ti.PreviousOutPoint.String()), nil)
+				}
+			}
+		}
 
 		// magic
-		if _, err := w.Write([]byte("EPTF\xff\x00")); err != nil {
+		if _, err := w.Write(eptfMagicBytes); err != nil {
 			return er.E(err)
 		}
 		eptf = true
-	} else if enc&ForceEptfEncoding != 0 {
+	} else if enc&_ForceEptfEncoding != 0 {
 		return er.New("EptfEncoding was specified but transaction is missing input " +
 			"additional info")
 	}
@@ -748,11 +1431,11 @@ func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) er.R
 		return err
 	}
 
-	// If the encoding version is set to WitnessEncoding, and the Flags
-	// field for the MsgTx aren't 0x00, then this indicates the transaction
-	// is to be encoded using the new witness inclusionary structure
-	// defined in BIP0144.
-	doWitness := (enc == WitnessEncoding && msg.HasWitness()) || eptf
+	// If the encoding includes WitnessEncoding, and the Flags field for
+	// the MsgTx aren't 0x00, then this indicates the transaction is to be
+	// encoded using the new witness inclusionary structure defined in
+	// BIP0144.
+	doWitness := (enc.withWitness() && msg.HasWitness()) || eptf
 	if doWitness {
 		// After the txn's Version field, we include two additional
 		// bytes specific to the witness encoding. The first byte is an
@@ -866,6 +1549,23 @@ func (msg *MsgTx) Serialize(w io.Writer) er.R {
 	return msg.BtcEncode(w, 0, WitnessEncoding)
 }
 
+// SerializeInto resets buf and serializes the transaction into it in the same
+// way as Serialize, growing buf once to the serialized size so that at most one
+// allocation is made. Code which serializes many transactions can avoid
+// allocating a buffer for each one by taking buffers from a sync.Pool:
+//
+//	var bufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+//
+//	buf := bufPool.Get().(*bytes.Buffer)
+//	if err := tx.SerializeInto(buf); err != nil { ... }
+//	... use buf.Bytes(), it must not be retained after the buffer is returned ...
+//	bufPool.Put(buf)
+func (msg *MsgTx) SerializeInto(buf *bytes.Buffer) er.R {
+	buf.Reset()
+	buf.Grow(msg.SerializeSize())
+	return msg.Serialize(buf)
+}
+
 // SerializeNoWitness encodes the transaction to w in an identical manner to
 // Serialize, however even if the source transaction has inputs with witness
 // data, the old serialization format will still be used.
@@ -873,6 +1573,43 @@ func (msg *MsgTx) SerializeNoWitness(w io.Writer) er.R {
 	return msg.BtcEncode(w, 0, BaseEncoding)
 }
 
+// ToHex serializes the transaction in the same way as Serialize and returns
+// the result as a hex string.
+func (msg *MsgTx) ToHex() (string, er.R) {
+	b := bytes.NewBuffer(make([]byte, 0, msg.SerializeSize()))
+	if err := msg.Serialize(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b.Bytes()), nil
+}
+
+// NewMsgTxFromHex decodes a transaction from a hex string as produced by
+// ToHex, surrounding whitespace is ignored.  An error is returned if the
+// string is not valid hex or if it contains anything other than exactly one
+// transaction.
+func NewMsgTxFromHex(s string) (*MsgTx, er.R) {
+	s = strings.TrimSpace(s)
+	if len(s)%2 != 0 {
+		str := fmt.Sprintf("hex string has odd length [%d]", len(s))
+		return nil, messageError("NewMsgTxFromHex", str)
+	}
+	serialized, errr := hex.DecodeString(s)
+	if errr != nil {
+		str := fmt.Sprintf("invalid hex string: %v", errr)
+		return nil, messageError("NewMsgTxFromHex", str)
+	}
+	r := bytes.NewReader(serialized)
+	var msg MsgTx
+	if err := msg.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		str := fmt.Sprintf("[%d] unexpected bytes after the transaction", r.Len())
+		return nil, messageError("NewMsgTxFromHex", str)
+	}
+	return &msg, nil
+}
+
 // baseSize returns the serialized size of the transaction without accounting
 // for any witness data.
 func (msg *MsgTx) baseSize() int {
@@ -911,6 +1648,71 @@ func (msg *MsgTx) SerializeSize() int {
 	return n
 }
 
+// eptfPlaceholderSize returns the size of the placeholder script which stands in
+// for the signature script of an unsigned input in EPTF, it is
+// OP_INVALIDOPCODE OP_0 <0xfd || pkScript>.
+func eptfPlaceholderSize(pkScript []byte) int {
+	dataLen := len(pkScript) + 1
+	n := 2 + dataLen
+	switch {
+	case dataLen < opcode.OP_PUSHDATA1:
+		n++
+	case dataLen <= 0xff:
+		n += 2
+	case dataLen <= 0xffff:
+		n += 3
+	default:
+		n += 5
+	}
+	return n
+}
+
+// SerializeSizeEptf returns the number of bytes it would take to serialize the
+// transaction using EptfEncoding.  This includes the EPTF magic, the
+// placeholder scripts of unsigned inputs and the amount hints which are stored
+// alongside the witnesses.  If the transaction does not have additional info
+// for every input then it cannot be encoded in EPTF and the result is the same
+// as SerializeSize.
+func (msg *MsgTx) SerializeSizeEptf() int {
+	if len(msg.Additional) == 0 || len(msg.Additional) < len(msg.TxIn) {
+		return msg.SerializeSize()
+	}
+
+	// Magic 6 bytes + Version 4 bytes + marker and flag 2 bytes + LockTime
+	// 4 bytes + Serialized varint size for the number of transaction inputs
+	// and outputs.
+	n := 16 + VarIntSerializeSize(uint64(len(msg.TxIn))) +
+		VarIntSerializeSize(uint64(len(msg.TxOut)))
+
+	for i, txIn := range msg.TxIn {
+		add := &msg.Additional[i]
+
+		// Outpoint Hash 32 bytes + Outpoint Index 4 bytes + Sequence 4
+		// bytes.
+		n += 40
+		if len(add.PkScript) > 0 && len(txIn.SignatureScript) == 0 {
+			scriptLen := eptfPlaceholderSize(add.PkScript)
+			n += VarIntSerializeSize(uint64(scriptLen)) + scriptLen
+		} else {
+			n += VarIntSerializeSize(uint64(len(txIn.SignatureScript))) +
+				len(txIn.SignatureScript)
+		}
+
+		// The amount hint is a 5 byte varint marker, the 8 byte amount
+		// and a 2 byte version.
+		if add.Value != nil {
+			n += 15
+		}
+		n += txIn.Witness.SerializeSize()
+	}
+
+	for _, txOut := range msg.TxOut {
+		n += txOut.SerializeSize()
+	}
+
+	return n
+}
+
 // SerializeSizeStripped returns the number of bytes it would take to serialize
 // the transaction, excluding any included witness data.
 func (msg *MsgTx) SerializeSizeStripped() int {
@@ -1029,6 +1831,17 @@ func readScript(r io.Reader, pver uint32, maxAllowed uint32, fieldName string) (
 			"[count %d, max %d]", fieldName, count, maxAllowed)
 		return nil, messageError("readScript", str)
 	}
+	if err := checkSizeLimit(r, count, fieldName); err != nil {
+		return nil, err
+	}
+
+	if sr, ok := r.(*sliceReader); ok {
+		b, ok := sr.next(count)
+		if !ok {
+			return nil, er.E(io.ErrUnexpectedEOF)
+		}
+		return b, nil
+	}
 
 	b := scriptPool.Borrow(count)
 	_, errr := io.ReadFull(r, b)
@@ -1039,6 +1852,15 @@ func readScript(r io.Reader, pver uint32, maxAllowed uint32, fieldName string) (
 	return b, nil
 }
 
+// makeTxInAdditional allocates the additional info for count inputs of an EPTF
+// transaction along with one backing array for the values, so that decoding
+// the values does not make a separate allocation per input.  The caller must
+// have checked count against maxTxInPerMessage, so no more than
+// maxTxInAdditionalBytes are allocated.
+func makeTxInAdditional(count uint64) ([]TxInAdditional, []int64) {
+	return make([]TxInAdditional, count), make([]int64, count)
+}
+
 // readTxIn reads the next sequence of bytes from r as a transaction input
 // (TxIn).
 func readTxIn(r io.Reader, pver uint32, version int32, ti *TxIn, add *TxInAdditional) er.R {
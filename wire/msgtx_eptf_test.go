@@ -0,0 +1,505 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"runtime"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pkt-cash/pktd/chaincfg/chainhash"
+)
+
+// eptfValue is the amount of the inputs in the EPTF test transactions.
+var eptfValue = int64(5000000000)
+
+// newEptfTx returns a copy of multiWitnessTx with the Additional info of its
+// input so that it is encoded as EPTF.
+func newEptfTx() *MsgTx {
+	tx := multiWitnessTx.Copy()
+	tx.Additional = []TxInAdditional{{
+		PkScript: multiWitnessTx.TxOut[0].PkScript,
+		Value:    &eptfValue,
+	}}
+	return tx
+}
+
+// newUnsignedEptfTx returns a copy of multiWitnessTx with one unsigned input
+// for each of pkScripts, which make up the Additional info.
+func newUnsignedEptfTx(pkScripts ...[]byte) *MsgTx {
+	tx := multiWitnessTx.Copy()
+	for len(tx.TxIn) < len(pkScripts) {
+		tx.TxIn = append(tx.TxIn, &TxIn{
+			PreviousOutPoint: OutPoint{Hash: chainhash.Hash{0x01}, Index: uint32(len(tx.TxIn))},
+			Sequence:         0xffffffff,
+		})
+	}
+	tx.Additional = make([]TxInAdditional, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+		if i < len(pkScripts) {
+			tx.Additional[i] = TxInAdditional{PkScript: pkScripts[i], Value: &eptfValue}
+		}
+	}
+	return tx
+}
+
+// TestTxSerializeSizeEptf tests that SerializeSizeEptf matches the length of
+// the EPTF encoding for signed and unsigned inputs of various script sizes.
+func TestTxSerializeSizeEptf(t *testing.T) {
+	unsigned := func(pkScriptLen int) *MsgTx {
+		tx := multiTx.Copy()
+		tx.TxIn[0].SignatureScript = nil
+		tx.Additional = []TxInAdditional{{
+			PkScript: bytes.Repeat([]byte{0x51}, pkScriptLen),
+			Value:    &eptfValue,
+		}}
+		return tx
+	}
+	signed := multiTx.Copy()
+	signed.Additional = []TxInAdditional{{PkScript: []byte{0x51}}}
+	tests := []struct {
+		name string
+		tx   *MsgTx
+	}{
+		{"unsigned p2wkh", unsigned(22)},
+		{"unsigned pushdata1", unsigned(100)},
+		{"unsigned pushdata2", unsigned(300)},
+		{"signed without amount", signed},
+		{"witness with amount", newEptfTx()},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := test.tx.BtcEncode(&b, 0, EptfEncoding); err != nil {
+			t.Errorf("%s: BtcEncode: %v", test.name, err)
+			continue
+		}
+		if size := test.tx.SerializeSizeEptf(); size != b.Len() {
+			t.Errorf("%s: SerializeSizeEptf: got %d, want %d",
+				test.name, size, b.Len())
+		}
+	}
+
+	// Without additional info the transaction is serialized normally.
+	if size := multiTx.SerializeSizeEptf(); size != multiTx.SerializeSize() {
+		t.Errorf("SerializeSizeEptf: got %d, want %d", size,
+			multiTx.SerializeSize())
+	}
+}
+
+// TestTxAdditionalByOutpoint tests that the additional info of a decoded EPTF
+// transaction is keyed by the outpoint of each input.
+func TestTxAdditionalByOutpoint(t *testing.T) {
+	tx := multiTx.Copy()
+	tx.TxIn[0].SignatureScript = nil
+	second := *tx.TxIn[0]
+	second.PreviousOutPoint.Index++
+	tx.TxIn = append(tx.TxIn, &second)
+	values := []int64{1000, 2000}
+	tx.Additional = []TxInAdditional{
+		{PkScript: []byte{0x51}, Value: &values[0]},
+		{PkScript: []byte{0x52}, Value: &values[1]},
+	}
+
+	var b bytes.Buffer
+	if err := tx.BtcEncode(&b, 0, ForceEptfEncoding); err != nil {
+		t.Fatalf("BtcEncode: %v", err)
+	}
+	var decoded MsgTx
+	if err := decoded.Deserialize(&b); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	byOutpoint, err := decoded.AdditionalByOutpoint()
+	if err != nil {
+		t.Fatalf("AdditionalByOutpoint: %v", err)
+	}
+	if len(byOutpoint) != len(tx.TxIn) {
+		t.Fatalf("AdditionalByOutpoint: got %d entries, want %d",
+			len(byOutpoint), len(tx.TxIn))
+	}
+	for i, txIn := range tx.TxIn {
+		add, ok := byOutpoint[txIn.PreviousOutPoint]
+		if !ok {
+			t.Fatalf("AdditionalByOutpoint: missing input %d", i)
+		}
+		if !bytes.Equal(add.PkScript, tx.Additional[i].PkScript) {
+			t.Errorf("AdditionalByOutpoint: input %d got pkScript %x, "+
+				"want %x", i, add.PkScript, tx.Additional[i].PkScript)
+		}
+		if add.Value == nil || *add.Value != values[i] {
+			t.Errorf("AdditionalByOutpoint: input %d got value %v, "+
+				"want %d", i, add.Value, values[i])
+		}
+	}
+
+	decoded.Additional = decoded.Additional[:1]
+	if _, err := decoded.AdditionalByOutpoint(); err == nil {
+		t.Errorf("AdditionalByOutpoint: expected error for mismatched " +
+			"lengths")
+	}
+}
+
+// TestTxEncodingFlags tests the framing which BtcEncode produces for each of
+// the valid combinations of encoding flags and that it decodes back.
+func TestTxEncodingFlags(t *testing.T) {
+	eptfTx := newEptfTx()
+
+	tests := []struct {
+		name    string
+		tx      *MsgTx
+		enc     MessageEncoding
+		eptf    bool // expect EPTF magic
+		witness bool // expect the segwit marker and flag
+	}{
+		{"base", multiWitnessTx, BaseEncoding, false, false},
+		{"witness", multiWitnessTx, WitnessEncoding, false, true},
+		{"witness no witness data", multiTx, WitnessEncoding, false, false},
+		{"witness|packetcrypt", multiWitnessTx,
+			WitnessEncoding | PacketCryptEncoding, false, true},
+		{"witness|nopacketcrypt", multiWitnessTx,
+			WitnessEncoding | NoPacketCryptEncoding, false, true},
+		{"base|packetcrypt", multiWitnessTx,
+			BaseEncoding | PacketCryptEncoding, false, false},
+		{"eptf without additional", multiWitnessTx, EptfEncoding, false, true},
+		{"eptf|witness without additional", multiWitnessTx,
+			EptfEncoding | WitnessEncoding, false, true},
+		{"eptf", eptfTx, EptfEncoding, true, true},
+		{"eptf|witness", eptfTx, EptfEncoding | WitnessEncoding, true, true},
+		{"force eptf", eptfTx, ForceEptfEncoding, true, true},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := test.tx.BtcEncode(&b, 0, test.enc); err != nil {
+			t.Errorf("%s: BtcEncode: %v", test.name, err)
+			continue
+		}
+		buf := b.Bytes()
+		if test.eptf != bytes.HasPrefix(buf, []byte("EPTF\xff\x00")) {
+			t.Errorf("%s: got EPTF magic %v, want %v", test.name,
+				!test.eptf, test.eptf)
+			continue
+		}
+		if test.eptf {
+			buf = buf[6:]
+		}
+		hasMarker := bytes.Equal(buf[4:6], witessMarkerBytes)
+		if hasMarker != test.witness {
+			t.Errorf("%s: got witness marker %v, want %v", test.name,
+				hasMarker, test.witness)
+			continue
+		}
+
+		var decoded MsgTx
+		if err := decoded.BtcDecode(bytes.NewReader(b.Bytes()), 0, test.enc); err != nil {
+			t.Errorf("%s: BtcDecode: %v", test.name, err)
+			continue
+		}
+		if test.witness != decoded.HasWitness() {
+			t.Errorf("%s: decoded HasWitness %v, want %v", test.name,
+				decoded.HasWitness(), test.witness)
+		}
+	}
+
+	// ForceEptfEncoding requires the additional info.
+	var b bytes.Buffer
+	if err := multiWitnessTx.BtcEncode(&b, 0, ForceEptfEncoding); err == nil {
+		t.Errorf("ForceEptfEncoding: expected error without additional info")
+	}
+}
+
+// TestTxStrictEptfEncoding tests that an input having both a SignatureScript
+// and an Additional PkScript is accepted by EptfEncoding but rejected by
+// StrictEptfEncoding.
+func TestTxStrictEptfEncoding(t *testing.T) {
+	conflict := multiTx.Copy()
+	conflict.Additional = []TxInAdditional{{
+		PkScript: []byte{0x51},
+		Value:    &eptfValue,
+	}}
+
+	var lenient bytes.Buffer
+	if err := conflict.BtcEncode(&lenient, 0, EptfEncoding); err != nil {
+		t.Fatalf("EptfEncoding: %v", err)
+	}
+	var decoded MsgTx
+	if err := decoded.BtcDecode(bytes.NewReader(lenient.Bytes()), 0, EptfEncoding); err != nil {
+		t.Fatalf("BtcDecode: %v", err)
+	}
+	if !bytes.Equal(decoded.TxIn[0].SignatureScript, multiTx.TxIn[0].SignatureScript) {
+		t.Errorf("EptfEncoding did not preserve the SignatureScript")
+	}
+
+	var strict bytes.Buffer
+	err := conflict.BtcEncode(&strict, 0, StrictEptfEncoding)
+	if !EptfScriptConflictError.Is(err) {
+		t.Fatalf("StrictEptfEncoding: expected EptfScriptConflictError, got %v", err)
+	}
+	if strict.Len() != 0 {
+		t.Errorf("StrictEptfEncoding: wrote %d bytes before failing", strict.Len())
+	}
+
+	// An unsigned input with only the PkScript is fine in strict mode.
+	unsigned := conflict.Copy()
+	unsigned.TxIn[0].SignatureScript = nil
+	unsigned.Additional = conflict.Additional
+	strict.Reset()
+	if err := unsigned.BtcEncode(&strict, 0, StrictEptfEncoding); err != nil {
+		t.Fatalf("StrictEptfEncoding unsigned: %v", err)
+	}
+	if !bytes.Equal(strict.Bytes()[:6], []byte("EPTF\xff\x00")) {
+		t.Errorf("StrictEptfEncoding did not produce EPTF")
+	}
+}
+
+// TestCombineEptf tests combining two complementary partial signings of the
+// same transaction into a fully signed one.
+func TestCombineEptf(t *testing.T) {
+	base := newUnsignedEptfTx([]byte{0x00, 0x14, 0x01}, []byte{0x00, 0x14, 0x02})
+
+	// Round trip through EPTF as the signers would exchange it.
+	partial := func(signInput int) *MsgTx {
+		tx := base.Copy()
+		tx.Additional = base.Additional
+		tx.TxIn[signInput].Witness = TxWitness{{byte(signInput), 0xaa}, {0x02, 0x03}}
+		var b bytes.Buffer
+		if err := tx.BtcEncode(&b, 0, ForceEptfEncoding); err != nil {
+			t.Fatalf("BtcEncode: %v", err)
+		}
+		var decoded MsgTx
+		if err := decoded.BtcDecode(&b, 0, BaseEncoding); err != nil {
+			t.Fatalf("BtcDecode: %v", err)
+		}
+		return &decoded
+	}
+	a := partial(0)
+	b := partial(1)
+
+	combined, err := CombineEptf(a, b)
+	if err != nil {
+		t.Fatalf("CombineEptf: %v", err)
+	}
+	for i, in := range combined.TxIn {
+		want := TxWitness{{byte(i), 0xaa}, {0x02, 0x03}}
+		if !reflect.DeepEqual(in.Witness, want) {
+			t.Errorf("input %d: got witness %x, want %x", i, in.Witness, want)
+		}
+		if !bytes.Equal(combined.Additional[i].PkScript, base.Additional[i].PkScript) {
+			t.Errorf("input %d: got pkScript %x, want %x", i,
+				combined.Additional[i].PkScript, base.Additional[i].PkScript)
+		}
+	}
+	if len(a.TxIn[1].Witness) != 0 {
+		t.Errorf("CombineEptf modified its first argument")
+	}
+
+	// The order of the arguments does not matter.
+	reversed, err := CombineEptf(b, a)
+	if err != nil {
+		t.Fatalf("CombineEptf reversed: %v", err)
+	}
+	if combined.TxHash() != reversed.TxHash() ||
+		combined.WitnessHash() != reversed.WitnessHash() {
+		t.Errorf("CombineEptf depends on argument order")
+	}
+
+	// Conflicting signatures for the same input.
+	conflict := a.Copy()
+	conflict.TxIn[0].Witness = TxWitness{{0xff}}
+	if _, err := CombineEptf(a, conflict); err == nil {
+		t.Errorf("CombineEptf: expected error for conflicting signatures")
+	}
+
+	// Different transactions cannot be combined.
+	other := b.Copy()
+	other.TxOut[0].Value++
+	if _, err := CombineEptf(a, other); err == nil {
+		t.Errorf("CombineEptf: expected error for different outputs")
+	}
+	other = b.Copy()
+	other.TxIn[1].PreviousOutPoint.Index++
+	if _, err := CombineEptf(a, other); err == nil {
+		t.Errorf("CombineEptf: expected error for different inputs")
+	}
+	other = b.Copy()
+	other.LockTime++
+	if _, err := CombineEptf(a, other); err == nil {
+		t.Errorf("CombineEptf: expected error for different locktime")
+	}
+}
+
+// TestPlannedTxHash checks that the txid of a partially signed transaction is
+// known when every unsigned input is segwit, and that it matches the txid once
+// the transaction is signed.
+func TestPlannedTxHash(t *testing.T) {
+	p2wpkh := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x01}, 20)...)
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, bytes.Repeat([]byte{0x02}, 20)...), 0x88, 0xac)
+
+	unsigned := newUnsignedEptfTx(p2wpkh, p2wpkh)
+
+	// All segwit.
+	planned, err := unsigned.PlannedTxHash()
+	if err != nil {
+		t.Fatalf("PlannedTxHash: %v", err)
+	}
+	signed := unsigned.Copy()
+	for i, in := range signed.TxIn {
+		in.Witness = TxWitness{{byte(i), 0xaa}, {0x02, 0x03}}
+	}
+	if planned != signed.TxHash() {
+		t.Errorf("PlannedTxHash: got %v, want %v", planned, signed.TxHash())
+	}
+
+	// Mixed, the non-segwit input is still unsigned.
+	mixed := newUnsignedEptfTx(p2wpkh, p2pkh)
+	if _, err := mixed.PlannedTxHash(); err == nil {
+		t.Errorf("PlannedTxHash: expected error for unsigned non-segwit input")
+	}
+
+	// Mixed, the non-segwit input is signed so its sigscript is final.
+	mixed.TxIn[1].SignatureScript = []byte{0x01, 0x02}
+	mixed.Additional[1] = TxInAdditional{}
+	planned, err = mixed.PlannedTxHash()
+	if err != nil {
+		t.Fatalf("PlannedTxHash mixed: %v", err)
+	}
+	mixedSigned := mixed.Copy()
+	mixedSigned.TxIn[0].Witness = TxWitness{{0xaa}}
+	if planned != mixedSigned.TxHash() {
+		t.Errorf("PlannedTxHash mixed: got %v, want %v", planned, mixedSigned.TxHash())
+	}
+
+	// An unsigned input with no known pkScript.
+	unknown := unsigned.Copy()
+	if _, err := unknown.PlannedTxHash(); err == nil {
+		t.Errorf("PlannedTxHash: expected error for input with unknown pkScript")
+	}
+}
+
+// TestIsEptf ensures that EPTF encoded transactions are recognized from their
+// bytes.
+func TestIsEptf(t *testing.T) {
+	eptfTx := newEptfTx()
+	var b bytes.Buffer
+	if err := eptfTx.BtcEncode(&b, 0, ForceEptfEncoding); err != nil {
+		t.Fatalf("BtcEncode: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		eptf bool
+	}{
+		{"eptf", b.Bytes(), true},
+		{"witness", multiWitnessTxEncoded, false},
+		{"legacy", multiTxEncoded, false},
+		{"magic only", []byte("EPTF\xff\x00"), true},
+		{"too short", []byte("EPTF\xff"), false},
+		{"empty", nil, false},
+	}
+	for _, test := range tests {
+		if got := IsEptf(test.b); got != test.eptf {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.eptf)
+		}
+	}
+}
+
+// TestTxDecodeEptfInputCap checks that an EPTF transaction which declares the
+// maximum number of inputs but carries none of them fails to decode without
+// allocating more than the inputs and their bounded additional info, and that
+// one more input than the maximum is rejected before anything is allocated.
+func TestTxDecodeEptfInputCap(t *testing.T) {
+	eptfHeader := func(count uint64) []byte {
+		var b bytes.Buffer
+		b.Write(eptfMagicBytes)
+		binary.Write(&b, binary.LittleEndian, uint32(1))
+		b.Write([]byte{0x00, 0x01})
+		WriteVarInt(&b, 0, count)
+		return b.Bytes()
+	}
+
+	add, values := makeTxInAdditional(maxTxInPerMessage)
+	if len(add) != maxTxInPerMessage || len(values) != maxTxInPerMessage {
+		t.Fatalf("makeTxInAdditional: got %d entries and %d values, want %d",
+			len(add), len(values), maxTxInPerMessage)
+	}
+	add, values = nil, nil
+
+	// Each input also costs a TxIn of 96 bytes and a pointer to it.
+	const maxTxInBytes = maxTxInPerMessage * (96 + 8)
+	const slack = 1 << 20
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var tx MsgTx
+	err := tx.BtcDecode(bytes.NewReader(eptfHeader(maxTxInPerMessage)), 0, WitnessEncoding)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatalf("BtcDecode: expected error for truncated inputs")
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > maxTxInBytes+maxTxInAdditionalBytes+slack {
+		t.Errorf("BtcDecode: allocated %d bytes at the input cap, want at most %d",
+			alloc, maxTxInBytes+maxTxInAdditionalBytes+slack)
+	}
+
+	runtime.ReadMemStats(&before)
+	err = tx.BtcDecode(bytes.NewReader(eptfHeader(maxTxInPerMessage+1)), 0, WitnessEncoding)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatalf("BtcDecode: expected error above the input cap")
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > slack {
+		t.Errorf("BtcDecode: allocated %d bytes above the input cap", alloc)
+	}
+}
+
+// TestDeserializeAuto checks that standard and EPTF transactions are both
+// decoded with the format detected, and that too short input fails.
+func TestDeserializeAuto(t *testing.T) {
+	eptfTx := newEptfTx()
+	var b bytes.Buffer
+	if err := eptfTx.BtcEncode(&b, 0, ForceEptfEncoding); err != nil {
+		t.Fatalf("BtcEncode: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		want *MsgTx
+		eptf bool
+	}{
+		{"eptf", b.Bytes(), eptfTx, true},
+		{"witness", multiWitnessTxEncoded, multiWitnessTx, false},
+		{"legacy", multiTxEncoded, multiTx, false},
+	}
+	for _, test := range tests {
+		// Read one byte at a time so the peeked bytes must be replayed.
+		tx, eptf, err := DeserializeAuto(iotest.OneByteReader(bytes.NewReader(test.b)))
+		if err != nil {
+			t.Errorf("%s: DeserializeAuto: %v", test.name, err)
+			continue
+		}
+		if eptf != test.eptf {
+			t.Errorf("%s: got eptf %v, want %v", test.name, eptf, test.eptf)
+		}
+		if tx.TxHash() != test.want.TxHash() {
+			t.Errorf("%s: got txid %s, want %s", test.name, tx.TxHash(), test.want.TxHash())
+		}
+		if test.eptf && len(tx.Additional) != len(tx.TxIn) {
+			t.Errorf("%s: got %d Additional for %d inputs",
+				test.name, len(tx.Additional), len(tx.TxIn))
+		}
+	}
+
+	for _, short := range [][]byte{nil, b.Bytes()[:3], multiTxEncoded[:3]} {
+		if _, _, err := DeserializeAuto(bytes.NewReader(short)); err == nil {
+			t.Errorf("DeserializeAuto: expected error for %d bytes", len(short))
+		}
+	}
+}
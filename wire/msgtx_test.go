@@ -8,10 +8,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/pkt-cash/pktd/btcutil/er"
+	"github.com/pkt-cash/pktd/wire/constants"
 	"github.com/pkt-cash/pktd/wire/protocol"
 
 	"github.com/davecgh/go-spew/spew"
@@ -786,6 +790,742 @@ func TestTxWitnessSize(t *testing.T) {
 	}
 }
 
+// TestScriptFreeList tests Borrow and Return on a custom sized script free
+// list and the validation done by SetScriptFreeListSize.
+func TestScriptFreeList(t *testing.T) {
+	pool := newScriptFreeList(2, 64)
+
+	// Borrowed buffers have the requested length and the pool's cap.
+	buf := pool.Borrow(10)
+	if len(buf) != 10 || cap(buf) != 64 {
+		t.Fatalf("Borrow: got len %d cap %d, want len 10 cap 64",
+			len(buf), cap(buf))
+	}
+	pool.Return(buf)
+	if len(pool.pool) != 1 {
+		t.Fatalf("Return: got %d pooled buffers, want 1", len(pool.pool))
+	}
+
+	// A returned buffer is reused by the next Borrow.
+	reused := pool.Borrow(20)
+	if &reused[:1][0] != &buf[:1][0] {
+		t.Fatalf("Borrow: pooled buffer was not reused")
+	}
+	pool.Return(reused)
+
+	// Oversized buffers bypass the pool in both directions.
+	big := pool.Borrow(65)
+	if len(big) != 65 {
+		t.Fatalf("Borrow: got len %d, want 65", len(big))
+	}
+	pool.Return(big)
+	if len(pool.pool) != 1 {
+		t.Fatalf("Return: oversized buffer was pooled")
+	}
+
+	// The pool never holds more than its configured number of items.
+	for i := 0; i < 5; i++ {
+		pool.Return(make([]byte, 64))
+	}
+	if len(pool.pool) != 2 {
+		t.Fatalf("Return: got %d pooled buffers, want 2", len(pool.pool))
+	}
+
+	if err := SetScriptFreeListSize(-1, 64); err == nil {
+		t.Fatalf("SetScriptFreeListSize: expected error for negative items")
+	}
+	if err := SetScriptFreeListSize(10, 0); err == nil {
+		t.Fatalf("SetScriptFreeListSize: expected error for zero size")
+	}
+
+	// Buffers from the old pool are ignored by the new one.
+	old := scriptPool.Borrow(10)
+	if err := SetScriptFreeListSize(4, 128); err != nil {
+		t.Fatalf("SetScriptFreeListSize: %v", err)
+	}
+	defer func() {
+		_ = SetScriptFreeListSize(freeListMaxItems, freeListMaxScriptSize)
+	}()
+	scriptPool.Return(old)
+	if len(scriptPool.pool) != 0 {
+		t.Fatalf("Return: buffer from previous pool was accepted")
+	}
+	if buf := scriptPool.Borrow(100); cap(buf) != 128 {
+		t.Fatalf("Borrow: got cap %d, want 128", cap(buf))
+	}
+
+	// Transactions still decode with the reconfigured pool.
+	var b bytes.Buffer
+	if err := multiTx.Serialize(&b); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	var tx MsgTx
+	if err := tx.Deserialize(&b); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if tx.TxHash() != multiTx.TxHash() {
+		t.Fatalf("Deserialize: got tx %s, want %s", tx.TxHash(),
+			multiTx.TxHash())
+	}
+}
+
+// TestScriptFreeListStats tests that borrowing and returning buffers updates
+// the free list counters.
+func TestScriptFreeListStats(t *testing.T) {
+	pool := newScriptFreeList(1, 64)
+
+	a := pool.Borrow(10) // miss
+	b := pool.Borrow(20) // miss
+	pool.Return(a)       // pooled
+	pool.Return(b)       // pool is full, dropped
+	c := pool.Borrow(30) // hit
+	pool.Return(c)       // pooled
+	big := pool.Borrow(65)
+	pool.Return(big) // oversized, ignored
+
+	want := ScriptFreeListStats{Borrows: 3, Returns: 2, Misses: 2, Oversized: 1}
+	if got := pool.stats(); got != want {
+		t.Fatalf("stats: got %+v, want %+v", got, want)
+	}
+
+	// The global pool counters start over when it is replaced.
+	if err := SetScriptFreeListSize(4, 128); err != nil {
+		t.Fatalf("SetScriptFreeListSize: %v", err)
+	}
+	defer func() {
+		_ = SetScriptFreeListSize(freeListMaxItems, freeListMaxScriptSize)
+	}()
+	if got := ScriptPoolStats(); got != (ScriptFreeListStats{}) {
+		t.Fatalf("ScriptPoolStats: got %+v for new pool", got)
+	}
+	var buf bytes.Buffer
+	if err := multiTx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	var tx MsgTx
+	if err := tx.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	got := ScriptPoolStats()
+	if got.Borrows == 0 || got.Returns != got.Borrows || got.Misses > 4 {
+		t.Fatalf("ScriptPoolStats: unexpected counters after decode %+v", got)
+	}
+}
+
+// TestDisableScriptPool ensures that while the script free list is disabled
+// every buffer is newly allocated and nothing is returned to the free list.
+func TestDisableScriptPool(t *testing.T) {
+	pool := newScriptFreeList(2, 64)
+	DisableScriptPool(true)
+	defer DisableScriptPool(false)
+
+	a := pool.Borrow(10)
+	if len(a) != 10 || cap(a) != 10 {
+		t.Fatalf("Borrow: got len %d cap %d, want len 10 cap 10", len(a), cap(a))
+	}
+	pool.Return(a)
+	pool.Return(make([]byte, 64))
+	if len(pool.pool) != 0 {
+		t.Fatalf("Return: got %d pooled buffers, want 0", len(pool.pool))
+	}
+	b := pool.Borrow(10)
+	if &a[0] == &b[0] {
+		t.Fatalf("Borrow: buffer was reused")
+	}
+	if got := pool.stats(); got != (ScriptFreeListStats{}) {
+		t.Fatalf("stats: got %+v while disabled", got)
+	}
+
+	// Transactions still decode with the free list disabled.
+	var tx MsgTx
+	if err := tx.Deserialize(bytes.NewReader(multiTxEncoded)); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if tx.TxHash() != multiTx.TxHash() {
+		t.Fatalf("Deserialize: got tx %s, want %s", tx.TxHash(), multiTx.TxHash())
+	}
+
+	// Once enabled again the free list is used.
+	DisableScriptPool(false)
+	pool.Return(pool.Borrow(10))
+	if len(pool.pool) != 1 {
+		t.Fatalf("Return: got %d pooled buffers after enabling, want 1", len(pool.pool))
+	}
+}
+
+// TestTxDeserializeLimited tests that DeserializeLimited decodes transactions
+// within the size limit and aborts early on transactions which exceed it.
+func TestTxDeserializeLimited(t *testing.T) {
+	var b bytes.Buffer
+	if err := multiTx.Serialize(&b); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	encoded := b.Bytes()
+
+	var tx MsgTx
+	err := tx.DeserializeLimited(bytes.NewReader(encoded), uint32(len(encoded)))
+	if err != nil {
+		t.Fatalf("DeserializeLimited: %v", err)
+	}
+	if tx.TxHash() != multiTx.TxHash() {
+		t.Fatalf("DeserializeLimited: got tx %s, want %s", tx.TxHash(),
+			multiTx.TxHash())
+	}
+
+	err = tx.DeserializeLimited(bytes.NewReader(encoded), uint32(len(encoded)-1))
+	if !MessageError.Is(err) {
+		t.Fatalf("DeserializeLimited: expected MessageError, got %v", err)
+	}
+
+	// The oversized script must be rejected without reading the rest of
+	// the transaction.
+	big := multiTx.Copy()
+	big.TxOut[0].PkScript = make([]byte, 100000)
+	b.Reset()
+	if err := big.Serialize(&b); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	r := bytes.NewReader(b.Bytes())
+	err = tx.DeserializeLimited(r, 1000)
+	if !MessageError.Is(err) {
+		t.Fatalf("DeserializeLimited: expected MessageError, got %v", err)
+	}
+	if r.Len() < 100000 {
+		t.Fatalf("DeserializeLimited: read %d bytes before aborting",
+			int(r.Size())-r.Len())
+	}
+
+	if err := tx.DeserializeLimited(bytes.NewReader(encoded),
+		MaxBlockPayload+1); !MessageError.Is(err) {
+		t.Fatalf("DeserializeLimited: expected MessageError for limit "+
+			"above MaxBlockPayload, got %v", err)
+	}
+}
+
+// TestTxDeserializeLimitedAlloc tests that DeserializeLimited rejects declared
+// counts and lengths which cannot fit within the limit before allocating memory
+// for them.
+func TestTxDeserializeLimitedAlloc(t *testing.T) {
+	const limit = 1000
+	const slack = 64 * 1024
+
+	// A single input whose signature script claims to be 1MB.
+	var bigScript bytes.Buffer
+	bigScript.Write([]byte{0x01, 0x00, 0x00, 0x00, 0x01})
+	bigScript.Write(make([]byte, 36))
+	if err := WriteVarInt(&bigScript, 0, 1000000); err != nil {
+		t.Fatalf("WriteVarInt: %v", err)
+	}
+
+	// An input count which would need far more than limit bytes.
+	var manyInputs bytes.Buffer
+	manyInputs.Write([]byte{0x01, 0x00, 0x00, 0x00})
+	if err := WriteVarInt(&manyInputs, 0, maxTxInPerMessage); err != nil {
+		t.Fatalf("WriteVarInt: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded []byte
+	}{
+		{"script length", bigScript.Bytes()},
+		{"input count", manyInputs.Bytes()},
+	}
+	for _, test := range tests {
+		var before, after runtime.MemStats
+		var tx MsgTx
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		err := tx.DeserializeLimited(bytes.NewReader(test.encoded), limit)
+		runtime.ReadMemStats(&after)
+		if !MessageError.Is(err) {
+			t.Fatalf("%s: expected MessageError, got %v", test.name, err)
+		}
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > slack {
+			t.Errorf("%s: allocated %d bytes before rejecting, want at most %d",
+				test.name, alloc, slack)
+		}
+	}
+}
+
+// TestTxHex tests round tripping transactions through hex strings and the
+// errors returned for malformed hex.
+func TestTxHex(t *testing.T) {
+	for _, tx := range []*MsgTx{multiTx, multiWitnessTx} {
+		s, err := tx.ToHex()
+		if err != nil {
+			t.Fatalf("ToHex: %v", err)
+		}
+		decoded, err := NewMsgTxFromHex("  " + s + "\n")
+		if err != nil {
+			t.Fatalf("NewMsgTxFromHex: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, tx) {
+			t.Errorf("NewMsgTxFromHex: mismatched tx - got %v, want %v",
+				spew.Sdump(decoded), spew.Sdump(tx))
+		}
+		if decoded.WitnessHash() != tx.WitnessHash() {
+			t.Errorf("NewMsgTxFromHex: got witness hash %s, want %s",
+				decoded.WitnessHash(), tx.WitnessHash())
+		}
+	}
+
+	valid, err := multiTx.ToHex()
+	if err != nil {
+		t.Fatalf("ToHex: %v", err)
+	}
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"odd length", valid[1:]},
+		{"non-hex", "zz" + valid[2:]},
+		{"truncated", valid[:len(valid)-2]},
+		{"trailing data", valid + "00"},
+	}
+	for _, test := range tests {
+		if _, err := NewMsgTxFromHex(test.in); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+	for _, test := range tests[:2] {
+		if _, err := NewMsgTxFromHex(test.in); !MessageError.Is(err) {
+			t.Errorf("%s: expected MessageError, got %v", test.name, err)
+		}
+	}
+}
+
+// TestWitnessLimits tests that transactions whose witness exceeds the limits
+// configured with SetWitnessLimits are rejected.
+func TestWitnessLimits(t *testing.T) {
+	defer func() {
+		_ = SetWitnessLimits(maxWitnessItemsPerInput, maxWitnessItemSize)
+	}()
+
+	if err := SetWitnessLimits(0, 100); err == nil {
+		t.Fatalf("SetWitnessLimits: expected error for zero items")
+	}
+	if err := SetWitnessLimits(10, 0); err == nil {
+		t.Fatalf("SetWitnessLimits: expected error for zero size")
+	}
+
+	// multiWitnessTx has a single input with a 70 byte and a 33 byte
+	// witness item.
+	tests := []struct {
+		name        string
+		maxItems    int
+		maxItemSize int
+		valid       bool
+	}{
+		{"at limits", 2, 70, true},
+		{"too many items", 1, 70, false},
+		{"item too large", 2, 69, false},
+	}
+	for _, test := range tests {
+		if err := SetWitnessLimits(test.maxItems, test.maxItemSize); err != nil {
+			t.Fatalf("%s: SetWitnessLimits: %v", test.name, err)
+		}
+		var tx MsgTx
+		err := tx.Deserialize(bytes.NewReader(multiWitnessTxEncoded))
+		if test.valid && err != nil {
+			t.Errorf("%s: Deserialize: %v", test.name, err)
+		} else if !test.valid && !MessageError.Is(err) {
+			t.Errorf("%s: expected MessageError, got %v", test.name, err)
+		}
+	}
+}
+
+// TestTxHashStreaming tests that TxHashStreaming produces the same hash as
+// TxHash.
+func TestTxHashStreaming(t *testing.T) {
+	txns := []*MsgTx{multiTx, multiWitnessTx, &genesisCoinbaseTx, NewMsgTx(1)}
+	txns = append(txns, blockOne.Transactions...)
+	for i, tx := range txns {
+		if got, want := tx.TxHashStreaming(), tx.TxHash(); got != want {
+			t.Errorf("TxHashStreaming #%d: got %s, want %s", i, got, want)
+		}
+	}
+}
+
+// TestTxIsCoinBase tests IsCoinBase on coinbase and non-coinbase transactions.
+func TestTxIsCoinBase(t *testing.T) {
+	coinbaseIn := func() *TxIn {
+		return &TxIn{PreviousOutPoint: OutPoint{Index: constants.MaxPrevOutIndex}}
+	}
+	nonZeroHash := coinbaseIn()
+	nonZeroHash.PreviousOutPoint.Hash[0] = 0x01
+	zeroIndex := coinbaseIn()
+	zeroIndex.PreviousOutPoint.Index = 0
+
+	tests := []struct {
+		name string
+		txIn []*TxIn
+		want bool
+	}{
+		{"coinbase", []*TxIn{coinbaseIn()}, true},
+		{"no inputs", nil, false},
+		{"two coinbase inputs", []*TxIn{coinbaseIn(), coinbaseIn()}, false},
+		{"nonzero hash", []*TxIn{nonZeroHash}, false},
+		{"zero index", []*TxIn{zeroIndex}, false},
+	}
+	for _, test := range tests {
+		tx := NewMsgTx(1)
+		tx.TxIn = test.txIn
+		if got := tx.IsCoinBase(); got != test.want {
+			t.Errorf("%s: IsCoinBase got %v, want %v", test.name, got,
+				test.want)
+		}
+	}
+	if !genesisCoinbaseTx.IsCoinBase() {
+		t.Errorf("IsCoinBase: genesis coinbase not detected")
+	}
+	if multiWitnessTx.IsCoinBase() {
+		t.Errorf("IsCoinBase: multiWitnessTx detected as coinbase")
+	}
+}
+
+// TestInsertTxInOut checks inserting inputs and outputs at the front, middle
+// and end of a transaction and that Additional stays aligned with the inputs.
+func TestInsertTxInOut(t *testing.T) {
+	value := int64(1000)
+	in := func(i uint32) *TxIn {
+		return &TxIn{PreviousOutPoint: OutPoint{Index: i}}
+	}
+	tx := &MsgTx{
+		TxIn:       []*TxIn{in(1), in(3)},
+		TxOut:      []*TxOut{NewTxOut(1, nil), NewTxOut(3, nil)},
+		Additional: []TxInAdditional{{PkScript: []byte{1}, Value: &value}, {PkScript: []byte{3}}},
+	}
+
+	tests := []struct {
+		index int
+		num   uint32
+	}{
+		{0, 0}, // front
+		{2, 2}, // middle
+		{4, 4}, // end
+	}
+	for _, test := range tests {
+		if err := tx.InsertTxIn(test.index, in(test.num)); err != nil {
+			t.Fatalf("InsertTxIn(%d): %v", test.index, err)
+		}
+		if err := tx.InsertTxOut(test.index, NewTxOut(int64(test.num), nil)); err != nil {
+			t.Fatalf("InsertTxOut(%d): %v", test.index, err)
+		}
+	}
+	if len(tx.TxIn) != 5 || len(tx.TxOut) != 5 || len(tx.Additional) != 5 {
+		t.Fatalf("got %d inputs, %d outputs and %d additional, want 5 of each",
+			len(tx.TxIn), len(tx.TxOut), len(tx.Additional))
+	}
+	for i := range tx.TxIn {
+		if tx.TxIn[i].PreviousOutPoint.Index != uint32(i) {
+			t.Errorf("input %d: got %d", i, tx.TxIn[i].PreviousOutPoint.Index)
+		}
+		if tx.TxOut[i].Value != int64(i) {
+			t.Errorf("output %d: got %d", i, tx.TxOut[i].Value)
+		}
+		switch i {
+		case 1, 3:
+			if !bytes.Equal(tx.Additional[i].PkScript, []byte{byte(i)}) {
+				t.Errorf("additional %d: got %x", i, tx.Additional[i].PkScript)
+			}
+		default:
+			if tx.Additional[i].PkScript != nil || tx.Additional[i].Value != nil {
+				t.Errorf("additional %d: expected empty entry", i)
+			}
+		}
+	}
+
+	// Out of range.
+	if err := tx.InsertTxIn(-1, in(9)); err == nil {
+		t.Errorf("InsertTxIn: expected error for negative index")
+	}
+	if err := tx.InsertTxIn(6, in(9)); err == nil {
+		t.Errorf("InsertTxIn: expected error for index past the end")
+	}
+	if err := tx.InsertTxOut(6, NewTxOut(9, nil)); err == nil {
+		t.Errorf("InsertTxOut: expected error for index past the end")
+	}
+
+	// Without Additional nothing is added to it.
+	plain := &MsgTx{}
+	if err := plain.InsertTxIn(0, in(0)); err != nil {
+		t.Fatalf("InsertTxIn: %v", err)
+	}
+	if plain.Additional != nil {
+		t.Errorf("InsertTxIn: Additional created for a tx which had none")
+	}
+}
+
+// TestTxValueSums checks OutputSum and InputSum, including totals which are
+// close to or beyond the range of an int64.
+func TestTxValueSums(t *testing.T) {
+	if sum := multiTx.OutputSum(); sum != 0x12a05f200+0x5f5e100 {
+		t.Errorf("OutputSum: got %d, want %d", sum, 0x12a05f200+0x5f5e100)
+	}
+	if sum := (&MsgTx{}).OutputSum(); sum != 0 {
+		t.Errorf("OutputSum of empty tx: got %d", sum)
+	}
+
+	nearMax := &MsgTx{TxOut: []*TxOut{
+		NewTxOut(math.MaxInt64-10, nil),
+		NewTxOut(10, nil),
+	}}
+	if sum := nearMax.OutputSum(); sum != math.MaxInt64 {
+		t.Errorf("OutputSum near max: got %d, want %d", sum, int64(math.MaxInt64))
+	}
+	nearMax.AddTxOut(NewTxOut(1, nil))
+	if sum := nearMax.OutputSum(); sum != math.MaxInt64 {
+		t.Errorf("OutputSum overflow: got %d, want saturation at %d", sum, int64(math.MaxInt64))
+	}
+
+	a, b := int64(math.MaxInt64-10), int64(10)
+	tx := &MsgTx{
+		TxIn:       []*TxIn{{}, {}},
+		Additional: []TxInAdditional{{Value: &a}, {Value: &b}},
+	}
+	if sum, err := tx.InputSum(); err != nil || sum != math.MaxInt64 {
+		t.Errorf("InputSum near max: got %d, %v", sum, err)
+	}
+	b = 11
+	if _, err := tx.InputSum(); err == nil {
+		t.Errorf("InputSum: expected overflow error")
+	}
+	tx.Additional[1].Value = nil
+	if _, err := tx.InputSum(); err == nil {
+		t.Errorf("InputSum: expected error for unknown value")
+	}
+	tx.Additional = tx.Additional[:1]
+	if _, err := tx.InputSum(); err == nil {
+		t.Errorf("InputSum: expected error for missing Additional")
+	}
+}
+
+// TestTxSerializeInto checks that SerializeInto replaces the contents of the
+// buffer with the same bytes as Serialize.
+func TestTxSerializeInto(t *testing.T) {
+	var want bytes.Buffer
+	if err := multiWitnessTx.Serialize(&want); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	buf := bytes.NewBufferString("stale data")
+	for i := 0; i < 2; i++ {
+		if err := multiWitnessTx.SerializeInto(buf); err != nil {
+			t.Fatalf("SerializeInto: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+			t.Fatalf("SerializeInto #%d: got %x, want %x", i, buf.Bytes(), want.Bytes())
+		}
+	}
+}
+
+// TestRelativeLockSequence checks the BIP0068 encoding of relative locktimes.
+func TestRelativeLockSequence(t *testing.T) {
+	blockTests := []struct {
+		n    uint16
+		want uint32
+	}{
+		{0, 0x00000000},
+		{1, 0x00000001},
+		{144, 0x00000090},
+		{0xffff, 0x0000ffff},
+	}
+	for _, test := range blockTests {
+		if got := SequenceForRelativeBlocks(test.n); got != test.want {
+			t.Errorf("SequenceForRelativeBlocks(%d): got %#08x, want %#08x",
+				test.n, got, test.want)
+		}
+	}
+
+	timeTests := []struct {
+		d    time.Duration
+		want uint32
+	}{
+		{0, 0x00400000},
+		{time.Second, 0x00400001},
+		{512 * time.Second, 0x00400001},
+		{512*time.Second + time.Nanosecond, 0x00400002},
+		{1024 * time.Second, 0x00400002},
+		{24 * time.Hour, 0x004000a9},
+		{65535 * 512 * time.Second, 0x0040ffff},
+	}
+	for _, test := range timeTests {
+		got, err := SequenceForRelativeTime(test.d)
+		if err != nil {
+			t.Errorf("SequenceForRelativeTime(%s): %v", test.d, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("SequenceForRelativeTime(%s): got %#08x, want %#08x",
+				test.d, got, test.want)
+		}
+		if got&constants.SequenceLockTimeDisabled != 0 {
+			t.Errorf("SequenceForRelativeTime(%s): relative locktime is disabled", test.d)
+		}
+	}
+
+	for _, d := range []time.Duration{-time.Second, 65535*512*time.Second + 1} {
+		if _, err := SequenceForRelativeTime(d); err == nil {
+			t.Errorf("SequenceForRelativeTime(%s): expected error", d)
+		}
+	}
+}
+
+// TestTxDeserializeFromBytes ensures that decoding transactions one after
+// another from a byte slice gives the same result as decoding them from a
+// reader, and that the scripts share the input slice.
+func TestTxDeserializeFromBytes(t *testing.T) {
+	var block []byte
+	block = append(block, multiTxEncoded...)
+	block = append(block, multiWitnessTxEncoded...)
+
+	offset := 0
+	for i, encoded := range [][]byte{multiTxEncoded, multiWitnessTxEncoded} {
+		var fromReader MsgTx
+		if err := fromReader.Deserialize(bytes.NewReader(encoded)); err != nil {
+			t.Fatalf("Deserialize #%d error %v", i, err)
+		}
+		var fromBytes MsgTx
+		n, err := fromBytes.DeserializeFromBytes(block[offset:])
+		if err != nil {
+			t.Fatalf("DeserializeFromBytes #%d error %v", i, err)
+		}
+		if n != len(encoded) {
+			t.Errorf("DeserializeFromBytes #%d consumed %d bytes, want %d",
+				i, n, len(encoded))
+		}
+		if !reflect.DeepEqual(&fromBytes, &fromReader) {
+			t.Errorf("DeserializeFromBytes #%d\n got: %s want: %s", i,
+				spew.Sdump(&fromBytes), spew.Sdump(&fromReader))
+		}
+		offset += n
+	}
+	if offset != len(block) {
+		t.Fatalf("consumed %d bytes of %d", offset, len(block))
+	}
+
+	// The public key script is a subslice of the input.
+	var tx MsgTx
+	buf := append([]byte{}, multiTxEncoded...)
+	if _, err := tx.DeserializeFromBytes(buf); err != nil {
+		t.Fatalf("DeserializeFromBytes error %v", err)
+	}
+	buf[multiTxPkScriptLocs[0]] ^= 0xff
+	if tx.TxOut[0].PkScript[0] != buf[multiTxPkScriptLocs[0]] {
+		t.Errorf("PkScript does not share the input slice")
+	}
+
+	// A truncated transaction is an error.
+	if _, err := tx.DeserializeFromBytes(multiTxEncoded[:len(multiTxEncoded)-10]); err == nil {
+		t.Errorf("DeserializeFromBytes of truncated tx did not fail")
+	}
+}
+
+// TestTxNormalize ensures that equivalent transactions which differ in whether
+// empty fields are nil are equal after they are normalized.
+func TestTxNormalize(t *testing.T) {
+	var decoded MsgTx
+	if err := decoded.Deserialize(bytes.NewReader(multiWitnessTxEncoded)); err != nil {
+		t.Fatalf("Deserialize error %v", err)
+	}
+
+	// Build the same transaction by hand with empty but non-nil fields and
+	// Additional entries which carry nothing.
+	built := multiWitnessTx.Copy()
+	for _, ti := range built.TxIn {
+		if ti.SignatureScript == nil {
+			ti.SignatureScript = []byte{}
+		}
+		if ti.Witness == nil {
+			ti.Witness = TxWitness{}
+		}
+	}
+	built.Additional = make([]TxInAdditional, len(built.TxIn))
+
+	if reflect.DeepEqual(&decoded, built) {
+		t.Fatalf("transactions are expected to differ before normalizing")
+	}
+	decoded.Normalize()
+	built.Normalize()
+	if !reflect.DeepEqual(&decoded, built) {
+		t.Fatalf("normalized transactions differ\n got: %s want: %s",
+			spew.Sdump(built), spew.Sdump(&decoded))
+	}
+	if built.Additional != nil {
+		t.Fatalf("empty Additional was not removed")
+	}
+
+	// Additional which carries information is kept.
+	value := int64(1000)
+	built.Additional = make([]TxInAdditional, len(built.TxIn))
+	built.Additional[0].Value = &value
+	built.Additional[0].PkScript = []byte{}
+	built.Normalize()
+	if len(built.Additional) != len(built.TxIn) || built.Additional[0].PkScript != nil {
+		t.Fatalf("unexpected Additional after normalizing: %s", spew.Sdump(built.Additional))
+	}
+}
+
+// TestTxSpentOutpoints checks that SpentOutpoints gives the outpoint of each
+// input and that HasDuplicateInputs detects an outpoint spent twice.
+func TestTxSpentOutpoints(t *testing.T) {
+	tx := &MsgTx{}
+	for i := uint32(0); i < 3; i++ {
+		tx.AddTxIn(NewTxIn(&OutPoint{Hash: chainhash.Hash{byte(i)}, Index: i}, nil, nil))
+	}
+	spent := tx.SpentOutpoints()
+	if len(spent) != len(tx.TxIn) {
+		t.Fatalf("SpentOutpoints: got %d entries, want %d", len(spent), len(tx.TxIn))
+	}
+	for i, txIn := range tx.TxIn {
+		if _, ok := spent[txIn.PreviousOutPoint]; !ok {
+			t.Errorf("SpentOutpoints: missing input %d", i)
+		}
+	}
+	if tx.HasDuplicateInputs() {
+		t.Errorf("HasDuplicateInputs: got true for distinct inputs")
+	}
+
+	tx.AddTxIn(NewTxIn(&tx.TxIn[1].PreviousOutPoint, nil, nil))
+	if spent := tx.SpentOutpoints(); len(spent) != 3 {
+		t.Errorf("SpentOutpoints: got %d entries with a duplicate, want 3", len(spent))
+	}
+	if !tx.HasDuplicateInputs() {
+		t.Errorf("HasDuplicateInputs: got false for a duplicate input")
+	}
+	if (&MsgTx{}).HasDuplicateInputs() {
+		t.Errorf("HasDuplicateInputs: got true for empty tx")
+	}
+}
+
+// TestTxWitnessAnnex checks that a taproot annex is only recognized as the
+// last of at least two witness items.
+func TestTxWitnessAnnex(t *testing.T) {
+	sig := bytes.Repeat([]byte{0x01}, 64)
+	annex := []byte{TaprootAnnexTag, 0x02, 0x03}
+	tests := []struct {
+		name    string
+		witness TxWitness
+		annex   []byte
+	}{
+		{"key path with annex", TxWitness{sig, annex}, annex},
+		{"script path with annex", TxWitness{sig, []byte{0x51}, []byte{0xc0}, annex}, annex},
+		{"key path", TxWitness{sig}, nil},
+		{"annex alone", TxWitness{annex}, nil},
+		{"empty last item", TxWitness{sig, {}}, nil},
+		{"script path", TxWitness{sig, []byte{0x51}, []byte{0xc0}}, nil},
+		{"empty", nil, nil},
+	}
+	for _, test := range tests {
+		if got := test.witness.HasAnnex(); got != (test.annex != nil) {
+			t.Errorf("%s: HasAnnex got %v, want %v", test.name, got, test.annex != nil)
+		}
+		if got := test.witness.Annex(); !bytes.Equal(got, test.annex) {
+			t.Errorf("%s: Annex got %x, want %x", test.name, got, test.annex)
+		}
+	}
+}
+
 // multiTx is a MsgTx with an input and output and used in various tests.
 var multiTx = &MsgTx{
 	Version: 1,
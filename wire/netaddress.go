@@ -60,6 +60,34 @@ func (na *NetAddress) AddService(service protocol.ServiceFlag) {
 	na.Services |= service
 }
 
+// WithTimestamp returns a copy of the address with the timestamp replaced by
+// t. The timestamp is rounded to single second precision, the receiver is not
+// modified.
+func (na *NetAddress) WithTimestamp(t time.Time) *NetAddress {
+	out := na.copy()
+	out.Timestamp = time.Unix(t.Unix(), 0)
+	return out
+}
+
+// WithServices returns a copy of the address with the supported services
+// replaced by sf, the receiver is not modified.
+func (na *NetAddress) WithServices(sf protocol.ServiceFlag) *NetAddress {
+	out := na.copy()
+	out.Services = sf
+	return out
+}
+
+// copy returns a deep copy of the address so that the IP of the result does
+// not share memory with the receiver.
+func (na *NetAddress) copy() *NetAddress {
+	out := *na
+	if na.IP != nil {
+		out.IP = make(net.IP, len(na.IP))
+		copy(out.IP, na.IP)
+	}
+	return &out
+}
+
 // NewNetAddressIPPort returns a new NetAddress using the provided IP, port, and
 // supported services with defaults for the remaining fields.
 func NewNetAddressIPPort(ip net.IP, port uint16, services protocol.ServiceFlag) *NetAddress {
@@ -74,6 +74,46 @@ func TestNetAddress(t *testing.T) {
 	}
 }
 
+// TestNetAddressWith tests that WithTimestamp and WithServices return
+// independent copies with the expected fields set.
+func TestNetAddressWith(t *testing.T) {
+	ts := time.Unix(0x495fab29, 0)
+	na := NewNetAddressTimestamp(ts, 0, net.ParseIP("127.0.0.1"), 8333)
+
+	later := ts.Add(time.Hour + 500*time.Millisecond)
+	withTs := na.WithTimestamp(later)
+	if !withTs.Timestamp.Equal(time.Unix(later.Unix(), 0)) {
+		t.Errorf("WithTimestamp: wrong timestamp - got %v, want %v",
+			withTs.Timestamp, time.Unix(later.Unix(), 0))
+	}
+	if !na.Timestamp.Equal(ts) {
+		t.Errorf("WithTimestamp: original modified - got %v, want %v",
+			na.Timestamp, ts)
+	}
+
+	withSf := na.WithServices(protocol.SFNodeNetwork)
+	if withSf.Services != protocol.SFNodeNetwork {
+		t.Errorf("WithServices: wrong services - got %v, want %v",
+			withSf.Services, protocol.SFNodeNetwork)
+	}
+	if na.Services != 0 {
+		t.Errorf("WithServices: original modified - got %v, want %v",
+			na.Services, 0)
+	}
+	if !withSf.Timestamp.Equal(ts) || withSf.Port != na.Port ||
+		!withSf.IP.Equal(na.IP) {
+		t.Errorf("WithServices: unexpected fields - got %v, want %v",
+			spew.Sdump(withSf), spew.Sdump(na))
+	}
+
+	// Mutating the IP of a copy must not affect the original.
+	withSf.IP[len(withSf.IP)-1] = 2
+	if !na.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("WithServices: copy shares IP with original - got %v",
+			na.IP)
+	}
+}
+
 // TestNetAddressWire tests the NetAddress wire encode and decode for various
 // protocol versions and timestamp flag combinations.
 func TestNetAddressWire(t *testing.T) {